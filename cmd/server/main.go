@@ -1,28 +1,370 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/mongo"
 
-	"UploadDocument-Saas/internal/middleware"
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/clients"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/handlers"
+	"UploadDocument-Saas/internal/health"
+	"UploadDocument-Saas/internal/keys"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/notifications"
+	"UploadDocument-Saas/internal/pipeline"
+	"UploadDocument-Saas/internal/quota"
+	"UploadDocument-Saas/internal/ratelimit"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/retry"
+	"UploadDocument-Saas/internal/signedurl"
+	"UploadDocument-Saas/internal/storage"
+	"UploadDocument-Saas/internal/tracing"
+	"UploadDocument-Saas/internal/webhooks"
 	"UploadDocument-Saas/internal/websocket"
 	"UploadDocument-Saas/pkg/logger"
 	"UploadDocument-Saas/routes"
 )
 
 func main() {
-	logFile := logger.InitLogFile()
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", "", "YAML or TOML config file (default $CONFIG_FILE or ./config.yaml)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+
+	logFile := logger.Init(logger.Options{
+		Level:          cfg.Log.Level,
+		Output:         cfg.Log.Output,
+		File:           cfg.Log.File,
+		MaxSizeMB:      cfg.Log.MaxSizeMB,
+		MaxBackups:     cfg.Log.MaxBackups,
+		RotateInterval: cfg.Log.RotateInterval,
+		Compress:       cfg.Log.Compress,
+	})
 	defer logFile.Close()
 
-	app := fiber.New()
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		return 1
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	kms, err := keys.NewLocalKMS(cfg.Keys.MasterKeyFile)
+	if err != nil {
+		slog.Error("Failed to load master key", "error", err)
+		return 1
+	}
+
+	conns, err := connect(cfg)
+	if err != nil {
+		slog.Error("Failed to connect to dependencies", "error", err)
+		return 1
+	}
+	comps, err := build(cfg, conns, keys.NewManager(kms, conns.store))
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		conns.mongo.Disconnect(context.Background())
+		return 1
+	}
+
+	app := fiber.New(fiber.Config{
+		// c.IP() is the real client address only for requests from a trusted proxy
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: cfg.Server.ProxyHeader != "",
+		TrustedProxies:          cfg.Server.Proxies(),
+		EnableIPValidation:      true,
+	})
 
-	go websocket.HubInstance.Run()
+	// Background workers run until workersCtx is cancelled during shutdown
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
 
-	// Middleware
-	app.Use(middleware.RecoverMiddleware())
-	app.Use(middleware.LoggerMiddleware())
+	// Relay hub messages between replicas when the hub bus is kafka
+	var bus websocket.Bus
+	if cfg.Hub.Bus == "kafka" {
+		replicaID := cfg.Hub.ReplicaID
+		if replicaID == "" {
+			replicaID = websocket.ReplicaID()
+		}
+		bus = websocket.NewKafkaBus(cfg.Kafka.Broker, cfg.Hub.BusTopic)
+		comps.hub.ConnectBus(workersCtx, bus, replicaID)
+		slog.Info("Hub relaying through Kafka", "topic", cfg.Hub.BusTopic, "replica", replicaID)
+	}
+	go comps.hub.Run()
+	metrics.MustRegister(comps.hub)
+	startWorker(comps.pipeline.RunIndexer)
+	startWorker(comps.pipeline.RunIndexQueue)
+	startWorker(comps.webhooks.Run)
+	startWorker(comps.notifier.RunDigests)
+	comps.notifier.WatchFolders(comps.events)
+	startWorker(comps.quotas.RunReconcile)
+
+	// Reload the master key file and re-wrap data keys still under a retired master key
+	// version, at startup and whenever an operator sends SIGHUP after adding a version
+	go func() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+		for {
+			if n, err := comps.keys.RewrapAll(workersCtx); err != nil {
+				slog.Error("Error re-wrapping tenant data keys", "error", err)
+			} else if n > 0 {
+				slog.Info("Re-wrapped tenant data keys", "count", n, "master_version", comps.keys.MasterVersion())
+			}
+			select {
+			case <-workersCtx.Done():
+				return
+			case <-hangup:
+			}
+		}
+	}()
 
 	// Load routes
-	routes.SetupRoutes(app)
+	routes.SetupRoutes(app, cfg, routes.Dependencies{
+		Handler: handlers.New(handlers.Options{
+			Store:    conns.store,
+			Audit:    comps.audit,
+			Events:   comps.events,
+			Health:   conns.health,
+			Keys:     comps.keys,
+			Signer:   comps.signer,
+			Storage:  comps.storage,
+			Quotas:   comps.quotas,
+			Hub:      comps.hub,
+			Pipeline: comps.pipeline,
+			Notifier: comps.notifier,
+			Webhooks: comps.webhooks,
+		}),
+		Audit:   comps.audit,
+		Tokens:  comps.tokens,
+		Limiter: comps.limiter,
+		Hub:     comps.hub,
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.Server.Addr())
+	}()
+
+	code := 0
+	select {
+	case sig := <-signals:
+		slog.Info("Shutting down", "signal", sig.String())
+	case err := <-listenErr:
+		slog.Error("Server stopped", "error", err)
+		code = 1
+	}
+	signal.Stop(signals)
+
+	shutdown(app, cfg.Server.ShutdownTimeout, stopWorkers, &workers, bus, conns, comps)
+	return code
+}
+
+// components are the parts of the server built from their sections of cfg, owned by main
+type components struct {
+	keys     *keys.Manager
+	signer   *signedurl.Signer
+	events   *events.Bus
+	audit    *audit.Log
+	tokens   *auth.Tokens
+	limiter  *ratelimit.Limiter
+	quotas   *quota.Quotas
+	storage  storage.Storage
+	hub      *websocket.Hub
+	pipeline *pipeline.Pipeline
+	notifier *notifications.Notifier
+	webhooks *webhooks.Dispatcher
+}
+
+// build creates each component from its section of cfg, the connections it uses and the
+// key manager blobs are encrypted with
+func build(cfg *config.Config, conns *connections, keyManager *keys.Manager) (*components, error) {
+	tokens, err := auth.NewTokens(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("auth.tokens: %w", err)
+	}
+	limiter, err := ratelimit.New(cfg.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Store == "redis" {
+		slog.Info("Rate limits shared through Redis", "addr", cfg.RateLimit.RedisAddr)
+	}
+	quotas, err := quota.New(cfg.Quota, conns.store)
+	if err != nil {
+		return nil, fmt.Errorf("quota.tenant_overrides: %w", err)
+	}
+	signer, err := signedurl.New(cfg.Keys.DownloadURLSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	bus := events.NewBus()
+	store := storage.New(cfg.Storage, keyManager)
+	hub := websocket.NewHub(cfg.Hub, tokens, conns.store)
+	return &components{
+		keys:    keyManager,
+		signer:  signer,
+		events:  bus,
+		audit:   audit.New(conns.store),
+		tokens:  tokens,
+		limiter: limiter,
+		quotas:  quotas,
+		storage: store,
+		hub:     hub,
+		pipeline: pipeline.New(pipeline.Options{
+			Writer: conns.kafka,
+			NewReader: func(groupID string) *kafka.Reader {
+				return clients.NewKafkaReader(cfg.Kafka, groupID)
+			},
+			Store:   conns.store,
+			Storage: store,
+			Hub:     hub,
+		}),
+		notifier: notifications.New(conns.store, hub, notifications.NewSender(cfg.SMTP)),
+		webhooks: webhooks.NewDispatcher(cfg.Webhooks, conns.store, bus),
+	}, nil
+}
+
+// connections are the clients of the backing services, owned by main, with the
+// repositories and health checks built on them
+type connections struct {
+	mongo   *mongo.Client
+	elastic *elasticsearch.Client
+	kafka   *kafka.Writer
+	store   *repositories.Store
+	health  *health.Checker
+}
+
+// connect creates the clients and the store and health checks over them. MongoDB
+// is required, so it is retried with backoff until the startup timeout or a shutdown
+// signal; Elasticsearch and Kafka sit behind circuit breakers instead, so they may
+// come up later.
+func connect(cfg *config.Config) (*connections, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, cfg.Server.StartupTimeout)
+	defer cancel()
+
+	mongoClient, err := retry.Do(ctx, "MongoDB connection", func(ctx context.Context) (*mongo.Client, error) {
+		return clients.NewMongoClient(ctx, cfg.Mongo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Connected to MongoDB", "database", cfg.Mongo.Database)
+
+	elasticClient, err := clients.NewElasticClient(cfg.Elastic)
+	if err != nil {
+		mongoClient.Disconnect(context.Background())
+		return nil, err
+	}
+	writer := clients.NewKafkaWriter(cfg.Kafka)
+
+	return &connections{
+		mongo:   mongoClient,
+		elastic: elasticClient,
+		kafka:   writer,
+		store:   repositories.New(mongoClient.Database(cfg.Mongo.Database), elasticClient),
+		health: health.New(health.Dependencies{
+			Mongo:       mongoClient,
+			Elastic:     elasticClient,
+			KafkaBroker: cfg.Kafka.Broker,
+			Storage:     storage.NewLocal(cfg.Storage.UploadDir),
+		}, cfg.Health.CheckTimeout),
+	}, nil
+}
+
+// shutdown stops the server in dependency order within timeout: disconnect realtime
+// clients, drain in-flight requests, stop the workers, let background uploads and
+// event handlers finish, then flush Kafka and close the clients
+func shutdown(app *fiber.App, timeout time.Duration, stopWorkers context.CancelFunc, workers *sync.WaitGroup, bus websocket.Bus, conns *connections, comps *components) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Realtime streams never finish on their own, so end them before draining
+	if err := comps.hub.Shutdown(ctx); err != nil {
+		slog.Warn("Realtime clients did not disconnect in time", "error", err)
+	}
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		slog.Warn("In-flight requests did not finish in time", "error", err)
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Background workers did not stop in time")
+	}
+
+	if err := comps.pipeline.Wait(ctx); err != nil {
+		slog.Warn("Uploads still being processed were abandoned", "error", err)
+	}
+	if err := comps.events.Wait(ctx); err != nil {
+		slog.Warn("Event handlers still running were abandoned", "error", err)
+	}
 
-	app.Listen(":3008")
+	if err := conns.kafka.Close(); err != nil {
+		slog.Error("Error flushing Kafka writer", "error", err)
+	}
+	if bus != nil {
+		if err := bus.Close(); err != nil {
+			slog.Error("Error closing hub bus", "error", err)
+		}
+	}
+	if err := comps.limiter.Close(); err != nil {
+		slog.Error("Error closing rate limit store", "error", err)
+	}
+	if err := conns.mongo.Disconnect(ctx); err != nil {
+		slog.Error("Error disconnecting from MongoDB", "error", err)
+	}
+	slog.Info("Shutdown complete")
 }
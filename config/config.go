@@ -1,22 +1,599 @@
+// Package config loads and validates the application's settings. It depends on no
+// other package of the application; each component takes its typed section of Config.
+//
+// Settings are layered, later layers winning: built-in defaults, a YAML or TOML file
+// (CONFIG_FILE, or config.yaml, config.yml or config.toml in the working directory),
+// a .env file, then environment variables. Load validates the result so a bad setting
+// stops the process at startup instead of surfacing on the first request.
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// LoadEnv loads environment variables from .env file if present
-func LoadEnv() {
-	if _, err := os.Stat(".env"); err == nil {
-		if err := loadDotEnv(); err != nil {
-			log.Printf("Could not load .env: %v", err)
+// Config holds every setting of the server. Each field names the environment variable
+// that overrides it in its env tag.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Mongo     MongoConfig     `yaml:"mongo"`
+	Elastic   ElasticConfig   `yaml:"elasticsearch"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Keys      KeysConfig      `yaml:"keys"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Health    HealthConfig    `yaml:"health"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Quota     QuotaConfig     `yaml:"quota"`
+	Hub       HubConfig       `yaml:"hub"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+}
+
+// ServerConfig configures the HTTP listener, how long startup keeps retrying MongoDB
+// and how long shutdown waits for work to drain. Behind a reverse proxy, ProxyHeader
+// names a header the proxy overwrites with the client address (e.g. X-Real-IP) and
+// TrustedProxies lists the proxy addresses or CIDRs allowed to set it; without them
+// every request appears to come from the proxy.
+type ServerConfig struct {
+	Host            string        `yaml:"host" env:"HOST"`
+	Port            int           `yaml:"port" env:"PORT"`
+	ProxyHeader     string        `yaml:"proxy_header" env:"PROXY_HEADER"`
+	TrustedProxies  string        `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	StartupTimeout  time.Duration `yaml:"startup_timeout" env:"STARTUP_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// MongoConfig locates the MongoDB deployment and database
+type MongoConfig struct {
+	URI      string `yaml:"uri" env:"MONGO_URI"`
+	Database string `yaml:"database" env:"MONGO_DATABASE"`
+}
+
+// ElasticConfig locates the Elasticsearch cluster
+type ElasticConfig struct {
+	URL string `yaml:"url" env:"ELASTIC_URL"`
+}
+
+// KafkaConfig locates the Kafka cluster and the indexing topic
+type KafkaConfig struct {
+	Broker string `yaml:"broker" env:"KAFKA_BROKER"`
+	Topic  string `yaml:"topic" env:"KAFKA_TOPIC"`
+}
+
+// StorageConfig configures blob storage
+type StorageConfig struct {
+	UploadDir string `yaml:"upload_dir" env:"UPLOAD_DIR"`
+}
+
+// AuthConfig lists the API tokens as token=user:tenant[:role] entries, comma separated
+type AuthConfig struct {
+	Tokens string `yaml:"tokens" env:"AUTH_TOKENS"`
+}
+
+// KeysConfig configures encryption at rest and signed download URLs
+type KeysConfig struct {
+	MasterKeyFile     string `yaml:"master_key_file" env:"MASTER_KEY_FILE"`
+	DownloadURLSecret string `yaml:"download_url_secret" env:"DOWNLOAD_URL_SECRET"`
+}
+
+// LogConfig configures structured logging and log file rotation
+type LogConfig struct {
+	Level          string        `yaml:"level" env:"LOG_LEVEL"`
+	Output         string        `yaml:"output" env:"LOG_OUTPUT"`
+	File           string        `yaml:"file" env:"LOG_FILE"`
+	MaxSizeMB      int64         `yaml:"max_size_mb" env:"LOG_MAX_SIZE_MB"`
+	MaxBackups     int           `yaml:"max_backups" env:"LOG_MAX_BACKUPS"`
+	RotateInterval time.Duration `yaml:"rotate_interval" env:"LOG_ROTATE_INTERVAL"`
+	Compress       bool          `yaml:"compress" env:"LOG_COMPRESS"`
+}
+
+// TracingConfig picks the trace exporter. The OTLP exporter itself is configured by
+// the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	File        string `yaml:"file" env:"OTEL_TRACES_FILE"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// MetricsConfig guards the Prometheus endpoint
+type MetricsConfig struct {
+	Token string `yaml:"token" env:"METRICS_TOKEN"`
+}
+
+// HealthConfig tunes the readiness probe
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+// RateLimitConfig sets the per-caller budgets as "<requests>/<period>[:<burst>]" and
+// where buckets are kept
+type RateLimitConfig struct {
+	Store         string  `yaml:"store" env:"RATE_LIMIT_STORE"`
+	RedisAddr     string  `yaml:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword string  `yaml:"redis_password" env:"REDIS_PASSWORD"`
+	TenantFactor  float64 `yaml:"tenant_factor" env:"RATE_LIMIT_TENANT_FACTOR"`
+	Read          string  `yaml:"read" env:"RATE_LIMIT_READ"`
+	Upload        string  `yaml:"upload" env:"RATE_LIMIT_UPLOAD"`
+	Search        string  `yaml:"search" env:"RATE_LIMIT_SEARCH"`
+}
+
+// QuotaConfig sets the default storage quota, per-tenant overrides as
+// "id=bytes:docs" entries, comma separated, and how often usage is reconciled
+type QuotaConfig struct {
+	MaxBytes          int64         `yaml:"max_bytes" env:"QUOTA_MAX_BYTES"`
+	MaxDocuments      int64         `yaml:"max_documents" env:"QUOTA_MAX_DOCUMENTS"`
+	TenantOverrides   string        `yaml:"tenant_overrides" env:"QUOTA_TENANT_OVERRIDES"`
+	ReconcileInterval time.Duration `yaml:"reconcile_interval" env:"QUOTA_RECONCILE_INTERVAL"`
+}
+
+// HubConfig tunes the realtime hub and how replicas share it. Bus is memory when one
+// replica serves every client, or kafka to relay events between replicas. Replay on
+// reconnect only works when the load balancer keeps each client on one replica.
+type HubConfig struct {
+	Bus                   string `yaml:"bus" env:"HUB_BUS"`
+	BusTopic              string `yaml:"bus_topic" env:"HUB_BUS_TOPIC"`
+	ReplicaID             string `yaml:"replica_id" env:"REPLICA_ID"`
+	MaxConnections        int    `yaml:"max_connections" env:"HUB_MAX_CONNECTIONS"`
+	MaxConnectionsPerUser int    `yaml:"max_connections_per_user" env:"HUB_MAX_CONNECTIONS_PER_USER"`
+	SendBuffer            int    `yaml:"send_buffer" env:"HUB_SEND_BUFFER"`
+	SlowConsumerPolicy    string `yaml:"slow_consumer_policy" env:"HUB_SLOW_CONSUMER_POLICY"`
+	ReplayBuffer          int    `yaml:"replay_buffer" env:"HUB_REPLAY_BUFFER"`
+}
+
+// WebhooksConfig bounds webhook delivery retries and how many deliveries each replica
+// sends at once. AllowInternal lets webhooks target loopback and private addresses,
+// which is only safe when every tenant is trusted.
+type WebhooksConfig struct {
+	Workers       int  `yaml:"workers" env:"WEBHOOK_WORKERS"`
+	MaxAttempts   int  `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	DisableAfter  int  `yaml:"disable_after" env:"WEBHOOK_DISABLE_AFTER"`
+	AllowInternal bool `yaml:"allow_internal" env:"WEBHOOK_ALLOW_INTERNAL"`
+}
+
+// SMTPConfig configures digest email; without an address emails are only logged
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" env:"SMTP_ADDR"`
+	From     string `yaml:"from" env:"SMTP_FROM"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+}
+
+// TenantQuota is one QUOTA_TENANT_OVERRIDES entry
+type TenantQuota struct {
+	MaxBytes     int64
+	MaxDocuments int64
+}
+
+// Defaults returns the settings used when nothing overrides them
+func Defaults() *Config {
+	return &Config{
+		Server:  ServerConfig{Port: 3000, StartupTimeout: 2 * time.Minute, ShutdownTimeout: 30 * time.Second},
+		Mongo:   MongoConfig{URI: "mongodb://localhost:27017", Database: "testdb"},
+		Elastic: ElasticConfig{URL: "http://localhost:9200"},
+		Kafka:   KafkaConfig{Broker: "localhost:9092", Topic: "elastic"},
+		Storage: StorageConfig{UploadDir: "./uploads"},
+		Keys:    KeysConfig{MasterKeyFile: "./keys/master.key"},
+		Log: LogConfig{
+			Level:          "info",
+			Output:         "file",
+			File:           "logs/app.log",
+			MaxSizeMB:      10,
+			MaxBackups:     7,
+			RotateInterval: 24 * time.Hour,
+			Compress:       true,
+		},
+		Tracing: TracingConfig{Exporter: "none", File: "./logs/traces.json", ServiceName: "upload-document-saas"},
+		Health:  HealthConfig{CheckTimeout: 2 * time.Second},
+		RateLimit: RateLimitConfig{
+			Store:        "memory",
+			RedisAddr:    "localhost:6379",
+			TenantFactor: 10,
+			Read:         "600/1m:120",
+			Upload:       "30/1m:10",
+			Search:       "120/1m:30",
+		},
+		Quota: QuotaConfig{MaxBytes: 10 << 30, MaxDocuments: 100000, ReconcileInterval: 6 * time.Hour},
+		Hub: HubConfig{
+			Bus:                   "memory",
+			BusTopic:              "hub-events",
+			MaxConnections:        10000,
+			MaxConnectionsPerUser: 10,
+			SendBuffer:            256,
+			SlowConsumerPolicy:    "drop_oldest",
+			ReplayBuffer:          256,
+		},
+		Webhooks: WebhooksConfig{Workers: 8, MaxAttempts: 8, DisableAfter: 20},
+		SMTP:     SMTPConfig{From: "notifications@localhost"},
+	}
+}
+
+// Load builds the configuration from defaults, the config file at path (or the one
+// CONFIG_FILE or the working directory provides when path is empty), .env and the
+// environment, and validates it
+func Load(path string) (*Config, error) {
+	cfg := Defaults()
+
+	// .env comes first so that it can name the config file; its values only take
+	// effect where the real environment is silent
+	if err := loadDotEnv(".env"); err != nil {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		for _, candidate := range []string{"config.yaml", "config.yml", "config.toml"} {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides every field that has an env tag with its variable, when set and non-empty
+func applyEnv(v reflect.Value) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			if err := applyEnv(value); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		name := field.Tag.Get("env")
+		raw := os.Getenv(name)
+		if name == "" || raw == "" {
+			continue
+		}
+		if err := setField(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
 		}
 	}
+	return errors.Join(errs...)
 }
 
-// loadDotEnv loads .env (helper for LoadEnv)
-func loadDotEnv() error {
-	// Use os package to load .env manually (if you want to avoid external deps)
-	// Or use github.com/joho/godotenv for robust loading
+func setField(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
 	return nil
 }
+
+// Validate reports every invalid setting at once, naming both its file key and its
+// environment variable
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(key, env, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s (%s): %s", key, env, fmt.Sprintf(format, args...)))
+	}
+	positive := func(key, env string, n int64) {
+		if n <= 0 {
+			bad(key, env, "must be positive, got %d", n)
+		}
+	}
+	oneOf := func(key, env, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		bad(key, env, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+	httpURL := func(key, env, value string) {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad(key, env, "must be an http or https URL, got %q", value)
+		}
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		bad("server.port", "PORT", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	for _, proxy := range c.Server.Proxies() {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				bad("server.trusted_proxies", "TRUSTED_PROXIES", "%q is neither an IP address nor a CIDR", proxy)
+			}
+		}
+	}
+	if (c.Server.ProxyHeader == "") != (len(c.Server.Proxies()) == 0) {
+		bad("server.proxy_header", "PROXY_HEADER", "must be set together with server.trusted_proxies (TRUSTED_PROXIES)")
+	}
+	positive("server.startup_timeout", "STARTUP_TIMEOUT", int64(c.Server.StartupTimeout))
+	positive("server.shutdown_timeout", "SHUTDOWN_TIMEOUT", int64(c.Server.ShutdownTimeout))
+
+	if !strings.HasPrefix(c.Mongo.URI, "mongodb://") && !strings.HasPrefix(c.Mongo.URI, "mongodb+srv://") {
+		bad("mongo.uri", "MONGO_URI", "must start with mongodb:// or mongodb+srv://, got %q", c.Mongo.URI)
+	}
+	if c.Mongo.Database == "" || strings.ContainsAny(c.Mongo.Database, `/\. "$`) {
+		bad("mongo.database", "MONGO_DATABASE", "must be a valid database name, got %q", c.Mongo.Database)
+	}
+	httpURL("elasticsearch.url", "ELASTIC_URL", c.Elastic.URL)
+	if c.Kafka.Broker == "" {
+		bad("kafka.broker", "KAFKA_BROKER", "is required")
+	}
+	if c.Kafka.Topic == "" {
+		bad("kafka.topic", "KAFKA_TOPIC", "is required")
+	}
+	if c.Storage.UploadDir == "" {
+		bad("storage.upload_dir", "UPLOAD_DIR", "is required")
+	}
+	// There is deliberately no default token: a built-in one would be public knowledge
+	if strings.TrimSpace(c.Auth.Tokens) == "" {
+		bad("auth.tokens", "AUTH_TOKENS", "is required; set at least one token=user:tenant[:role] entry")
+	} else if _, err := c.Auth.Entries(); err != nil {
+		bad("auth.tokens", "AUTH_TOKENS", "%v", err)
+	}
+	// Without a token /metrics trusts private client addresses, which only identify the
+	// scraper when the server is reached directly or through a trusted proxy
+	if c.Metrics.Token == "" && !c.Server.Loopback() && c.Server.ProxyHeader == "" {
+		bad("metrics.token", "METRICS_TOKEN", "is required unless server.host is loopback or server.trusted_proxies is set")
+	}
+	if c.Keys.MasterKeyFile == "" {
+		bad("keys.master_key_file", "MASTER_KEY_FILE", "is required")
+	}
+	// Without a secret each replica signs with its own ephemeral key, which only suits a
+	// single local process
+	if s := c.Keys.DownloadURLSecret; s == "" && (c.Hub.Bus == "kafka" || !c.Server.Loopback()) {
+		bad("keys.download_url_secret", "DOWNLOAD_URL_SECRET", "is required unless server.host is loopback and hub.bus is memory")
+	} else if s != "" && len(s) < 32 {
+		bad("keys.download_url_secret", "DOWNLOAD_URL_SECRET", "must be at least 32 characters")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		bad("log.level", "LOG_LEVEL", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	oneOf("log.output", "LOG_OUTPUT", c.Log.Output, "file", "stdout", "both")
+	if c.Log.Output != "stdout" && c.Log.File == "" {
+		bad("log.file", "LOG_FILE", "is required when logging to a file")
+	}
+	if c.Log.MaxSizeMB < 0 {
+		bad("log.max_size_mb", "LOG_MAX_SIZE_MB", "must not be negative, got %d", c.Log.MaxSizeMB)
+	}
+	if c.Log.MaxBackups < 0 {
+		bad("log.max_backups", "LOG_MAX_BACKUPS", "must not be negative, got %d", c.Log.MaxBackups)
+	}
+	if c.Log.RotateInterval < 0 {
+		bad("log.rotate_interval", "LOG_ROTATE_INTERVAL", "must not be negative, got %s", c.Log.RotateInterval)
+	}
+
+	oneOf("tracing.exporter", "OTEL_TRACES_EXPORTER", c.Tracing.Exporter, "none", "otlp", "stdout", "file")
+	if c.Tracing.Exporter == "file" && c.Tracing.File == "" {
+		bad("tracing.file", "OTEL_TRACES_FILE", "is required with the file exporter")
+	}
+	positive("health.check_timeout", "HEALTH_CHECK_TIMEOUT", int64(c.Health.CheckTimeout))
+
+	oneOf("rate_limit.store", "RATE_LIMIT_STORE", c.RateLimit.Store, "memory", "redis")
+	if c.RateLimit.Store == "redis" && c.RateLimit.RedisAddr == "" {
+		bad("rate_limit.redis_addr", "REDIS_ADDR", "is required with the redis store")
+	}
+	if c.RateLimit.TenantFactor < 1 {
+		bad("rate_limit.tenant_factor", "RATE_LIMIT_TENANT_FACTOR", "must be at least 1, got %g", c.RateLimit.TenantFactor)
+	}
+	for budget, spec := range c.RateLimit.Budgets() {
+		if _, err := ParseRate(spec); err != nil {
+			bad("rate_limit."+budget, "RATE_LIMIT_"+strings.ToUpper(budget), "%v", err)
+		}
+	}
+
+	if c.Quota.MaxBytes < 0 {
+		bad("quota.max_bytes", "QUOTA_MAX_BYTES", "must not be negative, got %d", c.Quota.MaxBytes)
+	}
+	if c.Quota.MaxDocuments < 0 {
+		bad("quota.max_documents", "QUOTA_MAX_DOCUMENTS", "must not be negative, got %d", c.Quota.MaxDocuments)
+	}
+	if _, err := c.Quota.Overrides(); err != nil {
+		bad("quota.tenant_overrides", "QUOTA_TENANT_OVERRIDES", "%v", err)
+	}
+	positive("quota.reconcile_interval", "QUOTA_RECONCILE_INTERVAL", int64(c.Quota.ReconcileInterval))
+
+	oneOf("hub.bus", "HUB_BUS", c.Hub.Bus, "memory", "kafka")
+	if c.Hub.Bus == "kafka" && c.Hub.BusTopic == "" {
+		bad("hub.bus_topic", "HUB_BUS_TOPIC", "is required with the kafka bus")
+	}
+	positive("hub.max_connections", "HUB_MAX_CONNECTIONS", int64(c.Hub.MaxConnections))
+	positive("hub.max_connections_per_user", "HUB_MAX_CONNECTIONS_PER_USER", int64(c.Hub.MaxConnectionsPerUser))
+	positive("hub.send_buffer", "HUB_SEND_BUFFER", int64(c.Hub.SendBuffer))
+	positive("hub.replay_buffer", "HUB_REPLAY_BUFFER", int64(c.Hub.ReplayBuffer))
+	oneOf("hub.slow_consumer_policy", "HUB_SLOW_CONSUMER_POLICY", c.Hub.SlowConsumerPolicy, "drop_oldest", "disconnect")
+
+	positive("webhooks.workers", "WEBHOOK_WORKERS", int64(c.Webhooks.Workers))
+	positive("webhooks.max_attempts", "WEBHOOK_MAX_ATTEMPTS", int64(c.Webhooks.MaxAttempts))
+	positive("webhooks.disable_after", "WEBHOOK_DISABLE_AFTER", int64(c.Webhooks.DisableAfter))
+	if c.SMTP.Addr != "" && c.SMTP.From == "" {
+		bad("smtp.from", "SMTP_FROM", "is required when smtp.addr is set")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Addr is the address the HTTP server listens on
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Loopback reports whether the server only listens on a loopback address
+func (s ServerConfig) Loopback() bool {
+	if s.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(s.Host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Proxies splits TrustedProxies into its addresses and CIDRs
+func (s ServerConfig) Proxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(s.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// Budgets returns the rate limit spec of each budget by name
+func (r RateLimitConfig) Budgets() map[string]string {
+	return map[string]string{
+		"read":   r.Read,
+		"upload": r.Upload,
+		"search": r.Search,
+	}
+}
+
+// Rate is a parsed rate limit spec: Requests per Period, in bursts of at most Burst
+type Rate struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseRate parses "<requests>/<period>[:<burst>]", e.g. "600/1m:120". The burst
+// defaults to the request count.
+func ParseRate(s string) (Rate, error) {
+	spec, burstStr, hasBurst := strings.Cut(s, ":")
+	countStr, periodStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate limit %q must look like 600/1m:120", s)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Rate{}, fmt.Errorf("invalid request count in %q", s)
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return Rate{}, fmt.Errorf("invalid period in %q", s)
+	}
+	burst := count
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst <= 0 {
+			return Rate{}, fmt.Errorf("invalid burst in %q", s)
+		}
+	}
+	return Rate{Requests: count, Period: period, Burst: burst}, nil
+}
+
+// Token is one entry of AuthConfig.Tokens: an API token and who it authenticates
+type Token struct {
+	Token    string
+	UserID   string
+	TenantID string
+	Role     string
+}
+
+// Entries parses Tokens, a comma separated list of token=user:tenant[:role] entries.
+// The role defaults to user; the tenant ID is checked by the auth package.
+func (a AuthConfig) Entries() ([]Token, error) {
+	var entries []Token
+	for i, entry := range strings.Split(a.Tokens, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		token, spec, ok := strings.Cut(entry, "=")
+		parts := strings.Split(spec, ":")
+		if !ok || token == "" || len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("entry %d must look like token=user:tenant[:role]", i+1)
+		}
+		t := Token{Token: token, UserID: parts[0], TenantID: parts[1], Role: "user"}
+		if len(parts) > 2 && parts[2] != "" {
+			t.Role = parts[2]
+		}
+		if t.Role != "user" && t.Role != "admin" {
+			return nil, fmt.Errorf("entry %d has an unknown role %q", i+1, t.Role)
+		}
+		entries = append(entries, t)
+	}
+	return entries, nil
+}
+
+// Overrides parses TenantOverrides, e.g. "acme=53687091200:500000,beta=0:1000".
+// Either number may be left empty to keep the default.
+func (q QuotaConfig) Overrides() (map[string]TenantQuota, error) {
+	overrides := map[string]TenantQuota{}
+	for _, entry := range strings.Split(q.TenantOverrides, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, spec, ok := strings.Cut(entry, "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("entry %q must look like tenant=bytes:docs", entry)
+		}
+		bytes, docs, _ := strings.Cut(spec, ":")
+		limits := TenantQuota{MaxBytes: q.MaxBytes, MaxDocuments: q.MaxDocuments}
+		for _, part := range []struct {
+			raw string
+			dst *int64
+		}{{bytes, &limits.MaxBytes}, {docs, &limits.MaxDocuments}} {
+			if part.raw == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(part.raw, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("entry %q has an invalid limit %q", entry, part.raw)
+			}
+			*part.dst = n
+		}
+		overrides[id] = limits
+	}
+	return overrides, nil
+}
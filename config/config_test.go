@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inDir runs the rest of the test in dir, where Load looks for .env and config files
+func inDir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// validConfig returns defaults that pass Validate
+func validConfig() *Config {
+	cfg := Defaults()
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Auth.Tokens = "admin-token=alice:acme:admin"
+	cfg.Keys.MasterKeyFile = "master.keys"
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	// Each setting is given by every source up to the one that should win
+	unsetEnv(t, "CONFIG_FILE", "HOST", "AUTH_TOKENS", "MASTER_KEY_FILE", "MONGO_DATABASE", "KAFKA_TOPIC", "UPLOAD_DIR", "PORT")
+	dir := t.TempDir()
+	inDir(t, dir)
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("config.yaml", `
+server:
+  host: 127.0.0.1
+  port: 4000
+mongo:
+  database: filedb
+kafka:
+  topic: file-topic
+storage:
+  upload_dir: ./file-uploads
+auth:
+  tokens: admin-token=alice:acme:admin
+keys:
+  master_key_file: master.keys
+`)
+	writeFile(".env", "KAFKA_TOPIC=dotenv-topic\nUPLOAD_DIR=./dotenv-uploads\n")
+	t.Setenv("UPLOAD_DIR", "./env-uploads")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		source, setting, got, want string
+	}{
+		{"defaults", "mongo.uri", cfg.Mongo.URI, Defaults().Mongo.URI},
+		{"config.yaml", "mongo.database", cfg.Mongo.Database, "filedb"},
+		{".env", "kafka.topic", cfg.Kafka.Topic, "dotenv-topic"},
+		{"the environment", "storage.upload_dir", cfg.Storage.UploadDir, "./env-uploads"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q from %s", tt.setting, tt.got, tt.want, tt.source)
+		}
+	}
+	if cfg.Server.Port != 4000 {
+		t.Errorf("server.port = %d, want 4000 from config.yaml", cfg.Server.Port)
+	}
+}
+
+func TestLoadRejectsInvalidEnvironment(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "PORT")
+	inDir(t, t.TempDir())
+	t.Setenv("PORT", "eighty")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("err = %v, want an error naming PORT", err)
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = 0
+	cfg.Mongo.URI = "http://localhost:27017"
+	cfg.Auth.Tokens = ""
+	cfg.Log.Level = "loud"
+	cfg.Hub.Bus = "kafka"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config passed validation")
+	}
+	// Each problem names its file key and its environment variable
+	for _, want := range []string{
+		"server.port (PORT)",
+		"mongo.uri (MONGO_URI)",
+		"auth.tokens (AUTH_TOKENS)",
+		"log.level (LOG_LEVEL)",
+		"keys.download_url_secret (DOWNLOAD_URL_SECRET): is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not report %s:\n%v", want, err)
+		}
+	}
+	if n := len(strings.Split(err.Error(), "\n")); n != 5 {
+		t.Errorf("error reports %d problems, want 5:\n%v", n, err)
+	}
+}
+
+func TestValidateDownloadURLSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		ok     bool
+	}{
+		{"unset on loopback with the memory bus", func(*Config) {}, true},
+		{"unset on all interfaces", func(c *Config) { c.Server.Host = "" }, false},
+		{"unset with the kafka bus", func(c *Config) { c.Hub.Bus = "kafka" }, false},
+		{"too short", func(c *Config) { c.Keys.DownloadURLSecret = "short" }, false},
+		{"set on all interfaces", func(c *Config) {
+			c.Server.Host = ""
+			c.Metrics.Token = "metrics-token"
+			c.Keys.DownloadURLSecret = strings.Repeat("s", 32)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.change(cfg)
+			if err := cfg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile decodes the YAML (or JSON) or TOML file at path into cfg. Keys that match
+// no setting are errors, so typos do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	case ".toml":
+		// Decoding into YAML keeps the yaml tags the one source of key names and the
+		// known-field check below
+		var tables map[string]any
+		if _, err := toml.Decode(string(data), &tables); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(tables); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported config file type %q; use .yaml, .yml, .json or .toml", filepath.Ext(path))
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// loadDotEnv sets the KEY=VALUE pairs of the file at path as environment variables,
+// leaving any variable that is already set alone. A missing file is not an error.
+func loadDotEnv(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, raw, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value, err := dotEnvValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// dotEnvValue unquotes a .env value: double quotes allow escapes such as \n, single
+// quotes are literal, and an unquoted value ends at a " #" comment
+func dotEnvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := strings.LastIndex(raw, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strconv.Unquote(raw[:end+1])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.LastIndex(raw, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1:end], nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw), nil
+}
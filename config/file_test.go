@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+# TOML the old hand-rolled parser rejected: inline tables, multi-line strings,
+# escapes and trailing comments
+server = { port = 8080, shutdown_timeout = "45s" }
+
+[auth]
+tokens = """
+admin-token=alice:acme:admin,\
+reader-token=bob:acme"""
+
+[rate_limit]
+tenant_factor = 2.5   # per-tenant multiplier
+read = "100/1m"
+
+[webhooks]
+allow_internal = true
+`)
+	cfg := Defaults()
+	if err := loadFile(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 8080 || cfg.Server.ShutdownTimeout != 45*time.Second {
+		t.Errorf("server = %+v, want port 8080 and a 45s shutdown timeout", cfg.Server)
+	}
+	if cfg.Auth.Tokens != "admin-token=alice:acme:admin,reader-token=bob:acme" {
+		t.Errorf("auth.tokens = %q", cfg.Auth.Tokens)
+	}
+	if cfg.RateLimit.TenantFactor != 2.5 || cfg.RateLimit.Read != "100/1m" {
+		t.Errorf("rate_limit = %+v", cfg.RateLimit)
+	}
+	if !cfg.Webhooks.AllowInternal {
+		t.Error("webhooks.allow_internal was not read")
+	}
+	if cfg.Mongo.Database != Defaults().Mongo.Database {
+		t.Errorf("unset mongo.database changed to %q", cfg.Mongo.Database)
+	}
+}
+
+func TestLoadFileRejectsUnknownAndMalformedTOML(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":   "[server]\nprot = 8080\n",
+		"unknown table": "[sever]\nport = 8080\n",
+		"syntax error":  "[server\nport = 8080\n",
+		"wrong type":    "[server]\nport = \"eighty\"\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := loadFile(writeConfig(t, "config.toml", content), Defaults()); err == nil {
+				t.Errorf("loaded %q without error", content)
+			}
+		})
+	}
+}
+
+func TestLoadFileRejectsUnknownExtension(t *testing.T) {
+	err := loadFile(writeConfig(t, "config.ini", "port=1\n"), Defaults())
+	if err == nil || !strings.Contains(err.Error(), "unsupported config file type") {
+		t.Fatalf("err = %v, want an unsupported type error", err)
+	}
+}
+
+// unsetEnv clears keys for the test and restores their values afterwards, so that
+// variables loadDotEnv sets do not leak into other tests
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		old, had := os.LookupEnv(key)
+		os.Unsetenv(key)
+		t.Cleanup(func() {
+			if had {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "DOTENV_TEST=value\n", "value"},
+		{"spaces around", "  DOTENV_TEST = value  \n", "value"},
+		{"export", "export DOTENV_TEST=value\n", "value"},
+		{"trailing comment", "DOTENV_TEST=value # the value\n", "value"},
+		{"hash inside a value", "DOTENV_TEST=a#b\n", "a#b"},
+		{"comment lines", "# DOTENV_TEST=commented\n\nDOTENV_TEST=value\n", "value"},
+		{"double quotes", `DOTENV_TEST="two words # not a comment"` + "\n", "two words # not a comment"},
+		{"double quote escapes", `DOTENV_TEST="line\nbreak"` + "\n", "line\nbreak"},
+		{"single quotes are literal", `DOTENV_TEST='line\nbreak'` + "\n", `line\nbreak`},
+		{"empty", "DOTENV_TEST=\n", ""},
+		{"first of repeated keys", "DOTENV_TEST=first\nDOTENV_TEST=second\n", "first"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "DOTENV_TEST")
+			if err := loadDotEnv(writeConfig(t, ".env", tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := os.LookupEnv("DOTENV_TEST"); !ok || got != tt.want {
+				t.Errorf("DOTENV_TEST = %q (set %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnvKeepsTheEnvironment(t *testing.T) {
+	unsetEnv(t, "DOTENV_TEST", "DOTENV_OTHER")
+	t.Setenv("DOTENV_TEST", "from the environment")
+	if err := loadDotEnv(writeConfig(t, ".env", "DOTENV_TEST=from .env\nDOTENV_OTHER=from .env\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("DOTENV_TEST"); got != "from the environment" {
+		t.Errorf("DOTENV_TEST = %q, want the environment's value", got)
+	}
+	if got := os.Getenv("DOTENV_OTHER"); got != "from .env" {
+		t.Errorf("DOTENV_OTHER = %q, want the .env value", got)
+	}
+}
+
+func TestLoadDotEnvRejectsMalformedLines(t *testing.T) {
+	tests := map[string]string{
+		"no equals":           "DOTENV_TEST\n",
+		"no key":              "=value\n",
+		"space in key":        "DOTENV TEST=value\n",
+		"unterminated double": "DOTENV_TEST=\"value\n",
+		"unterminated single": "DOTENV_TEST='value\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			unsetEnv(t, "DOTENV_TEST")
+			if err := loadDotEnv(writeConfig(t, ".env", content)); err == nil {
+				t.Errorf("loaded %q without error", content)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnvWithoutAFile(t *testing.T) {
+	if err := loadDotEnv(filepath.Join(t.TempDir(), ".env")); err != nil {
+		t.Errorf("missing .env = %v, want no error", err)
+	}
+}
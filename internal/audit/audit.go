@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionDocumentUploaded   = "document.uploaded"
+	ActionDocumentViewed     = "document.viewed"
+	ActionDocumentMoved      = "document.moved"
+	ActionDocumentDeleted    = "document.deleted"
+	ActionDocumentPurged     = "document.purged"
+	ActionDocumentVersioned  = "document.version_uploaded"
+	ActionCommentCreated     = "comment.created"
+	ActionDocumentDownloaded = "document.downloaded"
+	ActionDownloadURLCreated = "document.download_url_created"
+	ActionFolderCreated      = "folder.created"
+	ActionShareCreated       = "share.created"
+	ActionShareRevoked       = "share.revoked"
+	ActionAuditLogExported   = "audit.exported"
+	ActionAuditChainVerified = "audit.verified"
+	ActionKeysRotated        = "keys.rotated"
+	ActionUsageReconciled    = "usage.reconciled"
+	ActionWebhookCreated     = "webhook.created"
+	ActionWebhookUpdated     = "webhook.updated"
+	ActionWebhookDeleted     = "webhook.deleted"
+	ActionWebhookRedelivered = "webhook.redelivered"
+)
+
+// Actor types
+const (
+	ActorTypeUser      = "user"
+	ActorTypeShareLink = "share_link"
+	ActorTypeSignedURL = "signed_url"
+)
+
+// Target types
+const (
+	TargetDocument  = "document"
+	TargetFolder    = "folder"
+	TargetShareLink = "share_link"
+	TargetAuditLog  = "audit_log"
+	TargetTenantKey = "tenant_key"
+	TargetWebhook   = "webhook"
+	TargetTenant    = "tenant"
+)
+
+// Entry describes one action to record. When ActorID is empty the
+// authenticated principal in the request context is used.
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	ActorID    string
+	ActorType  string
+	Details    map[string]string
+}
+
+// Log appends audit events to the store
+type Log struct {
+	store *repositories.Store
+}
+
+// New returns an audit log kept in store
+func New(store *repositories.Store) *Log {
+	return &Log{store: store}
+}
+
+// Record appends an audit event for the request in c to the tenant in ctx.
+// Failures are logged rather than surfaced so that auditing never breaks a request.
+func (l *Log) Record(ctx context.Context, c *fiber.Ctx, e Entry) {
+	if e.ActorID == "" {
+		if principal, ok := auth.FromContext(ctx); ok {
+			e.ActorID = principal.UserID
+			e.ActorType = ActorTypeUser
+		}
+	}
+	event := models.AuditEvent{
+		Timestamp:  time.Now(),
+		ActorID:    e.ActorID,
+		ActorType:  e.ActorType,
+		IP:         c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Details:    e.Details,
+	}
+	if err := l.store.AppendAuditEvent(ctx, &event); err != nil {
+		slog.ErrorContext(ctx, "Error writing audit event", "action", e.Action, "target_type", e.TargetType, "target_id", e.TargetID, "error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ErrInvalidToken is returned when a token does not resolve to a principal
+var ErrInvalidToken = errors.New("invalid authorization token")
+
+// Principal is the authenticated caller behind a request
+type Principal struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+	Role     string `json:"role"`
+}
+
+// IsAdmin reports whether the principal has the admin role
+func (p *Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal and its tenant
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	ctx = context.WithValue(ctx, ctxKey{}, p)
+	return tenant.WithTenant(ctx, p.TenantID)
+}
+
+// FromContext returns the principal stored in ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Tokens maps each accepted API token to its principal
+type Tokens struct {
+	principals map[string]Principal
+}
+
+// NewTokens builds the accepted API tokens from the auth config section
+func NewTokens(cfg config.AuthConfig) (*Tokens, error) {
+	entries, err := cfg.Entries()
+	if err != nil {
+		return nil, err
+	}
+	t := &Tokens{principals: make(map[string]Principal, len(entries))}
+	for i, e := range entries {
+		if !tenant.Valid(e.TenantID) {
+			return nil, fmt.Errorf("entry %d has an invalid tenant %q", i+1, e.TenantID)
+		}
+		t.principals[e.Token] = Principal{UserID: e.UserID, TenantID: e.TenantID, Role: e.Role}
+	}
+	return t, nil
+}
+
+// Authenticate resolves a raw token (with or without a "Bearer " prefix) to its principal
+func (t *Tokens) Authenticate(token string) (*Principal, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" || t == nil {
+		return nil, ErrInvalidToken
+	}
+	for candidate, p := range t.principals {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			principal := p
+			return &principal, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
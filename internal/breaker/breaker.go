@@ -0,0 +1,143 @@
+// Package breaker stops calling a dependency that keeps failing, so requests fail fast
+// and callers can fall back instead of piling up behind timeouts.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"UploadDocument-Saas/internal/metrics"
+)
+
+// ErrOpen is returned, wrapped with the breaker's name, while calls are being refused
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is where a breaker is in its cycle
+type State int
+
+const (
+	// Closed lets every call through
+	Closed State = iota
+	// HalfOpen lets one trial call through after the cooldown
+	HalfOpen
+	// Open refuses calls until the cooldown has passed
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+	return "closed"
+}
+
+// Breaker opens after Threshold consecutive failures and, once Cooldown has passed,
+// lets a single trial call decide whether to close again
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is running
+}
+
+// New returns a closed breaker named after the dependency it guards
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{name: name, threshold: threshold, cooldown: cooldown}
+	metrics.SetBreakerState(name, int(Closed))
+	return b
+}
+
+// Do calls fn unless the breaker is open and records its outcome. A call ended by its
+// context being cancelled or running out of time says nothing about the dependency, so
+// it counts as neither a success nor a failure.
+func (b *Breaker) Do(fn func() error) error {
+	if !b.allow() {
+		return fmt.Errorf("%s: %w", b.name, ErrOpen)
+	}
+	err := fn()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		b.release()
+		return err
+	}
+	b.record(err == nil)
+	return err
+}
+
+// State returns the breaker's state, reporting an open breaker whose cooldown has
+// passed as half-open
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(HalfOpen)
+		b.trial = true
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// release ends a call without recording an outcome, letting another trial through
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen {
+		b.trial = false
+	}
+}
+
+func (b *Breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen {
+		b.trial = false
+	}
+	if ok {
+		b.failures = 0
+		if b.state != Closed {
+			b.setState(Closed)
+			slog.Info("Circuit breaker closed", "dependency", b.name)
+		}
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.setState(Open)
+		slog.Warn("Circuit breaker opened", "dependency", b.name, "failures", b.failures, "cooldown", b.cooldown.String())
+	}
+}
+
+func (b *Breaker) setState(s State) {
+	b.state = s
+	metrics.SetBreakerState(b.name, int(s))
+}
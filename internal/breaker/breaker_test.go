@@ -0,0 +1,83 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+func fail(err error) func() error {
+	return func() error { return err }
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := New("test-open", 3, time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := b.Do(fail(errDown)); !errors.Is(err, errDown) {
+			t.Fatalf("call %d: err = %v, want %v", i, err, errDown)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("state = %s after 3 failures, want open", b.State())
+	}
+	if err := b.Do(fail(nil)); !errors.Is(err, ErrOpen) {
+		t.Fatalf("open breaker let a call through: err = %v", err)
+	}
+}
+
+func TestBreakerIgnoresContextErrors(t *testing.T) {
+	for _, ctxErr := range []error{context.Canceled, context.DeadlineExceeded} {
+		t.Run(ctxErr.Error(), func(t *testing.T) {
+			b := New("test-ctx", 2, time.Hour)
+			wrapped := fmt.Errorf("search: %w", ctxErr)
+
+			// Neither a failure...
+			for i := 0; i < 5; i++ {
+				_ = b.Do(fail(wrapped))
+			}
+			if b.State() != Closed {
+				t.Fatalf("state = %s after context errors only, want closed", b.State())
+			}
+
+			// ...nor a success that resets the failure count
+			_ = b.Do(fail(errDown))
+			_ = b.Do(fail(wrapped))
+			_ = b.Do(fail(errDown))
+			if b.State() != Open {
+				t.Fatalf("state = %s, want open: a context error reset the failure count", b.State())
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenTrial(t *testing.T) {
+	b := New("test-trial", 1, 10*time.Millisecond)
+	_ = b.Do(fail(errDown))
+	time.Sleep(20 * time.Millisecond)
+
+	// A trial ended by its context neither closes nor reopens the breaker, and frees
+	// the slot for the next trial
+	if err := b.Do(fail(context.DeadlineExceeded)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("trial was refused: %v", err)
+	}
+	if b.State() != HalfOpen {
+		t.Fatalf("state = %s after a timed-out trial, want half-open", b.State())
+	}
+	if err := b.Do(fail(nil)); err != nil {
+		t.Fatalf("second trial was refused: %v", err)
+	}
+	if b.State() != Closed {
+		t.Fatalf("state = %s after a successful trial, want closed", b.State())
+	}
+
+	_ = b.Do(fail(errDown))
+	time.Sleep(20 * time.Millisecond)
+	_ = b.Do(fail(errDown))
+	if b.State() != Open {
+		t.Fatalf("state = %s after a failed trial, want open", b.State())
+	}
+}
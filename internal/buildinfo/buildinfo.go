@@ -0,0 +1,46 @@
+// Package buildinfo reports the version and commit the binary was built from.
+//
+// Set them at link time:
+//
+//	go build -ldflags "-X UploadDocument-Saas/internal/buildinfo.Version=1.4.0 \
+//	  -X UploadDocument-Saas/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X UploadDocument-Saas/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// Without them the commit falls back to the VCS revision Go stamps into the binary.
+package buildinfo
+
+import "runtime/debug"
+
+var (
+	// Version is the release version, "dev" unless set at link time
+	Version = "dev"
+	// Commit is the source revision
+	Commit = ""
+	// BuildTime is when the binary was built, RFC 3339
+	BuildTime = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+// Get returns the running build's details
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
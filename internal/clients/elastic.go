@@ -0,0 +1,26 @@
+// Package clients creates the connections to the backing services from their config
+// sections, instrumented for metrics and tracing.
+package clients
+
+import (
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/tracing"
+)
+
+// NewElasticClient returns a client for the Elasticsearch cluster of cfg. It does not
+// contact the cluster, so an unavailable cluster only degrades search.
+func NewElasticClient(cfg config.ElasticConfig) (*elasticsearch.Client, error) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{cfg.URL},
+		Transport: tracing.ElasticTransport(metrics.ElasticTransport(nil)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create Elasticsearch client: %w", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,31 @@
+package clients
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"UploadDocument-Saas/config"
+)
+
+// NewKafkaWriter returns a producer for the pipeline topic of cfg. Connections are made
+// on the first write.
+func NewKafkaWriter(cfg config.KafkaConfig) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:     kafka.TCP(cfg.Broker),
+		Topic:    cfg.Topic,
+		Balancer: &kafka.LeastBytes{},
+		// Fail fast so the pipeline can queue the document instead of holding the upload
+		MaxAttempts:  3,
+		WriteTimeout: 5 * time.Second,
+	}
+}
+
+// NewKafkaReader returns a consumer group reader on the pipeline topic of cfg
+func NewKafkaReader(cfg config.KafkaConfig, groupID string) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{cfg.Broker},
+		GroupID: groupID,
+		Topic:   cfg.Topic,
+	})
+}
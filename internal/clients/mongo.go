@@ -0,0 +1,37 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/tracing"
+)
+
+// mongoConnectTimeout bounds one connection attempt, including the ping
+const mongoConnectTimeout = 5 * time.Second
+
+// NewMongoClient connects to the MongoDB deployment of cfg and pings its primary, so
+// that an unreachable deployment is reported here rather than on the first query
+func NewMongoClient(ctx context.Context, cfg config.MongoConfig) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, mongoConnectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(cfg.URI).
+		SetServerSelectionTimeout(mongoConnectTimeout).
+		SetMonitor(tracing.MongoMonitor(metrics.MongoMonitor())))
+	if err != nil {
+		return nil, fmt.Errorf("connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("ping MongoDB: %w", err)
+	}
+	return client, nil
+}
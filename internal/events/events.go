@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// Document lifecycle event types
+const (
+	DocumentUploaded       = "document.uploaded"
+	DocumentMoved          = "document.moved"
+	DocumentDeleted        = "document.deleted"
+	DocumentVersionCreated = "document.version_created"
+	DocumentCommented      = "document.commented"
+)
+
+// Types lists every event type that may be emitted
+var Types = []string{DocumentUploaded, DocumentMoved, DocumentDeleted, DocumentVersionCreated, DocumentCommented}
+
+// DocumentEvent is the data of every document event
+type DocumentEvent struct {
+	Document     models.Document     `json:"document"`
+	FromFolderID *primitive.ObjectID `json:"from_folder_id,omitempty"` // document.moved only
+	ToFolderID   *primitive.ObjectID `json:"to_folder_id,omitempty"`   // document.moved only
+	Comment      *models.Comment     `json:"comment,omitempty"`        // document.commented only
+}
+
+// Event is something that happened in a tenant that other parts of the system react to
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	TenantID   string      `json:"tenant_id"`
+	ActorID    string      `json:"actor_id,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Handler reacts to an event. ctx carries the event's tenant and, when known, its actor.
+type Handler func(ctx context.Context, e Event)
+
+// Bus hands emitted events to its subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+	// running counts subscriber calls still in progress
+	running atomic.Int64
+}
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers h for every event emitted afterwards
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Emit records an event of eventType in the tenant carried by ctx and hands it to
+// every subscriber in the background, so request cancellation never drops it
+func (b *Bus) Emit(ctx context.Context, eventType string, data interface{}) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Dropping event without tenant", "type", eventType, "error", err)
+		return
+	}
+	e := Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		TenantID:   tenantID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+	if principal, ok := auth.FromContext(ctx); ok {
+		e.ActorID = principal.UserID
+	}
+
+	b.mu.RLock()
+	subscribers := append([]Handler(nil), b.handlers...)
+	b.mu.RUnlock()
+
+	detached := context.WithoutCancel(ctx)
+	for _, h := range subscribers {
+		b.running.Add(1)
+		go func(h Handler) {
+			defer b.running.Add(-1)
+			h(detached, e)
+		}(h)
+	}
+}
+
+// Wait blocks until every subscriber call for events emitted so far has returned, or
+// until ctx is done
+func (b *Bus) Wait(ctx context.Context) error {
+	for b.running.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// Known reports whether eventType is a type that may be emitted
+func Known(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newEventID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
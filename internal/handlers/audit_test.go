@@ -0,0 +1,22 @@
+package handlers
+
+import "testing"
+
+func TestAuditLimit(t *testing.T) {
+	tests := map[string]int64{
+		"":       defaultAuditLimit,
+		"abc":    defaultAuditLimit,
+		"0":      defaultAuditLimit,
+		"-5":     defaultAuditLimit,
+		"1":      1,
+		"250":    250,
+		"10000":  maxAuditExport,
+		"10001":  maxAuditExport,
+		"999999": maxAuditExport,
+	}
+	for raw, want := range tests {
+		if got := auditLimit(raw); got != want {
+			t.Errorf("auditLimit(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
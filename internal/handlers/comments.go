@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/websocket"
+)
+
+// maxCommentLength bounds a comment body, in characters
+const maxCommentLength = 4000
+
+// CreateComment adds a comment to a document
+func (h *Handler) CreateComment(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+	if utf8.RuneCountInString(req.Body) > maxCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment is too long",
+		})
+	}
+
+	ctx := c.UserContext()
+	document, err := h.store.GetDocument(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	comment := models.Comment{
+		DocumentID: id,
+		UserID:     currentPrincipal(c).UserID,
+		Body:       req.Body,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.store.InsertComment(ctx, &comment); err != nil {
+		return repoError(c, err, "")
+	}
+
+	commented := events.DocumentEvent{Document: document, Comment: &comment}
+	h.hub.Publish(document.TenantID, websocket.DocumentTopic(id), events.DocumentCommented, commented)
+	h.events.Emit(ctx, events.DocumentCommented, commented)
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionCommentCreated,
+		TargetType: audit.TargetDocument,
+		TargetID:   id.Hex(),
+		Details:    map[string]string{"comment_id": comment.ID.Hex()},
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"comment": comment,
+	})
+}
+
+// ListComments lists a document's comments, oldest first
+func (h *Handler) ListComments(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	ctx := c.UserContext()
+	if _, err := h.store.GetDocument(ctx, id); err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	comments, err := h.store.ListComments(ctx, id)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"comments": comments,
+	})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/signedurl"
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/pkg/logger"
+)
+
+const (
+	defaultDownloadTTL = 15 * time.Minute
+	maxDownloadTTL     = 24 * time.Hour
+)
+
+// CreateDownloadURL mints a signed, time-limited download URL for a document
+func (h *Handler) CreateDownloadURL(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	var req struct {
+		ExpiresIn int64 `json:"expires_in"` // seconds
+		BindIP    bool  `json:"bind_ip"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid JSON payload",
+			})
+		}
+	}
+	ttl := defaultDownloadTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > maxDownloadTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Download URLs may not outlive 24 hours",
+		})
+	}
+
+	document, err := h.store.GetDocument(c.UserContext(), id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+
+	claims := signedurl.Claims{
+		TenantID:   document.TenantID,
+		UserID:     currentPrincipal(c).UserID,
+		DocumentID: document.ID.Hex(),
+		Version:    document.Version,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	if req.BindIP {
+		claims.IP = c.IP()
+	}
+	h.audit.Record(c.UserContext(), c, audit.Entry{
+		Action:     audit.ActionDownloadURLCreated,
+		TargetType: audit.TargetDocument,
+		TargetID:   claims.DocumentID,
+		Details: map[string]string{
+			"version":    strconv.Itoa(claims.Version),
+			"expires_at": claims.ExpiresAt.UTC().Format(time.RFC3339),
+			"bound_ip":   claims.IP,
+		},
+	})
+
+	return c.JSON(fiber.Map{
+		"url":        "/download/" + claims.DocumentID + "?" + h.signer.Sign(claims).Encode(),
+		"expires_at": claims.ExpiresAt,
+		"version":    claims.Version,
+	})
+}
+
+// DownloadDocument verifies a signed download URL and streams the document from storage,
+// honouring single-range Range requests
+func (h *Handler) DownloadDocument(c *fiber.Ctx) error {
+	query, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid download URL",
+		})
+	}
+	claims, err := h.signer.Verify(c.Params("id"), query, c.IP(), time.Now())
+	if errors.Is(err, signedurl.ErrExpired) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": "Download URL has expired",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Invalid download URL",
+		})
+	}
+
+	id, err := primitive.ObjectIDFromHex(claims.DocumentID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	ctx := logger.With(c.UserContext(), "tenant_id", claims.TenantID)
+	ctx = tenant.WithTenant(ctx, claims.TenantID)
+	document, err := h.store.GetDocument(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	if document.Status == models.DocumentStatusQuarantined {
+		return quarantinedError(c)
+	}
+	// The URL names a version; superseded versions are kept and served from their own blob
+	name, storageKey, keyVersion, scanned := document.Name, document.StorageKey, document.KeyVersion, document.Scanned()
+	if document.Version != claims.Version {
+		version, err := h.store.GetDocumentVersion(ctx, id, claims.Version)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{
+				"error": "Document version is no longer available",
+			})
+		}
+		if err != nil {
+			return repoError(c, err, "")
+		}
+		name, storageKey, keyVersion, scanned = version.Name, version.StorageKey, version.KeyVersion, version.ScannedAt != nil
+	}
+	if !scanned {
+		return notScannedError(c)
+	}
+
+	obj, err := h.storage.Open(ctx, storageKey, keyVersion)
+	if err != nil {
+		return repoError(c, err, "File not found")
+	}
+
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentDownloaded,
+		TargetType: audit.TargetDocument,
+		TargetID:   claims.DocumentID,
+		ActorID:    claims.UserID,
+		ActorType:  audit.ActorTypeSignedURL,
+		Details:    map[string]string{"version": strconv.Itoa(claims.Version), "range": c.Get(fiber.HeaderRange)},
+	})
+
+	size := obj.Size()
+	c.Attachment(name)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`"%s-v%d"`, document.ID.Hex(), claims.Version))
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+
+	rangeHeader := c.Get(fiber.HeaderRange)
+	if rangeHeader == "" {
+		return c.SendStream(obj, int(size))
+	}
+	start, end, ok := parseByteRange(rangeHeader, size)
+	if !ok {
+		obj.Close()
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+	if _, err := obj.Seek(start, io.SeekStart); err != nil {
+		obj.Close()
+		return repoError(c, err, "")
+	}
+	length := end - start + 1
+	c.Status(fiber.StatusPartialContent)
+	c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	return c.SendStream(struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(obj, length), obj}, int(length))
+}
+
+// parseByteRange parses a single "bytes=" range against a body of the given size.
+// Multi-range requests are reported as unsatisfiable.
+func parseByteRange(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") || size == 0 {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if first == "" {
+		// Suffix range: the final N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
@@ -1,57 +1,103 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"mime/multipart"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/health"
+	"UploadDocument-Saas/internal/keys"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/notifications"
+	"UploadDocument-Saas/internal/pipeline"
+	"UploadDocument-Saas/internal/quota"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/signedurl"
+	"UploadDocument-Saas/internal/storage"
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/internal/webhooks"
+	"UploadDocument-Saas/internal/websocket"
 )
 
-// Document represents a document structure
-type Document struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Size        int64     `json:"size"`
-	Type        string    `json:"type"`
-	FolderID    int       `json:"folder_id"`
-	UploadedAt  time.Time `json:"uploaded_at"`
-	URL         string    `json:"url"`
+// Handler serves the API routes with the components they use
+type Handler struct {
+	store    *repositories.Store
+	audit    *audit.Log
+	events   *events.Bus
+	health   *health.Checker
+	keys     *keys.Manager
+	signer   *signedurl.Signer
+	storage  storage.Storage
+	quotas   *quota.Quotas
+	hub      *websocket.Hub
+	pipeline *pipeline.Pipeline
+	notifier *notifications.Notifier
+	webhooks *webhooks.Dispatcher
 }
 
-// Folder represents a folder structure
-type Folder struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	ParentID    *int      `json:"parent_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	DocumentCount int     `json:"document_count"`
+// Options are the components a Handler uses
+type Options struct {
+	Store    *repositories.Store
+	Audit    *audit.Log
+	Events   *events.Bus
+	Health   *health.Checker
+	Keys     *keys.Manager
+	Signer   *signedurl.Signer
+	Storage  storage.Storage
+	Quotas   *quota.Quotas
+	Hub      *websocket.Hub
+	Pipeline *pipeline.Pipeline
+	Notifier *notifications.Notifier
+	Webhooks *webhooks.Dispatcher
 }
 
-// Master represents master data structure
-type Master struct {
-	ID          int       `json:"id"`
-	Type        string    `json:"type"`
-	Value       string    `json:"value"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active"`
+// New returns a Handler using the components in opts
+func New(opts Options) *Handler {
+	return &Handler{
+		store:    opts.Store,
+		audit:    opts.Audit,
+		events:   opts.Events,
+		health:   opts.Health,
+		keys:     opts.Keys,
+		signer:   opts.Signer,
+		storage:  opts.Storage,
+		quotas:   opts.Quotas,
+		hub:      opts.Hub,
+		pipeline: opts.Pipeline,
+		notifier: opts.Notifier,
+		webhooks: opts.Webhooks,
+	}
 }
 
-// HealthCheck returns the health status of the application
-func HealthCheck(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"status":    "ok",
-		"timestamp": time.Now(),
-		"service":   "upload-document-saas",
-		"version":   "1.0.0",
-	})
+// Master represents master data structure
+type Master struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	IsActive    bool   `json:"is_active"`
 }
 
 // UploadDocument handles document upload
-func UploadDocument(c *fiber.Ctx) error {
+func (h *Handler) UploadDocument(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	principal := currentPrincipal(c)
+
 	// Parse multipart form
 	file, err := c.FormFile("document")
 	if err != nil {
@@ -60,13 +106,19 @@ func UploadDocument(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get folder ID from form (optional)
-	folderIDStr := c.FormValue("folder_id")
-	folderID := 1 // default folder
-	if folderIDStr != "" {
-		if id, err := strconv.Atoi(folderIDStr); err == nil {
-			folderID = id
+	// Get folder ID from form (optional, defaults to the tenant root)
+	folderID := primitive.NilObjectID
+	if folderIDStr := c.FormValue("folder_id"); folderIDStr != "" {
+		id, err := primitive.ObjectIDFromHex(folderIDStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid folder ID",
+			})
 		}
+		if _, err := h.store.GetFolder(ctx, id); err != nil {
+			return repoError(c, err, "Folder not found")
+		}
+		folderID = id
 	}
 
 	// Validate file
@@ -76,53 +128,100 @@ func UploadDocument(c *fiber.Ctx) error {
 		})
 	}
 
-	// Save file (in production, you'd save to cloud storage)
-	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-	uploadPath := fmt.Sprintf("./uploads/%s", filename)
-	
-	if err := c.SaveFile(file, uploadPath); err != nil {
-		log.Printf("Error saving file: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to save file",
+	tracker := h.pipeline.NewTracker(principal.TenantID, principal.UserID, uploadID(c))
+	tracker.Report(pipeline.StageReceived, 100)
+	document, err := h.storeDocument(ctx, file, folderID, tracker)
+	if err != nil {
+		return h.uploadError(c, ctx, err)
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentUploaded,
+		TargetType: audit.TargetDocument,
+		TargetID:   document.ID.Hex(),
+		Details:    map[string]string{"name": document.Name, "folder_id": folderID.Hex()},
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   "Document uploaded successfully",
+		"document":  document,
+		"upload_id": tracker.UploadID,
+	})
+}
+
+// GetDocumentByID retrieves a document by ID
+func (h *Handler) GetDocumentByID(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
 		})
 	}
 
-	// Create document record (in production, save to database)
-	document := Document{
-		ID:         int(time.Now().Unix()), // Simple ID generation
-		Name:       file.Filename,
-		Size:       file.Size,
-		Type:       filepath.Ext(file.Filename),
-		FolderID:   folderID,
-		UploadedAt: time.Now(),
-		URL:        fmt.Sprintf("/uploads/%s", filename),
+	document, err := h.store.GetDocument(c.UserContext(), id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":  "Document uploaded successfully",
+	return c.JSON(fiber.Map{
 		"document": document,
 	})
 }
 
-// GetDocumentByID retrieves a document by ID
-func GetDocumentByID(c *fiber.Ctx) error {
-	idParam := c.Params("id")
-	id, err := strconv.Atoi(idParam)
+// MoveDocument moves a document to another folder; {"folder_id": ""} moves it to the tenant root
+func (h *Handler) MoveDocument(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid document ID",
 		})
 	}
+	var req struct {
+		FolderID *string `json:"folder_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.FolderID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "folder_id is required",
+		})
+	}
+
+	ctx := c.UserContext()
+	folderID := primitive.NilObjectID
+	if *req.FolderID != "" {
+		if folderID, err = primitive.ObjectIDFromHex(*req.FolderID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid folder ID",
+			})
+		}
+		if _, err := h.store.GetFolder(ctx, folderID); err != nil {
+			return repoError(c, err, "Folder not found")
+		}
+	}
+
+	previous, err := h.store.MoveDocument(ctx, id, folderID)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	document := previous
+	document.FolderID = folderID
+	if previous.FolderID != folderID {
+		h.adjustFolderCount(ctx, previous.FolderID, -1)
+		h.adjustFolderCount(ctx, folderID, 1)
+		h.moveUsage(ctx, previous, folderID)
+		if err := h.store.UpdateDocumentIndex(ctx, id, map[string]interface{}{"folder_id": folderID.Hex()}); err != nil {
+			slog.ErrorContext(ctx, "Error updating search index for moved document", "document_id", id.Hex(), "error", err)
+		}
 
-	// In production, fetch from database
-	document := Document{
-		ID:         id,
-		Name:       "sample-document.pdf",
-		Size:       1024000,
-		Type:       ".pdf",
-		FolderID:   1,
-		UploadedAt: time.Now().Add(-24 * time.Hour),
-		URL:        "/uploads/sample-document.pdf",
+		moved := events.DocumentEvent{Document: document, FromFolderID: &previous.FolderID, ToFolderID: &folderID}
+		h.hub.Publish(document.TenantID, websocket.FolderTopic(previous.FolderID), events.DocumentMoved, moved)
+		h.hub.Publish(document.TenantID, websocket.FolderTopic(folderID), events.DocumentMoved, moved)
+		h.hub.Publish(document.TenantID, websocket.DocumentTopic(id), events.DocumentMoved, moved)
+		h.events.Emit(ctx, events.DocumentMoved, moved)
+		h.audit.Record(ctx, c, audit.Entry{
+			Action:     audit.ActionDocumentMoved,
+			TargetType: audit.TargetDocument,
+			TargetID:   id.Hex(),
+			Details:    map[string]string{"from_folder_id": previous.FolderID.Hex(), "to_folder_id": folderID.Hex()},
+		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -130,39 +229,137 @@ func GetDocumentByID(c *fiber.Ctx) error {
 	})
 }
 
+// DeleteDocument soft-deletes a document: it disappears from listings, search,
+// share links and downloads while its stored file is kept
+func (h *Handler) DeleteDocument(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	ctx := c.UserContext()
+	document, err := h.store.SoftDeleteDocument(ctx, id, currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	h.adjustFolderCount(ctx, document.FolderID, -1)
+	// The stored files stay until the document is purged, so only the count drops
+	h.quotas.Adjust(ctx, document.FolderID, 0, -1)
+	if err := h.store.DeleteDocumentIndex(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Error removing deleted document from search index", "document_id", id.Hex(), "error", err)
+	}
+
+	deleted := events.DocumentEvent{Document: document}
+	h.hub.Publish(document.TenantID, websocket.FolderTopic(document.FolderID), events.DocumentDeleted, deleted)
+	h.hub.Publish(document.TenantID, websocket.DocumentTopic(id), events.DocumentDeleted, deleted)
+	h.events.Emit(ctx, events.DocumentDeleted, deleted)
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentDeleted,
+		TargetType: audit.TargetDocument,
+		TargetID:   id.Hex(),
+		Details:    map[string]string{"name": document.Name, "folder_id": document.FolderID.Hex()},
+	})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PurgeDocument permanently removes a deleted document with its versions, comments and
+// stored files, releasing the storage they held
+func (h *Handler) PurgeDocument(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	ctx := c.UserContext()
+	document, err := h.store.PurgeDocument(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Deleted document not found")
+	}
+	versions, err := h.store.DeleteDocumentVersions(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting versions of purged document", "document_id", id.Hex(), "error", err)
+	}
+	if err := h.store.DeleteComments(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Error deleting comments of purged document", "document_id", id.Hex(), "error", err)
+	}
+
+	freed := document.Size
+	keys := []string{document.StorageKey}
+	for _, v := range versions {
+		freed += v.Size
+		keys = append(keys, v.StorageKey)
+	}
+	for _, key := range keys {
+		if err := h.storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(ctx, "Error deleting stored file of purged document", "document_id", id.Hex(), "error", err)
+		}
+	}
+	h.quotas.Adjust(ctx, document.FolderID, -freed, 0)
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentPurged,
+		TargetType: audit.TargetDocument,
+		TargetID:   id.Hex(),
+		Details:    map[string]string{"name": document.Name, "bytes": strconv.FormatInt(freed, 10)},
+	})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// moveUsage moves the storage held by document, including its superseded versions,
+// from its previous folder to folderID
+func (h *Handler) moveUsage(ctx context.Context, document models.Document, folderID primitive.ObjectID) {
+	bytes := document.Size
+	if versions, err := h.store.DocumentVersionBytes(ctx, document.ID); err != nil {
+		slog.ErrorContext(ctx, "Error sizing versions of moved document", "document_id", document.ID.Hex(), "error", err)
+	} else {
+		bytes += versions
+	}
+	h.quotas.Adjust(ctx, document.FolderID, -bytes, -1)
+	h.quotas.Adjust(ctx, folderID, bytes, 1)
+}
+
+// adjustFolderCount applies delta to a folder's document count; the tenant root has none
+func (h *Handler) adjustFolderCount(ctx context.Context, folderID primitive.ObjectID, delta int) {
+	if folderID.IsZero() {
+		return
+	}
+	if err := h.store.IncrementFolderDocumentCount(ctx, folderID, delta); err != nil {
+		slog.ErrorContext(ctx, "Error updating folder count", "folder_id", folderID.Hex(), "error", err)
+	}
+}
+
 // ListDocuments retrieves all documents with pagination
-func ListDocuments(c *fiber.Ctx) error {
+func (h *Handler) ListDocuments(c *fiber.Ctx) error {
 	// Get query parameters
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	folderID := c.Query("folder_id")
-
-	// Sample documents (in production, fetch from database)
-	documents := []Document{
-		{
-			ID:         1,
-			Name:       "document1.pdf",
-			Size:       1024000,
-			Type:       ".pdf",
-			FolderID:   1,
-			UploadedAt: time.Now().Add(-24 * time.Hour),
-			URL:        "/uploads/document1.pdf",
-		},
-		{
-			ID:         2,
-			Name:       "document2.docx",
-			Size:       512000,
-			Type:       ".docx",
-			FolderID:   1,
-			UploadedAt: time.Now().Add(-12 * time.Hour),
-			URL:        "/uploads/document2.docx",
-		},
+	page, _ := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
 	}
 
 	// Filter by folder if specified
-	if folderID != "" {
-		// In production, filter by folder_id in database query
-		log.Printf("Filtering by folder ID: %s", folderID)
+	filter := bson.M{}
+	if folderID := c.Query("folder_id"); folderID != "" {
+		id, err := primitive.ObjectIDFromHex(folderID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid folder ID",
+			})
+		}
+		filter["folder_id"] = id
+	}
+
+	documents, total, err := h.store.ListDocuments(c.UserContext(), filter, page, limit)
+	if err != nil {
+		return repoError(c, err, "")
 	}
 
 	return c.JSON(fiber.Map{
@@ -170,45 +367,121 @@ func ListDocuments(c *fiber.Ctx) error {
 		"pagination": fiber.Map{
 			"page":  page,
 			"limit": limit,
-			"total": len(documents),
+			"total": total,
 		},
 	})
 }
 
-// ListFolders retrieves all folders
-func ListFolders(c *fiber.Ctx) error {
-	// Sample folders (in production, fetch from database)
-	folders := []Folder{
-		{
-			ID:            1,
-			Name:          "Documents",
-			ParentID:      nil,
-			CreatedAt:     time.Now().Add(-7 * 24 * time.Hour),
-			DocumentCount: 5,
-		},
-		{
-			ID:            2,
-			Name:          "Images",
-			ParentID:      nil,
-			CreatedAt:     time.Now().Add(-5 * 24 * time.Hour),
-			DocumentCount: 3,
-		},
-		{
-			ID:            3,
-			Name:          "Contracts",
-			ParentID:      &[]int{1}[0],
-			CreatedAt:     time.Now().Add(-3 * 24 * time.Hour),
-			DocumentCount: 2,
+// SearchDocuments runs a full-text search over the caller's documents
+func (h *Handler) SearchDocuments(c *fiber.Ctx) error {
+	q := c.Query("q")
+	if q == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing search query",
+		})
+	}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  q,
+				"fields": []string{"name", "type", "content"},
+			},
 		},
 	}
 
+	var wg sync.WaitGroup
+	docsCh := make(chan models.Document)
+	errCh := make(chan error, 1)
+	wg.Add(1)
+	go h.store.SearchDocuments(c.UserContext(), query, &wg, docsCh, errCh)
+	go func() {
+		wg.Wait()
+		close(docsCh)
+	}()
+
+	documents := []models.Document{}
+	for doc := range docsCh {
+		documents = append(documents, doc)
+	}
+	select {
+	case err := <-errCh:
+		slog.ErrorContext(c.UserContext(), "Error searching documents", "error", err)
+		if errors.Is(err, repositories.ErrSearchUnavailable) {
+			c.Set(fiber.HeaderRetryAfter, "30")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Search is temporarily unavailable",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Search failed",
+		})
+	default:
+	}
+
+	return c.JSON(fiber.Map{
+		"documents": documents,
+	})
+}
+
+// ListFolders retrieves all folders
+func (h *Handler) ListFolders(c *fiber.Ctx) error {
+	folders, err := h.store.ListFolders(c.UserContext())
+	if err != nil {
+		return repoError(c, err, "")
+	}
+
 	return c.JSON(fiber.Map{
 		"folders": folders,
 	})
 }
 
+// CreateFolder creates a folder, optionally nested under a parent folder
+func (h *Handler) CreateFolder(c *fiber.Ctx) error {
+	var req struct {
+		Name     string `json:"name"`
+		ParentID string `json:"parent_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Folder name is required",
+		})
+	}
+
+	ctx := c.UserContext()
+	folder := models.Folder{
+		Name:      req.Name,
+		CreatedAt: time.Now(),
+	}
+	if req.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(req.ParentID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid parent folder ID",
+			})
+		}
+		if _, err := h.store.GetFolder(ctx, parentID); err != nil {
+			return repoError(c, err, "Parent folder not found")
+		}
+		folder.ParentID = &parentID
+	}
+
+	if err := h.store.InsertFolder(ctx, &folder); err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionFolderCreated,
+		TargetType: audit.TargetFolder,
+		TargetID:   folder.ID.Hex(),
+		Details:    map[string]string{"name": folder.Name},
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"folder": folder,
+	})
+}
+
 // ListMasters retrieves master data
-func ListMasters(c *fiber.Ctx) error {
+func (h *Handler) ListMasters(c *fiber.Ctx) error {
 	masterType := c.Query("type")
 
 	// Sample master data (in production, fetch from database)
@@ -253,7 +526,7 @@ func ListMasters(c *fiber.Ctx) error {
 }
 
 // SendKafkaTestMessage sends a test message to Kafka
-func SendKafkaTestMessage(c *fiber.Ctx) error {
+func (h *Handler) SendKafkaTestMessage(c *fiber.Ctx) error {
 	var payload map[string]interface{}
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -262,15 +535,99 @@ func SendKafkaTestMessage(c *fiber.Ctx) error {
 	}
 
 	// In production, send to Kafka
-	log.Printf("Kafka test message: %+v", payload)
+	slog.InfoContext(c.UserContext(), "Kafka test message", "payload", payload)
 
 	return c.JSON(fiber.Map{
-		"message": "Test message sent to Kafka",
-		"payload": payload,
+		"message":   "Test message sent to Kafka",
+		"payload":   payload,
 		"timestamp": time.Now(),
 	})
 }
 
+// storeDocument writes an uploaded file to storage and records it in the tenant
+// carried by ctx, then hands it to the background pipeline for scanning,
+// extraction and indexing. Progress is reported through tracker.
+func (h *Handler) storeDocument(ctx context.Context, file *multipart.FileHeader, folderID primitive.ObjectID, tracker pipeline.Tracker) (models.Document, error) {
+	var document models.Document
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return document, err
+	}
+	key, err := storage.NewKey(ctx, file.Filename)
+	if err != nil {
+		return document, err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return document, err
+	}
+	defer src.Close()
+
+	documentID := primitive.NewObjectID()
+	tracker.TenantID = tenantID
+	tracker.DocumentID = documentID.Hex()
+	if err := h.quotas.Reserve(ctx, folderID, file.Size, 1); err != nil {
+		tracker.Fail(pipeline.StageReceived, "storage quota exceeded")
+		return document, err
+	}
+	info, err := h.storage.Put(ctx, key, tracker.Reader(src, file.Size))
+	if err != nil {
+		h.quotas.Adjust(ctx, folderID, -file.Size, -1)
+		tracker.Fail(pipeline.StageStored, "could not store file")
+		return document, fmt.Errorf("save file: %w", err)
+	}
+
+	document = models.Document{
+		ID:         documentID,
+		TenantID:   tenantID,
+		Name:       file.Filename,
+		Size:       file.Size,
+		Type:       filepath.Ext(file.Filename),
+		FolderID:   folderID,
+		Version:    1,
+		StorageKey: key,
+		KeyVersion: info.KeyVersion,
+		Status:     models.DocumentStatusProcessing,
+		UploadedBy: tracker.UserID,
+		UploadedAt: time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	insertErr := make(chan error, 1)
+	wg.Add(1)
+	go h.store.InsertDocument(ctx, document, &wg, insertErr)
+	wg.Wait()
+	close(insertErr)
+
+	if err := <-insertErr; err != nil {
+		_ = h.storage.Delete(ctx, key)
+		h.quotas.Adjust(ctx, folderID, -file.Size, -1)
+		tracker.Fail(pipeline.StageStored, "could not record document")
+		return document, fmt.Errorf("save document record: %w", err)
+	}
+	tracker.Report(pipeline.StageStored, 100)
+	metrics.ObserveUpload("document", file.Size)
+	h.adjustFolderCount(ctx, folderID, 1)
+
+	h.hub.Publish(tenantID, websocket.FolderTopic(folderID), events.DocumentUploaded, document)
+	h.events.Emit(ctx, events.DocumentUploaded, events.DocumentEvent{Document: document})
+	h.pipeline.Process(ctx, pipeline.Job{Tracker: tracker, Document: document})
+	return document, nil
+}
+
+// uploadID returns the client-supplied upload ID used to key progress events,
+// generating one when the client did not send a usable value
+func uploadID(c *fiber.Ctx) string {
+	id := c.FormValue("upload_id")
+	if id == "" {
+		id = c.Get("X-Upload-ID")
+	}
+	if id == "" || len(id) > 64 || strings.ContainsAny(id, " \t\r\n") {
+		return primitive.NewObjectID().Hex()
+	}
+	return id
+}
+
 // validateFile validates uploaded file
 func validateFile(file *multipart.FileHeader) error {
 	// Check file size (10MB limit)
@@ -298,3 +655,55 @@ func validateFile(file *multipart.FileHeader) error {
 
 	return nil
 }
+
+// uploadError maps a failed upload to a JSON error response: 413 when the file can never
+// fit in the tenant's quota, 507 when the quota is used up, 500 otherwise
+func (h *Handler) uploadError(c *fiber.Ctx, ctx context.Context, err error) error {
+	tenantID, _ := tenant.Require(ctx)
+	switch {
+	case errors.Is(err, quota.ErrTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": "File is larger than the storage quota",
+			"quota": h.quotas.For(tenantID),
+		})
+	case errors.Is(err, quota.ErrExceeded):
+		usage, _ := h.store.GetTenantUsage(ctx)
+		return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{
+			"error": "Storage quota exceeded; delete and purge documents to free space",
+			"quota": h.quotas.For(tenantID),
+			"usage": usage,
+		})
+	}
+	slog.ErrorContext(ctx, "Error saving document", "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to save document",
+	})
+}
+
+// currentPrincipal returns the principal bound by AuthMiddleware
+func currentPrincipal(c *fiber.Ctx) *auth.Principal {
+	principal, _ := c.Locals("principal").(*auth.Principal)
+	return principal
+}
+
+// repoError maps repository and storage errors to a JSON error response
+func repoError(c *fiber.Ctx, err error, notFound string) error {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound), errors.Is(err, storage.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": notFound,
+		})
+	case errors.Is(err, tenant.ErrMissing), errors.Is(err, tenant.ErrInvalid):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "No tenant bound to request",
+		})
+	case errors.Is(err, repositories.ErrTenantMismatch), errors.Is(err, storage.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Repository error", "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Internal server error",
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/internal/buildinfo"
+	"UploadDocument-Saas/internal/health"
+	"UploadDocument-Saas/internal/middleware"
+)
+
+const serviceName = "upload-document-saas"
+
+// HealthCheck returns the health status of the application
+func (h *Handler) HealthCheck(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":    "ok",
+		"timestamp": time.Now(),
+		"service":   serviceName,
+		"version":   buildinfo.Version,
+	})
+}
+
+// Liveness reports that the process is up and serving requests. It checks no
+// dependencies, so an outage elsewhere never gets the replica restarted.
+func (h *Handler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":    health.StatusUp,
+		"timestamp": time.Now(),
+		"service":   serviceName,
+		"build":     buildinfo.Get(),
+	})
+}
+
+// Readiness checks Mongo, Elasticsearch, Kafka and storage, answering 503 when Mongo or
+// storage is down so the replica is taken out of rotation until they recover. Without
+// Elasticsearch or Kafka the replica is degraded but still ready. Callers show only
+// each component's status and latency unless they present the metrics token.
+func (h *Handler) Readiness(c *fiber.Ctx) error {
+	report := h.health.Ready(c.UserContext())
+	status := fiber.StatusOK
+	if report.Status == health.StatusDown {
+		status = fiber.StatusServiceUnavailable
+	}
+	if !middleware.HasMetricsAccess(c) {
+		return c.Status(status).JSON(fiber.Map{
+			"status":     report.Status,
+			"timestamp":  time.Now(),
+			"components": report.Public().Components,
+		})
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"status":     report.Status,
+		"timestamp":  time.Now(),
+		"service":    serviceName,
+		"build":      buildinfo.Get(),
+		"components": report.Components,
+	})
+}
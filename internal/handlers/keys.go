@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/internal/audit"
+)
+
+// ListTenantKeys lists the metadata of the tenant's data key versions
+func (h *Handler) ListTenantKeys(c *fiber.Ctx) error {
+	tenantKeys, err := h.store.ListTenantKeys(c.UserContext())
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"keys":           tenantKeys,
+		"master_version": h.keys.MasterVersion(),
+	})
+}
+
+// RotateTenantKeys re-wraps the tenant's data keys under the active master key and,
+// with {"rotate_data_key": true}, starts a new data key version for future uploads
+func (h *Handler) RotateTenantKeys(c *fiber.Ctx) error {
+	var req struct {
+		RotateDataKey bool `json:"rotate_data_key"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid JSON payload",
+			})
+		}
+	}
+
+	ctx := c.UserContext()
+	rewrapped, err := h.keys.Rewrap(ctx)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	dataKeyVersion := 0
+	if req.RotateDataKey {
+		if dataKeyVersion, err = h.keys.RotateDataKey(ctx); err != nil {
+			return repoError(c, err, "")
+		}
+	} else if dataKeyVersion, _, err = h.keys.ActiveDataKey(ctx); err != nil {
+		return repoError(c, err, "")
+	}
+
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionKeysRotated,
+		TargetType: audit.TargetTenantKey,
+		TargetID:   strconv.Itoa(dataKeyVersion),
+		Details: map[string]string{
+			"rewrapped":       strconv.Itoa(rewrapped),
+			"master_version":  strconv.Itoa(h.keys.MasterVersion()),
+			"rotate_data_key": strconv.FormatBool(req.RotateDataKey),
+		},
+	})
+
+	return c.JSON(fiber.Map{
+		"rewrapped":        rewrapped,
+		"master_version":   h.keys.MasterVersion(),
+		"data_key_version": dataKeyVersion,
+	})
+}
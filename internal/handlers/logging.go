@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/pkg/logger"
+)
+
+// GetLogLevel returns the minimum level currently logged
+func (h *Handler) GetLogLevel(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"level": logger.Level()})
+}
+
+// SetLogLevel changes the minimum level logged without restarting the process
+func (h *Handler) SetLogLevel(c *fiber.Ctx) error {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Level == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "level is required",
+		})
+	}
+	previous := logger.Level()
+	if err := logger.SetLevel(req.Level); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	slog.WarnContext(c.UserContext(), "Log level changed", "level", logger.Level(), "previous_level", previous)
+	return c.JSON(fiber.Map{"level": logger.Level()})
+}
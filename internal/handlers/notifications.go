@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/notifications"
+)
+
+// ListNotifications returns the caller's notifications newest first. ?unread=true
+// limits it to unread ones; ?before=<id> pages back from a notification.
+func (h *Handler) ListNotifications(c *fiber.Ctx) error {
+	limit, _ := strconv.ParseInt(c.Query("limit", "20"), 10, 64)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	before := primitive.NilObjectID
+	if raw := c.Query("before"); raw != "" {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid notification ID",
+			})
+		}
+		before = id
+	}
+
+	ctx := c.UserContext()
+	userID := currentPrincipal(c).UserID
+	list, err := h.store.ListNotifications(ctx, userID, c.QueryBool("unread"), before, limit)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	unread, err := h.store.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"notifications": list,
+		"unread_count":  unread,
+	})
+}
+
+// UnreadNotificationCount returns how many of the caller's notifications are unread
+func (h *Handler) UnreadNotificationCount(c *fiber.Ctx) error {
+	unread, err := h.store.CountUnreadNotifications(c.UserContext(), currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"unread_count": unread,
+	})
+}
+
+// MarkNotificationsRead marks {"ids": [...]} or, with {"all": true}, every unread
+// notification of the caller as read
+func (h *Handler) MarkNotificationsRead(c *fiber.Ctx) error {
+	var req struct {
+		IDs []string `json:"ids"`
+		All bool     `json:"all"`
+	}
+	if err := c.BodyParser(&req); err != nil || (!req.All && len(req.IDs) == 0) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ids or all is required",
+		})
+	}
+	var ids []primitive.ObjectID
+	if !req.All {
+		ids = make([]primitive.ObjectID, 0, len(req.IDs))
+		for _, raw := range req.IDs {
+			id, err := primitive.ObjectIDFromHex(raw)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid notification ID",
+				})
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	ctx := c.UserContext()
+	principal := currentPrincipal(c)
+	marked, err := h.store.MarkNotificationsRead(ctx, principal.UserID, ids)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	if marked > 0 {
+		h.notifier.PublishUnread(ctx, principal.TenantID, principal.UserID, notifications.EventRead, nil)
+	}
+	unread, err := h.store.CountUnreadNotifications(ctx, principal.UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"marked":       marked,
+		"unread_count": unread,
+	})
+}
+
+// GetNotificationPreferences returns the caller's notification preferences
+func (h *Handler) GetNotificationPreferences(c *fiber.Ctx) error {
+	prefs, err := h.store.GetNotificationPreferences(c.UserContext(), currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"preferences": prefs,
+		"types":       notifications.Types,
+	})
+}
+
+// UpdateNotificationPreferences changes which notification types the caller receives
+// and whether, where and how often unread ones are emailed
+func (h *Handler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	var req struct {
+		Muted       []string `json:"muted"`
+		Email       *string  `json:"email"`
+		EmailDigest *string  `json:"email_digest"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON payload",
+		})
+	}
+
+	ctx := c.UserContext()
+	prefs, err := h.store.GetNotificationPreferences(ctx, currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	if req.Muted != nil {
+		for _, t := range req.Muted {
+			if !notifications.Known(t) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Unknown notification type " + t,
+				})
+			}
+		}
+		prefs.Muted = req.Muted
+	}
+	if req.Email != nil {
+		if *req.Email != "" {
+			addr, err := mail.ParseAddress(*req.Email)
+			if err != nil || addr.Name != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid email address",
+				})
+			}
+		}
+		prefs.Email = *req.Email
+	}
+	if req.EmailDigest != nil {
+		switch *req.EmailDigest {
+		case models.DigestOff, models.DigestHourly, models.DigestDaily:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "email_digest must be off, hourly or daily",
+			})
+		}
+		if *req.EmailDigest != prefs.EmailDigest {
+			prefs.NextDigestAt = nil
+		}
+		prefs.EmailDigest = *req.EmailDigest
+	}
+	if prefs.EmailDigest != models.DigestOff && prefs.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "An email address is required for email digests",
+		})
+	}
+
+	now := time.Now()
+	if prefs.EmailDigest == models.DigestOff {
+		prefs.NextDigestAt = nil
+	} else if prefs.NextDigestAt == nil {
+		prefs.NextDigestAt = notifications.NextDigest(prefs.EmailDigest, now)
+	}
+	prefs.UpdatedAt = now
+	if err := h.store.SaveNotificationPreferences(ctx, &prefs); err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"preferences": prefs,
+	})
+}
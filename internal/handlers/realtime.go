@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/internal/websocket"
+)
+
+// WebSocketMetrics returns a snapshot of hub connections and message counters
+func (h *Handler) WebSocketMetrics(c *fiber.Ctx) error {
+	return c.JSON(h.hub.Metrics())
+}
+
+// FolderViewers lists who is currently viewing a folder; "root" names the tenant root
+func (h *Handler) FolderViewers(c *fiber.Ctx) error {
+	id, err := h.folderParam(c)
+	if err != nil {
+		return folderParamError(c, err)
+	}
+	return h.viewersResponse(c, websocket.FolderTopic(id))
+}
+
+// DocumentViewers lists who is currently viewing a document
+func (h *Handler) DocumentViewers(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	if _, err := h.store.GetDocument(c.UserContext(), id); err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	return h.viewersResponse(c, websocket.DocumentTopic(id))
+}
+
+func (h *Handler) viewersResponse(c *fiber.Ctx, topic string) error {
+	tenantID, err := tenant.Require(c.UserContext())
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"topic":   topic,
+		"viewers": h.hub.Viewers(tenantID, topic),
+	})
+}
@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/notifications"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/pkg/logger"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 90 * 24 * time.Hour
+)
+
+// maxShareRecipients bounds how many users one share link notifies
+const maxShareRecipients = 50
+
+var errSharePassword = errors.New("share link password required or incorrect")
+
+// hashShareToken returns the stored form of a share token; raw tokens are never persisted
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newShareToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CreateShareLink creates an external share link for a document or folder
+func (h *Handler) CreateShareLink(c *fiber.Ctx) error {
+	var req struct {
+		TargetType   string `json:"target_type"`
+		TargetID     string `json:"target_id"`
+		Mode         string `json:"mode"`
+		Password     string `json:"password"`
+		MaxDownloads int    `json:"max_downloads"`
+		ExpiresIn    int64  `json:"expires_in"` // seconds
+		// Recipients are users of the tenant notified that the link was shared with them
+		Recipients []string `json:"recipients"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON payload",
+		})
+	}
+
+	targetID, err := primitive.ObjectIDFromHex(req.TargetID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid target ID",
+		})
+	}
+	if req.Mode == "" {
+		req.Mode = models.ShareModeReadOnly
+	}
+	if req.Mode != models.ShareModeReadOnly && req.Mode != models.ShareModeUpload {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Mode must be read_only or upload",
+		})
+	}
+	if req.MaxDownloads < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "max_downloads cannot be negative",
+		})
+	}
+	ttl := defaultShareTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > maxShareTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Share links may not outlive 90 days",
+		})
+	}
+
+	if len(req.Recipients) > maxShareRecipients {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Too many recipients",
+		})
+	}
+
+	ctx := c.UserContext()
+	var targetName string
+	switch req.TargetType {
+	case models.ShareTargetDocument:
+		if req.Mode == models.ShareModeUpload {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Upload mode is only available for folders",
+			})
+		}
+		document, err := h.store.GetDocument(ctx, targetID)
+		if err != nil {
+			return repoError(c, err, "Document not found")
+		}
+		targetName = document.Name
+	case models.ShareTargetFolder:
+		folder, err := h.store.GetFolder(ctx, targetID)
+		if err != nil {
+			return repoError(c, err, "Folder not found")
+		}
+		targetName = folder.Name
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "target_type must be document or folder",
+		})
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error generating share token", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create share link",
+		})
+	}
+	now := time.Now()
+	link := models.ShareLink{
+		TokenHash:    hashShareToken(token),
+		TargetType:   req.TargetType,
+		TargetID:     targetID,
+		Mode:         req.Mode,
+		MaxDownloads: req.MaxDownloads,
+		ExpiresAt:    now.Add(ttl),
+		CreatedBy:    currentPrincipal(c).UserID,
+		CreatedAt:    now,
+		Recipients:   uniqueRecipients(req.Recipients, currentPrincipal(c).UserID),
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid password",
+			})
+		}
+		link.PasswordHash = string(hash)
+		link.HasPassword = true
+	}
+
+	if err := h.store.InsertShareLink(ctx, &link); err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionShareCreated,
+		TargetType: link.TargetType,
+		TargetID:   link.TargetID.Hex(),
+		Details: map[string]string{
+			"share_link_id": link.ID.Hex(),
+			"mode":          link.Mode,
+			"expires_at":    link.ExpiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+
+	for _, userID := range link.Recipients {
+		h.notifier.Notify(ctx, models.Notification{
+			UserID:  userID,
+			Type:    notifications.TypeShareReceived,
+			Title:   link.CreatedBy + " shared the " + link.TargetType + " " + targetName + " with you",
+			Link:    "/api/share/received/" + link.ID.Hex(),
+			ActorID: link.CreatedBy,
+			Data: map[string]string{
+				"share_link_id": link.ID.Hex(),
+				"target_type":   link.TargetType,
+				"target_id":     link.TargetID.Hex(),
+			},
+		})
+	}
+
+	// The raw token is only ever returned here. Recipients open the link by its ID as
+	// themselves, so the token is never stored in their notifications.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"share_link": link,
+		"token":      token,
+		"url":        "/s/" + token,
+	})
+}
+
+// uniqueRecipients drops blanks, duplicates and the sharer from recipients
+func uniqueRecipients(recipients []string, sharer string) []string {
+	seen := map[string]bool{sharer: true, "": true}
+	var out []string
+	for _, userID := range recipients {
+		if !seen[userID] {
+			seen[userID] = true
+			out = append(out, userID)
+		}
+	}
+	return out
+}
+
+// ListShareLinks lists the share links created by the caller
+func (h *Handler) ListShareLinks(c *fiber.Ctx) error {
+	links, err := h.store.ListShareLinks(c.UserContext(), currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"share_links": links,
+	})
+}
+
+// RevokeShareLink revokes one of the caller's share links; admins may revoke any link in their tenant
+func (h *Handler) RevokeShareLink(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid share link ID",
+		})
+	}
+	principal := currentPrincipal(c)
+	owner := principal.UserID
+	if principal.IsAdmin() {
+		owner = ""
+	}
+	link, err := h.store.RevokeShareLink(c.UserContext(), id, owner)
+	if err != nil {
+		return repoError(c, err, "Share link not found")
+	}
+	h.audit.Record(c.UserContext(), c, audit.Entry{
+		Action:     audit.ActionShareRevoked,
+		TargetType: audit.TargetShareLink,
+		TargetID:   link.ID.Hex(),
+	})
+	return c.JSON(fiber.Map{
+		"message":    "Share link revoked",
+		"share_link": link,
+	})
+}
+
+// openShareLink resolves the :token param to a usable link and a context scoped to the link's tenant
+func (h *Handler) openShareLink(c *fiber.Ctx) (models.ShareLink, context.Context, error) {
+	link, err := h.store.FindShareLinkByTokenHash(c.UserContext(), hashShareToken(c.Params("token")))
+	if err != nil {
+		return link, nil, err
+	}
+	if !shareUsable(link) {
+		return link, nil, repositories.ErrShareUnavailable
+	}
+	if link.HasPassword {
+		password := sharePassword(c)
+		if password == "" || bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return link, nil, errSharePassword
+		}
+	}
+	ctx := logger.With(c.UserContext(), "tenant_id", link.TenantID, "share_link_id", link.ID.Hex())
+	return link, tenant.WithTenant(ctx, link.TenantID), nil
+}
+
+// shareUsable reports whether link is neither revoked, expired nor out of downloads
+func shareUsable(link models.ShareLink) bool {
+	return link.RevokedAt == nil && time.Now().Before(link.ExpiresAt) &&
+		(link.MaxDownloads == 0 || link.DownloadCount < link.MaxDownloads)
+}
+
+// sharePassword returns the password sent in the X-Share-Password header or, for
+// uploads, the password field of the form body. It is never read from the query
+// string, which ends up in access logs, browser history and Referer headers.
+func sharePassword(c *fiber.Ctx) string {
+	if password := c.Get("X-Share-Password"); password != "" {
+		return password
+	}
+	if c.Method() != fiber.MethodPost {
+		return ""
+	}
+	if form, err := c.MultipartForm(); err == nil {
+		if values := form.Value["password"]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return string(c.Request().PostArgs().Peek("password"))
+}
+
+// shareError maps share link failures to responses that do not reveal whether a token ever existed
+func shareError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound), errors.Is(err, repositories.ErrShareUnavailable):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Share link not found or expired",
+		})
+	case errors.Is(err, errSharePassword):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return repoError(c, err, "Not found")
+}
+
+// GetSharedContent serves a share link: it streams a shared document, lists a shared
+// folder, or streams ?document_id= from within a shared folder
+func (h *Handler) GetSharedContent(c *fiber.Ctx) error {
+	link, ctx, err := h.openShareLink(c)
+	if err != nil {
+		return shareError(c, err)
+	}
+	return h.serveShareLink(c, ctx, link)
+}
+
+// GetReceivedShare serves a share link to one of its named recipients, who is
+// authenticated and so needs neither the token nor the link's password
+func (h *Handler) GetReceivedShare(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid share link ID",
+		})
+	}
+	ctx := c.UserContext()
+	link, err := h.store.GetReceivedShareLink(ctx, id, currentPrincipal(c).UserID)
+	if err != nil {
+		return shareError(c, err)
+	}
+	if !shareUsable(link) {
+		return shareError(c, repositories.ErrShareUnavailable)
+	}
+	return h.serveShareLink(c, logger.With(ctx, "share_link_id", link.ID.Hex()), link)
+}
+
+// serveShareLink serves an opened share link in ctx, which is scoped to the link's tenant
+func (h *Handler) serveShareLink(c *fiber.Ctx, ctx context.Context, link models.ShareLink) error {
+	var err error
+	documentID := link.TargetID
+	if link.TargetType == models.ShareTargetFolder {
+		if c.Query("document_id") == "" {
+			return h.listSharedFolder(c, ctx, link)
+		}
+		if documentID, err = primitive.ObjectIDFromHex(c.Query("document_id")); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid document ID",
+			})
+		}
+	}
+
+	document, err := h.store.GetDocument(ctx, documentID)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	if link.TargetType == models.ShareTargetFolder && document.FolderID != link.TargetID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document not found",
+		})
+	}
+	if err := h.store.ConsumeShareDownload(ctx, link.ID); err != nil {
+		return shareError(c, err)
+	}
+	entry := audit.Entry{
+		Action:     audit.ActionDocumentDownloaded,
+		TargetType: audit.TargetDocument,
+		TargetID:   document.ID.Hex(),
+		Details:    map[string]string{"share_link_id": link.ID.Hex()},
+	}
+	// Anonymous downloads are attributed to the link, a recipient's to the recipient
+	if _, ok := auth.FromContext(ctx); !ok {
+		entry.ActorID, entry.ActorType = link.ID.Hex(), audit.ActorTypeShareLink
+	}
+	h.audit.Record(ctx, c, entry)
+	return h.streamDocument(c, ctx, document)
+}
+
+func (h *Handler) listSharedFolder(c *fiber.Ctx, ctx context.Context, link models.ShareLink) error {
+	folder, err := h.store.GetFolder(ctx, link.TargetID)
+	if err != nil {
+		return repoError(c, err, "Folder not found")
+	}
+	documents, total, err := h.store.ListDocuments(ctx, bson.M{"folder_id": folder.ID}, 1, 100)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"folder":     folder,
+		"documents":  documents,
+		"total":      total,
+		"mode":       link.Mode,
+		"expires_at": link.ExpiresAt,
+	})
+}
+
+// UploadToShare accepts an anonymous upload into a folder shared in upload mode
+func (h *Handler) UploadToShare(c *fiber.Ctx) error {
+	link, ctx, err := h.openShareLink(c)
+	if err != nil {
+		return shareError(c, err)
+	}
+	if link.TargetType != models.ShareTargetFolder || link.Mode != models.ShareModeUpload {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "This share link does not allow uploads",
+		})
+	}
+
+	file, err := c.FormFile("document")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file uploaded",
+		})
+	}
+	if err := validateFile(file); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tracker := h.pipeline.NewTracker(link.TenantID, "share:"+link.ID.Hex(), uploadID(c))
+	document, err := h.storeDocument(ctx, file, link.TargetID, tracker)
+	if err != nil {
+		return h.uploadError(c, ctx, err)
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentUploaded,
+		TargetType: audit.TargetDocument,
+		TargetID:   document.ID.Hex(),
+		ActorID:    link.ID.Hex(),
+		ActorType:  audit.ActorTypeShareLink,
+		Details:    map[string]string{"name": document.Name, "folder_id": link.TargetID.Hex()},
+	})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":  "Document uploaded successfully",
+		"document": document,
+	})
+}
+
+// streamDocument streams a stored document to the client as an attachment
+func (h *Handler) streamDocument(c *fiber.Ctx, ctx context.Context, document models.Document) error {
+	if document.Status == models.DocumentStatusQuarantined {
+		return quarantinedError(c)
+	}
+	if !document.Scanned() {
+		return notScannedError(c)
+	}
+	obj, err := h.storage.Open(ctx, document.StorageKey, document.KeyVersion)
+	if err != nil {
+		return repoError(c, err, "File not found")
+	}
+	c.Attachment(document.Name)
+	return c.SendStream(obj, int(obj.Size()))
+}
+
+// notScannedError refuses to serve a document whose malware scan has not completed
+func notScannedError(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "5")
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error": "Document is still being scanned",
+	})
+}
+
+// quarantinedError refuses to serve a document that failed the malware scan
+func quarantinedError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "Document failed the malware scan and is quarantined",
+	})
+}
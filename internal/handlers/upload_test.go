@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/quota"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// unreachableStore returns a store whose every query fails fast, for responses that
+// only decorate an error with usage
+func unreachableStore(t *testing.T) *repositories.Store {
+	t.Helper()
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	return repositories.New(client.Database("handlers_test"), nil)
+}
+
+func TestUploadErrorMapsQuotaFailures(t *testing.T) {
+	cfg := config.Defaults().Quota
+	cfg.MaxBytes, cfg.MaxDocuments = 1000, 10
+	quotas, err := quota.New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(Options{Store: unreachableStore(t), Quotas: quotas})
+
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		withQuota bool
+		withUsage bool
+	}{
+		{"larger than the quota", quota.ErrTooLarge, fiber.StatusRequestEntityTooLarge, true, false},
+		{"quota used up", quota.ErrExceeded, fiber.StatusInsufficientStorage, true, true},
+		{"wrapped quota error", errors.Join(errors.New("reserve"), quota.ErrExceeded), fiber.StatusInsufficientStorage, true, true},
+		{"anything else", errors.New("disk on fire"), fiber.StatusInternalServerError, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error {
+				return h.uploadError(c, tenant.WithTenant(context.Background(), "acme"), tt.err)
+			})
+			res, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.status)
+			}
+			var body struct {
+				Error string          `json:"error"`
+				Quota *quota.Limits   `json:"quota"`
+				Usage json.RawMessage `json:"usage"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error == "" {
+				t.Error("response has no error message")
+			}
+			if tt.withQuota && (body.Quota == nil || *body.Quota != (quota.Limits{MaxBytes: 1000, MaxDocuments: 10})) {
+				t.Errorf("quota = %+v, want the tenant's limits", body.Quota)
+			}
+			if !tt.withQuota && body.Quota != nil {
+				t.Errorf("quota = %+v, want none", body.Quota)
+			}
+			if (body.Usage != nil) != tt.withUsage {
+				t.Errorf("usage = %s, want present %v", body.Usage, tt.withUsage)
+			}
+		})
+	}
+}
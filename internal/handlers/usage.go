@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/internal/audit"
+)
+
+// GetUsage reports the tenant's storage usage against its quota, with a per-folder breakdown
+func (h *Handler) GetUsage(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	usage, err := h.store.GetTenantUsage(ctx)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	folders, err := h.store.ListFolderUsage(ctx)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"usage":   usage,
+		"quota":   h.quotas.For(usage.TenantID),
+		"folders": folders,
+	})
+}
+
+// ReconcileUsage recomputes the tenant's usage from its documents right away instead of
+// waiting for the periodic reconciliation
+func (h *Handler) ReconcileUsage(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	before, err := h.store.GetTenantUsage(ctx)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	usage, err := h.quotas.Reconcile(ctx)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionUsageReconciled,
+		TargetType: audit.TargetTenant,
+		TargetID:   usage.TenantID,
+		Details: map[string]string{
+			"bytes":              strconv.FormatInt(usage.Bytes, 10),
+			"documents":          strconv.FormatInt(usage.Documents, 10),
+			"previous_bytes":     strconv.FormatInt(before.Bytes, 10),
+			"previous_documents": strconv.FormatInt(before.Documents, 10),
+		},
+	})
+	return c.JSON(fiber.Map{
+		"usage": usage,
+		"quota": h.quotas.For(usage.TenantID),
+	})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/pipeline"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/storage"
+	"UploadDocument-Saas/internal/websocket"
+)
+
+// UploadDocumentVersion replaces a document's file with a new version. The previous
+// version is kept in the document's history. An optional expected_version form field
+// rejects the upload with 409 if someone else uploaded a version first.
+func (h *Handler) UploadDocumentVersion(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	file, err := c.FormFile("document")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No file uploaded",
+		})
+	}
+	if err := validateFile(file); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	ctx := c.UserContext()
+	principal := currentPrincipal(c)
+	current, err := h.store.GetDocument(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	if raw := c.FormValue("expected_version"); raw != "" {
+		if expected, err := strconv.Atoi(raw); err != nil || expected != current.Version {
+			return versionConflict(c, current.Version)
+		}
+	}
+
+	tracker := h.pipeline.NewTracker(principal.TenantID, principal.UserID, uploadID(c))
+	tracker.DocumentID = id.Hex()
+	tracker.Report(pipeline.StageReceived, 100)
+
+	key, err := storage.NewKey(ctx, file.Filename)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	src, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not read uploaded file",
+		})
+	}
+	defer src.Close()
+	// The previous version keeps its file, so the new one is charged in full
+	if err := h.quotas.Reserve(ctx, current.FolderID, file.Size, 0); err != nil {
+		tracker.Fail(pipeline.StageReceived, "storage quota exceeded")
+		return h.uploadError(c, ctx, err)
+	}
+	info, err := h.storage.Put(ctx, key, tracker.Reader(src, file.Size))
+	if err != nil {
+		h.quotas.Adjust(ctx, current.FolderID, -file.Size, 0)
+		tracker.Fail(pipeline.StageStored, "could not store file")
+		slog.ErrorContext(ctx, "Error saving document version", "document_id", id.Hex(), "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save document",
+		})
+	}
+
+	document, err := h.store.ReplaceDocumentVersion(ctx, current, models.Document{
+		Name:       file.Filename,
+		Size:       file.Size,
+		Type:       filepath.Ext(file.Filename),
+		StorageKey: key,
+		KeyVersion: info.KeyVersion,
+		UploadedBy: principal.UserID,
+		UploadedAt: time.Now(),
+	})
+	if err != nil {
+		_ = h.storage.Delete(ctx, key)
+		h.quotas.Adjust(ctx, current.FolderID, -file.Size, 0)
+		tracker.Fail(pipeline.StageStored, "could not record document version")
+		if errors.Is(err, repositories.ErrConflict) {
+			return versionConflict(c, current.Version)
+		}
+		return repoError(c, err, "Document not found")
+	}
+	tracker.Report(pipeline.StageStored, 100)
+	metrics.ObserveUpload("version", file.Size)
+
+	versioned := events.DocumentEvent{Document: document}
+	h.hub.Publish(document.TenantID, websocket.FolderTopic(document.FolderID), events.DocumentVersionCreated, versioned)
+	h.hub.Publish(document.TenantID, websocket.DocumentTopic(id), events.DocumentVersionCreated, versioned)
+	h.events.Emit(ctx, events.DocumentVersionCreated, versioned)
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionDocumentVersioned,
+		TargetType: audit.TargetDocument,
+		TargetID:   id.Hex(),
+		Details:    map[string]string{"name": document.Name, "version": strconv.Itoa(document.Version)},
+	})
+	h.pipeline.Process(ctx, pipeline.Job{Tracker: tracker, Document: document})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"document":  document,
+		"upload_id": tracker.UploadID,
+	})
+}
+
+// ListDocumentVersions lists a document's superseded versions, newest first
+func (h *Handler) ListDocumentVersions(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+	ctx := c.UserContext()
+	document, err := h.store.GetDocument(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Document not found")
+	}
+	versions, err := h.store.ListDocumentVersions(ctx, id)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"current_version": document.Version,
+		"versions":        versions,
+	})
+}
+
+func versionConflict(c *fiber.Ctx, current int) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":           "Document has a newer version",
+		"current_version": current,
+	})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+)
+
+var errInvalidFolderID = errors.New("invalid folder ID")
+
+// folderParam resolves the :id route parameter to a folder of the caller's tenant;
+// "root" names the tenant root and resolves to the zero ID
+func (h *Handler) folderParam(c *fiber.Ctx) (primitive.ObjectID, error) {
+	if c.Params("id") == "root" {
+		return primitive.NilObjectID, nil
+	}
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return id, errInvalidFolderID
+	}
+	_, err = h.store.GetFolder(c.UserContext(), id)
+	return id, err
+}
+
+func folderParamError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errInvalidFolderID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid folder ID",
+		})
+	}
+	return repoError(c, err, "Folder not found")
+}
+
+// WatchFolder subscribes the caller to notifications about uploads, new versions,
+// comments and deletions in a folder; {"recursive": true} includes its subfolders
+func (h *Handler) WatchFolder(c *fiber.Ctx) error {
+	id, err := h.folderParam(c)
+	if err != nil {
+		return folderParamError(c, err)
+	}
+	var req struct {
+		Recursive bool `json:"recursive"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid JSON payload",
+			})
+		}
+	}
+	watch, err := h.store.UpsertFolderWatch(c.UserContext(), models.FolderWatch{
+		UserID:    currentPrincipal(c).UserID,
+		FolderID:  id,
+		Recursive: req.Recursive,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"watch": watch,
+	})
+}
+
+// UnwatchFolder stops the caller's watch on a folder
+func (h *Handler) UnwatchFolder(c *fiber.Ctx) error {
+	id, err := h.folderParam(c)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return folderParamError(c, err)
+	}
+	// A watch may outlive its folder, so unwatching only needs a well-formed ID
+	if err := h.store.DeleteFolderWatch(c.UserContext(), currentPrincipal(c).UserID, id); err != nil {
+		return repoError(c, err, "Not watching this folder")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListWatches lists the folders the caller watches
+func (h *Handler) ListWatches(c *fiber.Ctx) error {
+	watches, err := h.store.ListFolderWatches(c.UserContext(), currentPrincipal(c).UserID)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"watches": watches,
+	})
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/webhooks"
+)
+
+// webhookRequest is the body accepted when creating or updating a webhook
+type webhookRequest struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+// CreateWebhook registers an endpoint for the tenant's events. The signing secret is
+// only returned in this response.
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	var req webhookRequest
+	if err := c.BodyParser(&req); err != nil || req.URL == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "url and events are required",
+		})
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	if err := h.validateWebhook(c.UserContext(), req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	ctx := c.UserContext()
+	now := time.Now()
+	hook := models.Webhook{
+		URL:       *req.URL,
+		Events:    req.Events,
+		Secret:    webhooks.NewSecret(),
+		Active:    true,
+		CreatedBy: currentPrincipal(c).UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if err := h.store.InsertWebhook(ctx, &hook); err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionWebhookCreated,
+		TargetType: audit.TargetWebhook,
+		TargetID:   hook.ID.Hex(),
+		Details:    map[string]string{"url": hook.URL, "events": strings.Join(hook.Events, ",")},
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+// ListWebhooks lists the tenant's webhooks along with the event types they may subscribe to
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	hooks, err := h.store.ListWebhooks(c.UserContext())
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"webhooks":    hooks,
+		"event_types": events.Types,
+	})
+}
+
+// UpdateWebhook changes a webhook's URL, description, event filter or active flag.
+// Re-activating a disabled webhook clears its failure count.
+func (h *Handler) UpdateWebhook(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+	var req webhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid JSON payload",
+		})
+	}
+	if err := h.validateWebhook(c.UserContext(), req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	details := map[string]string{}
+	if req.URL != nil {
+		set["url"] = *req.URL
+		details["url"] = *req.URL
+	}
+	if req.Description != nil {
+		set["description"] = *req.Description
+	}
+	if req.Events != nil {
+		set["events"] = req.Events
+		details["events"] = strings.Join(req.Events, ",")
+	}
+	if req.Active != nil {
+		set["active"] = *req.Active
+		details["active"] = strconv.FormatBool(*req.Active)
+		if *req.Active {
+			set["consecutive_failures"] = 0
+			update["$unset"] = bson.M{"disabled_at": "", "disabled_reason": ""}
+		} else {
+			set["disabled_at"] = time.Now()
+			set["disabled_reason"] = "disabled by " + currentPrincipal(c).UserID
+		}
+	}
+
+	ctx := c.UserContext()
+	hook, err := h.store.UpdateWebhook(ctx, id, update)
+	if err != nil {
+		return repoError(c, err, "Webhook not found")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionWebhookUpdated,
+		TargetType: audit.TargetWebhook,
+		TargetID:   hook.ID.Hex(),
+		Details:    details,
+	})
+	return c.JSON(fiber.Map{
+		"webhook": hook,
+	})
+}
+
+// DeleteWebhook removes a webhook; queued deliveries are abandoned but the log is kept
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+	ctx := c.UserContext()
+	if err := h.store.DeleteWebhook(ctx, id); err != nil {
+		return repoError(c, err, "Webhook not found")
+	}
+	if err := h.store.FailPendingWebhookDeliveries(ctx, id, "webhook deleted"); err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionWebhookDeleted,
+		TargetType: audit.TargetWebhook,
+		TargetID:   id.Hex(),
+	})
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns a webhook's delivery log, newest first, optionally
+// filtered by ?status=pending|succeeded|failed
+func (h *Handler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+	limit, _ := strconv.ParseInt(c.Query("limit", "50"), 10, 64)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	ctx := c.UserContext()
+	if _, err := h.store.GetWebhook(ctx, id); err != nil {
+		return repoError(c, err, "Webhook not found")
+	}
+	deliveries, err := h.store.ListWebhookDeliveries(ctx, id, c.Query("status"), limit)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+	})
+}
+
+// RedeliverWebhookDelivery queues a new attempt at an earlier delivery's payload
+func (h *Handler) RedeliverWebhookDelivery(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+	deliveryID, err := primitive.ObjectIDFromHex(c.Params("deliveryId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid delivery ID",
+		})
+	}
+
+	ctx := c.UserContext()
+	hook, err := h.store.GetWebhook(ctx, id)
+	if err != nil {
+		return repoError(c, err, "Webhook not found")
+	}
+	if !hook.Active {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Webhook is disabled; re-activate it before redelivering",
+		})
+	}
+	original, err := h.store.GetWebhookDelivery(ctx, id, deliveryID)
+	if err != nil {
+		return repoError(c, err, "Delivery not found")
+	}
+	delivery, err := h.webhooks.Redeliver(ctx, original)
+	if err != nil {
+		return repoError(c, err, "")
+	}
+	h.audit.Record(ctx, c, audit.Entry{
+		Action:     audit.ActionWebhookRedelivered,
+		TargetType: audit.TargetWebhook,
+		TargetID:   hook.ID.Hex(),
+		Details:    map[string]string{"delivery_id": original.ID.Hex(), "redelivery_id": delivery.ID.Hex()},
+	})
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"delivery": delivery,
+	})
+}
+
+func (h *Handler) validateWebhook(ctx context.Context, req webhookRequest) error {
+	if req.URL != nil {
+		if err := h.webhooks.ValidateURL(ctx, *req.URL); err != nil {
+			return err
+		}
+	}
+	if req.Events != nil {
+		if err := webhooks.ValidateEvents(req.Events); err != nil {
+			return err
+		}
+	}
+	if req.Description != nil && len(*req.Description) > 500 {
+		return errors.New("description is too long")
+	}
+	return nil
+}
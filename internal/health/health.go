@@ -0,0 +1,244 @@
+// Package health checks whether the API's dependencies are reachable, for the liveness
+// and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"UploadDocument-Saas/internal/storage"
+)
+
+// Dependencies are the clients the checks probe
+type Dependencies struct {
+	Mongo       *mongo.Client
+	Elastic     *elasticsearch.Client
+	KafkaBroker string
+	Storage     *storage.Local // the upload directory, probed for writes
+}
+
+// Component and report statuses. A report is degraded when only optional components
+// are down: the replica still serves traffic, with search or indexing held back.
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// optional are the components whose outage is absorbed by circuit breakers and the
+// index queue rather than failing requests
+var optional = map[string]bool{
+	"elasticsearch": true,
+	"kafka":         true,
+}
+
+// Component is the outcome of checking one dependency
+type Component struct {
+	Status    string            `json:"status"`
+	LatencyMS float64           `json:"latency_ms"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Report is the outcome of checking every dependency. Status is down if any required
+// component is.
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// Check probes one dependency, returning details worth reporting or why it is unusable
+type Check func(ctx context.Context) (map[string]string, error)
+
+// Checker probes the dependencies a replica needs to serve traffic
+type Checker struct {
+	deps    Dependencies
+	timeout time.Duration // bounds each check
+	checks  map[string]Check
+
+	cached struct {
+		sync.Mutex
+		report Report
+		at     time.Time
+	}
+}
+
+// New returns a checker probing deps, giving each check timeout to answer
+func New(deps Dependencies, timeout time.Duration) *Checker {
+	c := &Checker{deps: deps, timeout: timeout}
+	c.checks = map[string]Check{
+		"mongo":         c.checkMongo,
+		"elasticsearch": c.checkElastic,
+		"kafka":         c.checkKafka,
+		"storage":       c.checkStorage,
+	}
+	return c
+}
+
+// Public returns r without error messages and details, which can reveal internal
+// addresses and topology to unauthenticated callers
+func (r Report) Public() Report {
+	public := Report{Status: r.Status, Components: make(map[string]Component, len(r.Components))}
+	for name, component := range r.Components {
+		public.Components[name] = Component{Status: component.Status, LatencyMS: component.LatencyMS}
+	}
+	return public
+}
+
+// cacheTTL is how long a report is reused, so probes and unauthenticated callers
+// cannot fan out a round of dependency checks per request
+const cacheTTL = time.Second
+
+// Ready returns the latest report, checking again once it is older than cacheTTL.
+// Concurrent callers wait for one round of checks instead of starting their own.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.cached.Lock()
+	defer c.cached.Unlock()
+	if c.cached.at.IsZero() || time.Since(c.cached.at) >= cacheTTL {
+		// A caller hanging up must not cache its cancellation as an outage
+		c.cached.report = c.check(context.WithoutCancel(ctx))
+		c.cached.at = time.Now()
+	}
+	return c.cached.report
+}
+
+// check runs every check concurrently, each under its own timeout
+func (c *Checker) check(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Components: make(map[string]Component, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			component := c.run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+			switch {
+			case component.Status == StatusUp:
+			case optional[name]:
+				if report.Status == StatusUp {
+					report.Status = StatusDegraded
+				}
+			default:
+				report.Status = StatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// run calls check with a timeout. The result is taken when the timeout passes even if
+// check ignores its context, so one hung client cannot hold up the probe.
+func (c *Checker) run(ctx context.Context, check Check) Component {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type result struct {
+		details map[string]string
+		err     error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		details, err := check(ctx)
+		done <- result{details, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = ctx.Err()
+	}
+	component := Component{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   r.details,
+	}
+	if r.err != nil {
+		component.Status = StatusDown
+		component.Error = r.err.Error()
+	}
+	return component
+}
+
+var errNotConnected = errors.New("not connected")
+
+func (c *Checker) checkMongo(ctx context.Context) (map[string]string, error) {
+	if c.deps.Mongo == nil {
+		return nil, errNotConnected
+	}
+	return nil, c.deps.Mongo.Ping(ctx, readpref.Primary())
+}
+
+// checkElastic fails only on a red cluster; yellow still serves reads and writes
+func (c *Checker) checkElastic(ctx context.Context) (map[string]string, error) {
+	client := c.deps.Elastic
+	if client == nil {
+		return nil, errNotConnected
+	}
+	res, err := client.Cluster.Health(client.Cluster.Health.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("cluster health: %s", res.Status())
+	}
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	details := map[string]string{"cluster_status": body.Status}
+	if body.Status == "red" {
+		return details, fmt.Errorf("cluster status is red")
+	}
+	return details, nil
+}
+
+// checkKafka fetches cluster metadata from the bootstrap broker
+func (c *Checker) checkKafka(ctx context.Context) (map[string]string, error) {
+	conn, err := (&kafka.Dialer{}).DialContext(ctx, "tcp", c.deps.KafkaBroker)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	brokers, err := conn.Brokers()
+	if err != nil {
+		return nil, err
+	}
+	controller, err := conn.Controller()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"brokers":    strconv.Itoa(len(brokers)),
+		"controller": net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)),
+	}, nil
+}
+
+func (c *Checker) checkStorage(ctx context.Context) (map[string]string, error) {
+	if c.deps.Storage == nil {
+		return nil, errNotConnected
+	}
+	return nil, c.deps.Storage.Probe(ctx)
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withChecks returns a checker running checks instead of probing real dependencies
+func withChecks(checks map[string]Check) *Checker {
+	c := New(Dependencies{}, time.Second)
+	c.checks = checks
+	return c
+}
+
+func TestReadyCachesReport(t *testing.T) {
+	var calls atomic.Int32
+	c := withChecks(map[string]Check{
+		"mongo": func(context.Context) (map[string]string, error) {
+			calls.Add(1)
+			return nil, nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Ready(context.Background())
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("20 concurrent probes ran the check %d times, want 1", n)
+	}
+
+	c.cached.at = time.Now().Add(-cacheTTL)
+	c.Ready(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("a stale report was reused: %d checks, want 2", n)
+	}
+}
+
+func TestReadyIgnoresCallerCancellation(t *testing.T) {
+	c := withChecks(map[string]Check{
+		"mongo": func(ctx context.Context) (map[string]string, error) {
+			return nil, ctx.Err()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := c.Ready(ctx); report.Status != StatusUp {
+		t.Fatalf("a cancelled caller cached status %s, want %s", report.Status, StatusUp)
+	}
+}
+
+func TestPublicReportOmitsDetails(t *testing.T) {
+	c := withChecks(map[string]Check{
+		"mongo": func(context.Context) (map[string]string, error) {
+			return nil, errors.New("dial tcp 10.0.3.7:27017: connection refused")
+		},
+		"kafka": func(context.Context) (map[string]string, error) {
+			return map[string]string{"brokers": "3", "controller": "kafka-0.internal:9092"}, nil
+		},
+	})
+	report := c.Ready(context.Background())
+	if report.Status != StatusDown || report.Components["mongo"].Error == "" || report.Components["kafka"].Details == nil {
+		t.Fatalf("full report lost information: %+v", report)
+	}
+	public := report.Public()
+	if public.Status != StatusDown {
+		t.Errorf("public status = %s, want %s", public.Status, StatusDown)
+	}
+	for name, component := range public.Components {
+		if component.Error != "" || component.Details != nil {
+			t.Errorf("public %s component leaks %+v", name, component)
+		}
+		if component.Status != report.Components[name].Status {
+			t.Errorf("public %s status = %s, want %s", name, component.Status, report.Components[name].Status)
+		}
+	}
+}
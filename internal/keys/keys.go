@@ -0,0 +1,213 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// Store keeps the wrapped data keys of the tenant carried by each call's context;
+// repositories.Store implements it
+type Store interface {
+	GetActiveTenantKey(ctx context.Context) (models.TenantKey, error)
+	GetTenantKey(ctx context.Context, version int) (models.TenantKey, error)
+	ListTenantKeys(ctx context.Context) ([]models.TenantKey, error)
+	InsertTenantKey(ctx context.Context, key *models.TenantKey) error
+	DeactivateTenantKeys(ctx context.Context, below int) error
+	UpdateWrappedTenantKey(ctx context.Context, id primitive.ObjectID, wrapped []byte, masterVersion int) error
+	TenantsWithStaleKeys(ctx context.Context, masterVersion int) ([]string, error)
+}
+
+// Manager hands out per-tenant data keys, creating, caching and rotating them.
+// Data keys are only persisted wrapped by the master key.
+type Manager struct {
+	kms   Wrapper
+	store Store
+	mu    sync.RWMutex
+	cache map[string][]byte // "<tenant>/<version>" -> unwrapped data key
+}
+
+// NewManager returns a key manager keeping data keys in store, wrapped by kms
+func NewManager(kms Wrapper, store Store) *Manager {
+	return &Manager{kms: kms, store: store, cache: make(map[string][]byte)}
+}
+
+func cacheKey(tenantID string, version int) string {
+	return fmt.Sprintf("%s/%d", tenantID, version)
+}
+
+// aad binds a wrapped data key to its tenant and version so wrapped keys cannot be swapped
+func aad(tenantID string, version int) []byte {
+	return []byte("tenant-key:" + cacheKey(tenantID, version))
+}
+
+func (m *Manager) unwrap(key models.TenantKey) ([]byte, error) {
+	ck := cacheKey(key.TenantID, key.Version)
+	m.mu.RLock()
+	dek, ok := m.cache[ck]
+	m.mu.RUnlock()
+	if ok {
+		return dek, nil
+	}
+	dek, err := m.kms.Unwrap(key.WrappedKey, aad(key.TenantID, key.Version), key.MasterVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key %s: %w", ck, err)
+	}
+	m.mu.Lock()
+	m.cache[ck] = dek
+	m.mu.Unlock()
+	return dek, nil
+}
+
+// ActiveDataKey returns the data key new blobs of the caller's tenant are encrypted with,
+// creating the tenant's first key on demand
+func (m *Manager) ActiveDataKey(ctx context.Context) (int, []byte, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		key, err := m.store.GetActiveTenantKey(ctx)
+		if err == nil {
+			dek, err := m.unwrap(key)
+			return key.Version, dek, err
+		}
+		if !errors.Is(err, repositories.ErrNotFound) {
+			return 0, nil, err
+		}
+		version, dek, err := m.createDataKey(ctx, 1)
+		if errors.Is(err, repositories.ErrConflict) {
+			continue
+		}
+		return version, dek, err
+	}
+	return 0, nil, errors.New("keys: could not establish tenant data key")
+}
+
+// DataKey returns a specific data key version of the caller's tenant
+func (m *Manager) DataKey(ctx context.Context, version int) ([]byte, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	dek, ok := m.cache[cacheKey(tenantID, version)]
+	m.mu.RUnlock()
+	if ok {
+		return dek, nil
+	}
+	key, err := m.store.GetTenantKey(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	return m.unwrap(key)
+}
+
+func (m *Manager) createDataKey(ctx context.Context, version int) (int, []byte, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return 0, nil, err
+	}
+	wrapped, masterVersion, err := m.kms.Wrap(dek, aad(tenantID, version))
+	if err != nil {
+		return 0, nil, err
+	}
+	key := models.TenantKey{
+		TenantID:      tenantID,
+		Version:       version,
+		WrappedKey:    wrapped,
+		MasterVersion: masterVersion,
+		Active:        true,
+		CreatedAt:     time.Now(),
+	}
+	if err := m.store.InsertTenantKey(ctx, &key); err != nil {
+		return 0, nil, err
+	}
+	m.mu.Lock()
+	m.cache[cacheKey(tenantID, version)] = dek
+	m.mu.Unlock()
+	return version, dek, nil
+}
+
+// RotateDataKey creates a new data key version for the caller's tenant. New blobs use it;
+// existing blobs keep decrypting with the version recorded in their header.
+func (m *Manager) RotateDataKey(ctx context.Context) (int, error) {
+	current, _, err := m.ActiveDataKey(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version, _, err := m.createDataKey(ctx, current+1)
+	if err != nil {
+		return 0, err
+	}
+	return version, m.store.DeactivateTenantKeys(ctx, version)
+}
+
+// Rewrap re-wraps every data key of the caller's tenant under the active master key
+// version. It uses the master keys already loaded: only RewrapAll, run by operators at
+// startup or on SIGHUP, re-reads the process-wide key file. Blobs are untouched.
+func (m *Manager) Rewrap(ctx context.Context) (int, error) {
+	return m.rewrapTenant(ctx)
+}
+
+func (m *Manager) rewrapTenant(ctx context.Context) (int, error) {
+	keys, err := m.store.ListTenantKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	active := m.kms.ActiveVersion()
+	rewrapped := 0
+	for _, key := range keys {
+		if key.MasterVersion == active {
+			continue
+		}
+		dek, err := m.unwrap(key)
+		if err != nil {
+			return rewrapped, err
+		}
+		wrapped, masterVersion, err := m.kms.Wrap(dek, aad(key.TenantID, key.Version))
+		if err != nil {
+			return rewrapped, err
+		}
+		if err := m.store.UpdateWrappedTenantKey(ctx, key.ID, wrapped, masterVersion); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// RewrapAll re-reads the master key file and re-wraps the data keys of every tenant
+// still using an older master key version
+func (m *Manager) RewrapAll(ctx context.Context) (int, error) {
+	if err := m.kms.Reload(); err != nil {
+		return 0, err
+	}
+	tenants, err := m.store.TenantsWithStaleKeys(ctx, m.kms.ActiveVersion())
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, tenantID := range tenants {
+		n, err := m.rewrapTenant(tenant.WithTenant(ctx, tenantID))
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("rewrap tenant %s: %w", tenantID, err)
+		}
+	}
+	return total, nil
+}
+
+// MasterVersion returns the active master key version
+func (m *Manager) MasterVersion() int {
+	return m.kms.ActiveVersion()
+}
@@ -0,0 +1,262 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/storage"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// memoryKeys is an in-memory Store
+type memoryKeys struct {
+	mu   sync.Mutex
+	keys []models.TenantKey
+}
+
+func (m *memoryKeys) find(ctx context.Context, match func(models.TenantKey) bool) ([]*models.TenantKey, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []*models.TenantKey
+	for i := range m.keys {
+		if m.keys[i].TenantID == tenantID && match(m.keys[i]) {
+			found = append(found, &m.keys[i])
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Version < found[j].Version })
+	return found, nil
+}
+
+func (m *memoryKeys) GetActiveTenantKey(ctx context.Context) (models.TenantKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found, err := m.find(ctx, func(k models.TenantKey) bool { return k.Active })
+	if err != nil || len(found) == 0 {
+		return models.TenantKey{}, errors.Join(err, repositories.ErrNotFound)
+	}
+	return *found[len(found)-1], nil
+}
+
+func (m *memoryKeys) GetTenantKey(ctx context.Context, version int) (models.TenantKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found, err := m.find(ctx, func(k models.TenantKey) bool { return k.Version == version })
+	if err != nil || len(found) == 0 {
+		return models.TenantKey{}, errors.Join(err, repositories.ErrNotFound)
+	}
+	return *found[0], nil
+}
+
+func (m *memoryKeys) ListTenantKeys(ctx context.Context) ([]models.TenantKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found, err := m.find(ctx, func(models.TenantKey) bool { return true })
+	keys := []models.TenantKey{}
+	for _, k := range found {
+		keys = append(keys, *k)
+	}
+	return keys, err
+}
+
+func (m *memoryKeys) InsertTenantKey(ctx context.Context, key *models.TenantKey) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k.TenantID == tenantID && k.Version == key.Version {
+			return repositories.ErrConflict
+		}
+	}
+	key.TenantID, key.ID = tenantID, primitive.NewObjectID()
+	m.keys = append(m.keys, *key)
+	return nil
+}
+
+func (m *memoryKeys) DeactivateTenantKeys(ctx context.Context, below int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found, err := m.find(ctx, func(k models.TenantKey) bool { return k.Version < below })
+	for _, k := range found {
+		k.Active = false
+	}
+	return err
+}
+
+func (m *memoryKeys) UpdateWrappedTenantKey(ctx context.Context, id primitive.ObjectID, wrapped []byte, masterVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found, err := m.find(ctx, func(k models.TenantKey) bool { return k.ID == id })
+	for _, k := range found {
+		k.WrappedKey, k.MasterVersion = wrapped, masterVersion
+	}
+	return err
+}
+
+func (m *memoryKeys) TenantsWithStaleKeys(_ context.Context, masterVersion int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := map[string]bool{}
+	var tenants []string
+	for _, k := range m.keys {
+		if k.MasterVersion < masterVersion && !seen[k.TenantID] {
+			seen[k.TenantID] = true
+			tenants = append(tenants, k.TenantID)
+		}
+	}
+	return tenants, nil
+}
+
+// writeMasterKeys writes a key file holding a fresh master key for each version
+func writeMasterKeys(t *testing.T, path string, versions ...int) {
+	t.Helper()
+	var lines strings.Builder
+	for _, version := range versions {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&lines, "%d:%s\n", version, base64.StdEncoding.EncodeToString(key))
+	}
+	if err := os.WriteFile(path, []byte(lines.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// appendMasterKeys adds lines of src to the key file at path
+func appendMasterKeys(t *testing.T, path, src string) {
+	t.Helper()
+	extra, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(extra); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadKMS(t *testing.T, path string) *LocalKMS {
+	t.Helper()
+	kms, err := NewLocalKMS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kms
+}
+
+func TestBlobsOpenAfterDataKeyRotationAndRewrap(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "master.keys")
+	writeMasterKeys(t, keyFile, 1)
+	store := &memoryKeys{}
+	manager := NewManager(loadKMS(t, keyFile), store)
+	blobs := storage.NewLocal(filepath.Join(dir, "blobs"))
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	put := func(m *Manager, plain string) (string, int) {
+		t.Helper()
+		key, err := storage.NewKey(ctx, "report.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := storage.NewEncrypted(blobs, m).Put(ctx, key, strings.NewReader(plain))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, info.KeyVersion
+	}
+	v1Key, v1 := put(manager, "written under data key 1")
+	if v1 != 1 {
+		t.Fatalf("first blob used data key %d, want 1", v1)
+	}
+	if version, err := manager.RotateDataKey(ctx); err != nil || version != 2 {
+		t.Fatalf("RotateDataKey = %d, %v; want 2", version, err)
+	}
+	v2Key, v2 := put(manager, "written under data key 2")
+	if v2 != 2 {
+		t.Fatalf("blob after rotation used data key %d, want 2", v2)
+	}
+
+	// An operator adds master key 2 and re-wraps every data key under it
+	newMaster := filepath.Join(dir, "master-2.keys")
+	writeMasterKeys(t, newMaster, 2)
+	appendMasterKeys(t, keyFile, newMaster)
+	if n, err := manager.RewrapAll(context.Background()); err != nil || n != 2 {
+		t.Fatalf("RewrapAll = %d, %v; want 2 data keys re-wrapped", n, err)
+	}
+	for _, key := range store.keys {
+		if key.MasterVersion != 2 {
+			t.Errorf("data key %d is still wrapped by master key %d", key.Version, key.MasterVersion)
+		}
+	}
+
+	// A replica that only holds master key 2 and has no cached data keys reads both blobs
+	fresh := NewManager(loadKMS(t, newMaster), store)
+	for key, want := range map[string]struct {
+		version int
+		plain   string
+	}{
+		v1Key: {v1, "written under data key 1"},
+		v2Key: {v2, "written under data key 2"},
+	} {
+		obj, err := storage.NewEncrypted(blobs, fresh).Open(ctx, key, want.version)
+		if err != nil {
+			t.Fatalf("open blob of data key %d: %v", want.version, err)
+		}
+		got, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil || !bytes.Equal(got, []byte(want.plain)) {
+			t.Errorf("blob of data key %d read %q, %v", want.version, got, err)
+		}
+	}
+}
+
+func TestWrappedKeysAreBoundToTheirTenant(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "master.keys")
+	writeMasterKeys(t, keyFile, 1)
+	store := &memoryKeys{}
+	acme := tenant.WithTenant(context.Background(), "acme")
+	if _, _, err := NewManager(loadKMS(t, keyFile), store).ActiveDataKey(acme); err != nil {
+		t.Fatal(err)
+	}
+	key := store.keys[0]
+
+	fresh := NewManager(loadKMS(t, keyFile), store)
+	if _, err := fresh.unwrap(key); err != nil {
+		t.Fatalf("acme's own key does not unwrap: %v", err)
+	}
+	moved := key
+	moved.TenantID = "globex"
+	if _, err := NewManager(loadKMS(t, keyFile), store).unwrap(moved); err == nil {
+		t.Error("acme's wrapped key unwrapped as globex's")
+	}
+	renumbered := key
+	renumbered.Version = 2
+	if _, err := NewManager(loadKMS(t, keyFile), store).unwrap(renumbered); err == nil {
+		t.Error("acme's data key 1 unwrapped as version 2")
+	}
+}
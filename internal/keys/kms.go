@@ -0,0 +1,156 @@
+package keys
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownMasterKey is returned when a data key was wrapped by a master key version that is not loaded
+var ErrUnknownMasterKey = errors.New("keys: unknown master key version")
+
+// Wrapper wraps and unwraps data keys under a master key. It mirrors the
+// encrypt/decrypt surface of a cloud KMS so a real one can be dropped in.
+type Wrapper interface {
+	Wrap(plaintext, aad []byte) (wrapped []byte, masterVersion int, err error)
+	Unwrap(wrapped, aad []byte, masterVersion int) ([]byte, error)
+	ActiveVersion() int
+	Reload() error
+}
+
+// LocalKMS is a Wrapper backed by a local key file of "<version>:<base64 key>" lines.
+// The highest version is used for new wraps; older versions remain available for unwrapping.
+type LocalKMS struct {
+	path   string
+	mu     sync.RWMutex
+	keys   map[int][]byte
+	active int
+}
+
+// NewLocalKMS loads master keys from path, creating the file with a fresh key if it does not exist
+func NewLocalKMS(path string) (*LocalKMS, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := generateKeyFile(path); err != nil {
+			return nil, err
+		}
+		slog.Warn("Generated new master key file; back it up, data is unrecoverable without it", "path", path)
+	}
+	k := &LocalKMS{path: path}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func generateKeyFile(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	line := "1:" + base64.StdEncoding.EncodeToString(key) + "\n"
+	return os.WriteFile(path, []byte(line), 0600)
+}
+
+// Reload re-reads the key file, picking up newly appended master key versions
+func (k *LocalKMS) Reload() error {
+	f, err := os.Open(k.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	keys := make(map[int][]byte)
+	active := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, encoded, ok := strings.Cut(line, ":")
+		version, err := strconv.Atoi(v)
+		if !ok || err != nil || version < 1 {
+			return fmt.Errorf("keys: malformed master key line %q", v)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("keys: master key version %d must be 32 base64-encoded bytes", version)
+		}
+		keys[version] = key
+		if version > active {
+			active = version
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if active == 0 {
+		return errors.New("keys: master key file contains no keys")
+	}
+
+	k.mu.Lock()
+	k.keys, k.active = keys, active
+	k.mu.Unlock()
+	return nil
+}
+
+// ActiveVersion returns the master key version used for new wraps
+func (k *LocalKMS) ActiveVersion() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *LocalKMS) aead(version int) (cipher.AEAD, error) {
+	k.mu.RLock()
+	key, ok := k.keys[version]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Wrap encrypts plaintext under the active master key, authenticating aad alongside it
+func (k *LocalKMS) Wrap(plaintext, aad []byte) ([]byte, int, error) {
+	version := k.ActiveVersion()
+	gcm, err := k.aead(version)
+	if err != nil {
+		return nil, 0, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, 0, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), version, nil
+}
+
+// Unwrap decrypts a data key wrapped by the given master key version
+func (k *LocalKMS) Unwrap(wrapped, aad []byte, version int) ([]byte, error) {
+	gcm, err := k.aead(version)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("keys: wrapped key too short")
+	}
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,210 @@
+// Package metrics exposes Prometheus metrics for the HTTP API and the backends it
+// depends on: storage, Mongo, Elasticsearch, Kafka and the websocket hub.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	uploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upload_bytes_total",
+		Help: "Bytes accepted in uploads, by kind (document or version).",
+	}, []string{"kind"})
+	uploadSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upload_size_bytes",
+		Help:    "Size of accepted uploads, by kind (document or version).",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 9), // 1KiB .. 64MiB
+	}, []string{"kind"})
+
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_operation_duration_seconds",
+		Help:    "Duration of blob storage operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	storageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_operation_errors_total",
+		Help: "Failed blob storage operations.",
+	}, []string{"operation"})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "Duration of MongoDB commands by command and collection.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"command", "collection"})
+	mongoErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_command_errors_total",
+		Help: "Failed MongoDB commands by command and collection.",
+	}, []string{"command", "collection"})
+
+	elasticDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "elasticsearch_request_duration_seconds",
+		Help:    "Duration of Elasticsearch requests by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	kafkaProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_produced_total",
+		Help: "Messages written to Kafka by topic.",
+	}, []string{"topic"})
+	kafkaProduceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_produce_errors_total",
+		Help: "Failed Kafka writes by topic.",
+	}, []string{"topic"})
+	kafkaProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_produce_duration_seconds",
+		Help:    "Time to write a message to Kafka by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+	kafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Messages read from Kafka by topic and consumer group.",
+	}, []string{"topic", "group"})
+	kafkaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages behind the partition high-water mark as of the last read, by topic and consumer group.",
+	}, []string{"topic", "group"})
+
+	breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Circuit breaker state by dependency: 0 closed, 1 half-open, 2 open.",
+	}, []string{"dependency"})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// MustRegister adds collectors, such as the websocket hub, to the default registry
+func MustRegister(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(collectors...)
+}
+
+// ObserveRequest records one served HTTP request against its route template
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// InFlight tracks a request in progress; call the returned func when it is done
+func InFlight() func() {
+	httpInFlight.Inc()
+	return httpInFlight.Dec
+}
+
+// ObserveUpload records an accepted upload of size bytes; kind is "document" or "version"
+func ObserveUpload(kind string, size int64) {
+	uploadBytes.WithLabelValues(kind).Add(float64(size))
+	uploadSize.WithLabelValues(kind).Observe(float64(size))
+}
+
+// ObserveStorage records a storage operation that started at start and ended with err
+func ObserveStorage(operation string, start time.Time, err error) {
+	storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveKafkaProduce records a write of n messages to topic that started at start
+func ObserveKafkaProduce(topic string, n int, start time.Time, err error) {
+	kafkaProduceDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		kafkaProduceErrors.WithLabelValues(topic).Inc()
+		return
+	}
+	kafkaProduced.WithLabelValues(topic).Add(float64(n))
+}
+
+// ObserveKafkaConsume records a message read by group and how far the group is behind
+func ObserveKafkaConsume(group string, msg kafka.Message) {
+	kafkaConsumed.WithLabelValues(msg.Topic, group).Inc()
+	if lag := msg.HighWaterMark - msg.Offset - 1; lag >= 0 {
+		kafkaLag.WithLabelValues(msg.Topic, group).Set(float64(lag))
+	}
+}
+
+// SetBreakerState records the state of the circuit breaker guarding dependency
+func SetBreakerState(dependency string, state int) {
+	breakerState.WithLabelValues(dependency).Set(float64(state))
+}
+
+// MongoMonitor returns a command monitor timing every MongoDB command
+func MongoMonitor() *event.CommandMonitor {
+	var collections sync.Map // request ID -> collection name
+	finish := func(e event.CommandFinishedEvent, failed bool) {
+		collection := ""
+		if v, ok := collections.LoadAndDelete(e.RequestID); ok {
+			collection = v.(string)
+		}
+		mongoDuration.WithLabelValues(e.CommandName, collection).Observe(e.Duration.Seconds())
+		if failed {
+			mongoErrors.WithLabelValues(e.CommandName, collection).Inc()
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection, ok := e.Command.Lookup(e.CommandName).StringValueOK()
+			if !ok {
+				collection, _ = e.Command.Lookup("collection").StringValueOK()
+			}
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.CommandFinishedEvent, true)
+		},
+	}
+}
+
+// ElasticTransport wraps next, or http.DefaultTransport when nil, to time Elasticsearch requests
+func ElasticTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(res.StatusCode)
+		}
+		elasticDuration.WithLabelValues(req.Method, status).Observe(time.Since(start).Seconds())
+		return res, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,62 +1,123 @@
 package middleware
 
 import (
-	"log"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/ratelimit"
+	"UploadDocument-Saas/internal/tracing"
+	"UploadDocument-Saas/pkg/logger"
 )
 
-// LoggerMiddleware returns a logger middleware
-func LoggerMiddleware() fiber.Handler {
-	return logger.New(logger.Config{
-		Format:     "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${error}\n",
-		TimeFormat: "2006-01-02 15:04:05",
-		TimeZone:   "Local",
-	})
+// RequestID tags each request with an ID, taken from a well-formed X-Request-ID header
+// or generated, echoes it in the response and attaches it to every log record
+// written for the request
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals("request_id", id)
+		c.SetUserContext(logger.With(c.UserContext(), "request_id", id))
+		return c.Next()
+	}
 }
 
-// RecoverMiddleware returns a recover middleware
-func RecoverMiddleware() fiber.Handler {
-	return recover.New(recover.Config{
-		EnableStackTrace: true,
-	})
+// validRequestID accepts caller-supplied IDs that are safe to log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
 }
 
-// Logger returns a custom logger middleware
-func Logger() fiber.Handler {
+// AccessLog writes one structured record per request once it has been served:
+// info for successes, warn for client errors and error for server errors
+func AccessLog() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
-
-		// Process request
 		err := c.Next()
 
-		// Log request details
-		log.Printf("[%s] %s %s - %v - %s",
-			c.Method(),
-			c.Path(),
-			c.IP(),
-			time.Since(start),
-			c.Response().Header.Peek("Content-Type"),
-		)
-
+		status := responseStatus(c, err)
+		lvl := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			lvl = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			lvl = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+			slog.Int("bytes", len(c.Response().Body())),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		slog.LogAttrs(c.UserContext(), lvl, "request", attrs...)
 		return err
 	}
 }
 
-// Recovery returns a recovery middleware using Fiber's built-in recovery
+// Recovery turns a panic in a handler into a 500 response and logs it with its stack
 func Recovery() fiber.Handler {
 	return recover.New(recover.Config{
 		EnableStackTrace: true,
 		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
-			log.Printf("Panic recovered: %v", e)
+			slog.ErrorContext(c.UserContext(), "Panic recovered", "panic", fmt.Sprint(e), "stack", string(debug.Stack()))
 		},
 	})
 }
 
+// responseStatus is the status the client receives, including one set by the error
+// handler for an error returned down the chain
+func responseStatus(c *fiber.Ctx, err error) int {
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &fe):
+		return fe.Code
+	case err != nil:
+		return fiber.StatusInternalServerError
+	}
+	return c.Response().StatusCode()
+}
+
 // CORSMiddleware returns a CORS middleware
 func CORSMiddleware() fiber.Handler {
 	return cors.New(cors.Config{
@@ -67,37 +128,253 @@ func CORSMiddleware() fiber.Handler {
 	})
 }
 
-// AuthMiddleware is a sample authentication middleware
-func AuthMiddleware() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		// Get token from header
-		token := c.Get("Authorization")
+// AuthMiddleware authenticates the bearer token against tokens and binds the caller's
+// principal and tenant to the request context
+func AuthMiddleware(tokens *auth.Tokens) fiber.Handler {
+	return authenticate(tokens, func(c *fiber.Ctx) string {
+		return c.Get("Authorization")
+	})
+}
 
+// StreamAuthMiddleware is AuthMiddleware for streaming endpoints, which also accept the
+// token as ?token= because browsers' EventSource cannot send an Authorization header
+func StreamAuthMiddleware(tokens *auth.Tokens) fiber.Handler {
+	return authenticate(tokens, func(c *fiber.Ctx) string {
+		if token := c.Get("Authorization"); token != "" {
+			return token
+		}
+		return c.Query("token")
+	})
+}
+
+func authenticate(tokens *auth.Tokens, tokenOf func(*fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := tokenOf(c)
 		if token == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Missing authorization token",
 			})
 		}
 
-		// Simple token validation (replace with your actual auth logic)
-		if token != "Bearer valid-token" {
+		principal, err := tokens.Authenticate(token)
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid authorization token",
 			})
 		}
 
-		// Set user context (example)
-		c.Locals("user_id", "12345")
+		c.Locals("principal", principal)
+		c.Locals("user_id", principal.UserID)
+		c.Locals("tenant_id", principal.TenantID)
+		c.SetUserContext(logger.With(auth.WithPrincipal(c.UserContext(), principal),
+			"tenant_id", principal.TenantID, "user_id", principal.UserID))
+
+		return c.Next()
+	}
+}
+
+// RequireRole rejects principals that do not hold the given role. It must run after AuthMiddleware.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := c.Locals("principal").(*auth.Principal)
+		if !ok || principal.Role != role {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// AuditMiddleware records action in log against the target named by the route
+// parameter once the handler has completed successfully
+func AuditMiddleware(log *audit.Log, action, targetType, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err == nil && c.Response().StatusCode() < fiber.StatusBadRequest {
+			log.Record(c.UserContext(), c, audit.Entry{
+				Action:     action,
+				TargetType: targetType,
+				TargetID:   c.Params(param),
+			})
+		}
+		return err
+	}
+}
+
+// RateLimitMiddleware limits requests against budget (ratelimit.BudgetRead, BudgetUpload
+// or BudgetSearch) with limiter's token buckets. Authenticated callers spend from buckets for their
+// API key, their user and their tenant; anonymous callers from one for their IP. Every
+// response carries RateLimit-Limit/Remaining/Reset for the tightest bucket; refused
+// requests get 429 with Retry-After. Mount it after AuthMiddleware so the caller is known.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, budget string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := limiter.LimitFor(budget)
+		var charges []ratelimit.Charge
+		if principal, ok := c.Locals("principal").(*auth.Principal); ok {
+			charges = []ratelimit.Charge{
+				{Key: budget + ":key:" + apiKeyID(c), Limit: limit},
+				{Key: budget + ":user:" + principal.TenantID + ":" + principal.UserID, Limit: limit},
+				{Key: budget + ":tenant:" + principal.TenantID, Limit: limiter.TenantLimitFor(budget)},
+			}
+		} else {
+			// c.IP() is the client's address behind a trusted proxy, not the proxy's
+			charges = []ratelimit.Charge{{Key: budget + ":ip:" + c.IP(), Limit: limit}}
+		}
+
+		// Every bucket is charged or none is, so a refused request does not drain the others
+		results, err := limiter.Take(c.UserContext(), charges)
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down with it
+			slog.ErrorContext(c.UserContext(), "Rate limit store error", "error", err)
+			return c.Next()
+		}
+		tightest := results[0]
+		for _, res := range results[1:] {
+			switch {
+			case tightest.Allowed && !res.Allowed:
+				tightest = res
+			case !tightest.Allowed && !res.Allowed && res.RetryAfter > tightest.RetryAfter:
+				// The request only succeeds once every bucket has a token
+				tightest = res
+			case tightest.Allowed && res.Allowed && res.Remaining < tightest.Remaining:
+				tightest = res
+			}
+		}
 
+		c.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.ResetAfter)))
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;name=%q", limit.Burst, ceilSeconds(limit.Window()), budget))
+		if !tightest.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(tightest.RetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Rate limit exceeded",
+			})
+		}
 		return c.Next()
 	}
 }
 
-// RateLimitMiddleware returns a rate limiting middleware
-func RateLimitMiddleware() fiber.Handler {
+// apiKeyID identifies the credential a request presented without keeping it in the store
+func apiKeyID(c *fiber.Ctx) string {
+	token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("token")
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// MetricsMiddleware counts and times every request by its route template, so that
+// /api/document/:id is one series however many documents there are
+func MetricsMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Simple rate limiting logic (you can use fiber/middleware/limiter for production)
-		// This is just a placeholder implementation
+		start := time.Now()
+		done := metrics.InFlight()
+		defer done()
+
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := c.Route().Path
+		// A 404 that only matched a group's middleware would otherwise be labelled with the group prefix
+		if status == fiber.StatusNotFound && route != c.Path() && !strings.ContainsAny(route, ":*") {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Method(), route, status, time.Since(start))
+		return err
+	}
+}
+
+// MetricsAccess guards /metrics. With a token set, scrapers must send it as a bearer
+// token; without one only loopback and private network addresses may scrape, which
+// config.Validate only allows when c.IP() is the real client address.
+func MetricsAccess(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if metricsAllowed(c, token) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+}
+
+// MetricsDetails marks requests that MetricsAccess would admit, without turning the
+// others away, so public endpoints can add operator-only details. See HasMetricsAccess.
+func MetricsDetails(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("metrics_access", metricsAllowed(c, token))
 		return c.Next()
 	}
 }
+
+// HasMetricsAccess reports whether MetricsDetails admitted the request
+func HasMetricsAccess(c *fiber.Ctx) bool {
+	allowed, _ := c.Locals("metrics_access").(bool)
+	return allowed
+}
+
+func metricsAllowed(c *fiber.Ctx, token string) bool {
+	if token != "" {
+		given := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+	ip := net.ParseIP(c.IP())
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate())
+}
+
+// TracingMiddleware starts a server span per request, continuing any trace the caller
+// sent in a traceparent header, and binds it to the request context so repository,
+// storage and search calls become its children
+func TracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := tracing.Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Method()),
+			semconv.URLPath(c.Path()),
+			semconv.ClientAddress(c.IP()),
+		))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		route := c.Route().Path
+		status := responseStatus(c, err)
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if tenantID, ok := c.Locals("tenant_id").(string); ok {
+			span.SetAttributes(attribute.String("tenant.id", tenantID))
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
+
+// headerCarrier adapts request headers to a propagation.TextMapCarrier
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string { return h.c.Get(key) }
+
+func (h headerCarrier) Set(key, value string) { h.c.Request().Header.Set(key, value) }
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
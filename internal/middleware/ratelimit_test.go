@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/ratelimit"
+)
+
+// rateLimitedApp serves GET / behind RateLimitMiddleware for the read budget, as the
+// user named by the X-User header when there is one
+func rateLimitedApp(t *testing.T, read string, tenantFactor float64) *fiber.App {
+	t.Helper()
+	cfg := config.Defaults().RateLimit
+	cfg.Read, cfg.TenantFactor = read, tenantFactor
+	limiter, err := ratelimit.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-User"); user != "" {
+			c.Locals("principal", &auth.Principal{UserID: user, TenantID: "acme", Role: auth.RoleUser})
+		}
+		return c.Next()
+	})
+	app.Get("/", RateLimitMiddleware(limiter, ratelimit.BudgetRead), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+func get(t *testing.T, app *fiber.App, user string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+		req.Header.Set("Authorization", "Bearer token-"+user)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	headers := map[string]string{}
+	for _, name := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"} {
+		headers[name] = res.Header.Get(name)
+	}
+	return res.StatusCode, headers
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	// Two requests an hour: a token every 1800 seconds
+	app := rateLimitedApp(t, "2/1h", 1)
+	for _, want := range []struct {
+		remaining, reset string
+	}{{"1", "1800"}, {"0", "3600"}} {
+		status, h := get(t, app, "")
+		if status != fiber.StatusNoContent {
+			t.Fatalf("status = %d, want %d", status, fiber.StatusNoContent)
+		}
+		if h["RateLimit-Limit"] != "2" || h["RateLimit-Remaining"] != want.remaining || h["RateLimit-Reset"] != want.reset {
+			t.Errorf("headers = %v, want limit 2, remaining %s, reset %s", h, want.remaining, want.reset)
+		}
+		if h["RateLimit-Policy"] != `2;w=3600;name="read"` || h["Retry-After"] != "" {
+			t.Errorf("headers = %v, want the read policy and no Retry-After", h)
+		}
+	}
+
+	status, h := get(t, app, "")
+	if status != fiber.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", status, fiber.StatusTooManyRequests)
+	}
+	if h["Retry-After"] != "1800" || h["RateLimit-Remaining"] != "0" {
+		t.Errorf("headers = %v, want Retry-After 1800 and nothing remaining", h)
+	}
+}
+
+func TestRateLimitReportsTheTightestBucket(t *testing.T) {
+	// Each user may make 2 requests, the tenant 3 between its users
+	app := rateLimitedApp(t, "2/1h", 1.5)
+	get(t, app, "alice")
+	get(t, app, "alice")
+	if status, h := get(t, app, "bob"); status != fiber.StatusNoContent || h["RateLimit-Remaining"] != "0" || h["RateLimit-Limit"] != "3" {
+		t.Fatalf("bob's first request = %d %v, want allowed with the tenant bucket empty", status, h)
+	}
+	status, h := get(t, app, "bob")
+	if status != fiber.StatusTooManyRequests || h["RateLimit-Limit"] != "3" || h["Retry-After"] != "1200" {
+		t.Errorf("bob's second request = %d %v, want refused by the tenant bucket, retry in 1200s", status, h)
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type AuditEvent struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
+	Seq        int64              `bson:"seq" json:"seq"`
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+	ActorID    string             `bson:"actor_id" json:"actor_id"`
+	ActorType  string             `bson:"actor_type" json:"actor_type"`
+	IP         string             `bson:"ip" json:"ip"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	Action     string             `bson:"action" json:"action"`
+	TargetType string             `bson:"target_type" json:"target_type"`
+	TargetID   string             `bson:"target_id" json:"target_id"`
+	Details    map[string]string  `bson:"details,omitempty" json:"details,omitempty"`
+	PrevHash   string             `bson:"prev_hash" json:"prev_hash"`
+	Hash       string             `bson:"hash" json:"hash"`
+}
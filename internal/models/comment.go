@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a remark left on a document
+type Comment struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
+	DocumentID primitive.ObjectID `bson:"document_id" json:"document_id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Body       string             `bson:"body" json:"body"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -5,12 +5,34 @@ import (
 	"time"
 )
 
+const (
+	DocumentStatusProcessing  = "processing"
+	DocumentStatusReady       = "ready"
+	DocumentStatusQuarantined = "quarantined"
+	DocumentStatusFailed      = "failed"
+)
+
 type Document struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
 	Name       string             `bson:"name" json:"name"`
 	Size       int64              `bson:"size" json:"size"`
 	Type       string             `bson:"type" json:"type"`
 	FolderID   primitive.ObjectID `bson:"folder_id" json:"folder_id"`
+	Version    int                `bson:"version" json:"version"`
+	StorageKey string             `bson:"storage_key" json:"-"`
+	KeyVersion int                `bson:"key_version" json:"key_version"`
+	Status     string             `bson:"status" json:"status"`
+	ScannedAt  *time.Time         `bson:"scanned_at,omitempty" json:"scanned_at,omitempty"` // when the current file passed the malware scan
+	UploadedBy string             `bson:"uploaded_by" json:"uploaded_by"`
 	UploadedAt time.Time          `bson:"uploaded_at" json:"uploaded_at"`
-	URL        string             `bson:"url" json:"url"`
+	DeletedAt  *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy  string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	Content    string             `bson:"-" json:"content,omitempty"` // extracted text, only stored in the search index
+}
+
+// Scanned reports whether the current file passed the malware scan. Documents stored
+// before scans were tracked count as scanned once they are ready.
+func (d Document) Scanned() bool {
+	return d.ScannedAt != nil || d.Status == DocumentStatusReady
 }
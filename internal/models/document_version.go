@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DocumentVersion is a superseded version of a document, kept when a new one is uploaded
+type DocumentVersion struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID   string             `bson:"tenant_id" json:"tenant_id"`
+	DocumentID primitive.ObjectID `bson:"document_id" json:"document_id"`
+	Version    int                `bson:"version" json:"version"`
+	Name       string             `bson:"name" json:"name"`
+	Size       int64              `bson:"size" json:"size"`
+	Type       string             `bson:"type" json:"type"`
+	StorageKey string             `bson:"storage_key" json:"-"`
+	KeyVersion int                `bson:"key_version" json:"key_version"`
+	ScannedAt  *time.Time         `bson:"scanned_at,omitempty" json:"scanned_at,omitempty"`
+	UploadedBy string             `bson:"uploaded_by" json:"uploaded_by"`
+	UploadedAt time.Time          `bson:"uploaded_at" json:"uploaded_at"`
+	ReplacedAt time.Time          `bson:"replaced_at" json:"replaced_at"`
+}
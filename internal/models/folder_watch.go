@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FolderWatch opts a user into notifications about activity in a folder and, when
+// Recursive, its subfolders. The zero FolderID is the tenant root.
+type FolderWatch struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	FolderID  primitive.ObjectID `bson:"folder_id" json:"folder_id"`
+	Recursive bool               `bson:"recursive" json:"recursive"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
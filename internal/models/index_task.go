@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IndexTask is a document waiting to be indexed because Kafka or Elasticsearch was
+// unavailable when its upload was processed
+type IndexTask struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID      string             `bson:"tenant_id" json:"tenant_id"`
+	DocumentID    primitive.ObjectID `bson:"document_id" json:"document_id"`
+	Payload       []byte             `bson:"payload" json:"-"` // the pipeline job, as it would have gone to Kafka
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Email digest frequencies
+const (
+	DigestOff    = "off"
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// Notification is an in-app message addressed to one user
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Type      string             `bson:"type" json:"type"`
+	Title     string             `bson:"title" json:"title"`
+	Body      string             `bson:"body,omitempty" json:"body,omitempty"`
+	Link      string             `bson:"link,omitempty" json:"link,omitempty"`
+	ActorID   string             `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	Data      map[string]string  `bson:"data,omitempty" json:"data,omitempty"`
+	ReadAt    *time.Time         `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	EmailedAt *time.Time         `bson:"emailed_at,omitempty" json:"-"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NotificationPreferences controls how one user is notified
+type NotificationPreferences struct {
+	TenantID     string     `bson:"tenant_id" json:"-"`
+	UserID       string     `bson:"user_id" json:"user_id"`
+	Muted        []string   `bson:"muted" json:"muted"` // notification types not delivered at all
+	Email        string     `bson:"email,omitempty" json:"email,omitempty"`
+	EmailDigest  string     `bson:"email_digest" json:"email_digest"`
+	NextDigestAt *time.Time `bson:"next_digest_at,omitempty" json:"next_digest_at,omitempty"`
+	UpdatedAt    time.Time  `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	ShareTargetDocument = "document"
+	ShareTargetFolder   = "folder"
+
+	ShareModeReadOnly = "read_only"
+	ShareModeUpload   = "upload"
+)
+
+type ShareLink struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID      string             `bson:"tenant_id" json:"tenant_id"`
+	TokenHash     string             `bson:"token_hash" json:"-"`
+	TargetType    string             `bson:"target_type" json:"target_type"`
+	TargetID      primitive.ObjectID `bson:"target_id" json:"target_id"`
+	Mode          string             `bson:"mode" json:"mode"`
+	PasswordHash  string             `bson:"password_hash,omitempty" json:"-"`
+	HasPassword   bool               `bson:"has_password" json:"has_password"`
+	MaxDownloads  int                `bson:"max_downloads" json:"max_downloads"`
+	DownloadCount int                `bson:"download_count" json:"download_count"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedBy     string             `bson:"created_by" json:"created_by"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	RevokedAt     *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Recipients    []string           `bson:"recipients,omitempty" json:"recipients,omitempty"` // users notified of the share
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// TenantKey is a per-tenant data encryption key, stored only in wrapped form
+type TenantKey struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID      string             `bson:"tenant_id" json:"tenant_id"`
+	Version       int                `bson:"version" json:"version"`
+	WrappedKey    []byte             `bson:"wrapped_key" json:"-"`
+	MasterVersion int                `bson:"master_version" json:"master_version"`
+	Active        bool               `bson:"active" json:"active"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	RewrappedAt   *time.Time         `bson:"rewrapped_at,omitempty" json:"rewrapped_at,omitempty"`
+}
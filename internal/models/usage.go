@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Usage scopes
+const (
+	UsageScopeTenant = "tenant"
+	UsageScopeFolder = "folder"
+)
+
+// Usage is the storage a tenant, or one of its folders, occupies. Bytes covers every
+// stored file: current documents, their superseded versions and deleted documents
+// that have not been purged yet. Documents counts live documents only.
+type Usage struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	TenantID     string             `bson:"tenant_id" json:"tenant_id"`
+	Scope        string             `bson:"scope" json:"scope"`
+	FolderID     primitive.ObjectID `bson:"folder_id" json:"folder_id"` // zero for the tenant row and the tenant root
+	Bytes        int64              `bson:"bytes" json:"bytes"`
+	Documents    int64              `bson:"documents" json:"documents"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	ReconciledAt *time.Time         `bson:"reconciled_at,omitempty" json:"reconciled_at,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookAllEvents subscribes a webhook to every event type
+const WebhookAllEvents = "*"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a tenant's HTTP endpoint that receives signed event payloads
+type Webhook struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID            string             `bson:"tenant_id" json:"tenant_id"`
+	URL                 string             `bson:"url" json:"url"`
+	Description         string             `bson:"description,omitempty" json:"description,omitempty"`
+	Events              []string           `bson:"events" json:"events"`
+	Secret              string             `bson:"secret" json:"-"` // HMAC key, only returned when created
+	Active              bool               `bson:"active" json:"active"`
+	ConsecutiveFailures int                `bson:"consecutive_failures" json:"consecutive_failures"`
+	DisabledAt          *time.Time         `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+	DisabledReason      string             `bson:"disabled_reason,omitempty" json:"disabled_reason,omitempty"`
+	CreatedBy           string             `bson:"created_by" json:"created_by"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook
+type WebhookDelivery struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	TenantID      string              `bson:"tenant_id" json:"tenant_id"`
+	WebhookID     primitive.ObjectID  `bson:"webhook_id" json:"webhook_id"`
+	EventID       string              `bson:"event_id" json:"event_id"`
+	EventType     string              `bson:"event_type" json:"event_type"`
+	Payload       string              `bson:"payload" json:"payload"`
+	Status        string              `bson:"status" json:"status"`
+	Attempts      []WebhookAttempt    `bson:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time          `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	RedeliveryOf  *primitive.ObjectID `bson:"redelivery_of,omitempty" json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	CompletedAt   *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// WebhookAttempt records the outcome of one HTTP request for a delivery
+type WebhookAttempt struct {
+	At           time.Time `bson:"at" json:"at"`
+	StatusCode   int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	ResponseBody string    `bson:"response_body,omitempty" json:"response_body,omitempty"`
+	Error        string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs   int64     `bson:"duration_ms" json:"duration_ms"`
+}
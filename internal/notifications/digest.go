@@ -0,0 +1,121 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+const (
+	// digestPoll is how often due digests are looked for
+	digestPoll = time.Minute
+	// maxDigestItems bounds how many notifications one digest lists
+	maxDigestItems = 50
+)
+
+// NextDigest returns when the digest after now is due for frequency, or nil when
+// digests are off
+func NextDigest(frequency string, now time.Time) *time.Time {
+	var next time.Time
+	switch frequency {
+	case models.DigestHourly:
+		next = now.Add(time.Hour)
+	case models.DigestDaily:
+		next = now.Add(24 * time.Hour)
+	default:
+		return nil
+	}
+	return &next
+}
+
+// digestStore is the storage digests are claimed from and recorded in
+type digestStore interface {
+	ClaimDueDigest(ctx context.Context, now time.Time, next func(models.NotificationPreferences) time.Time) (models.NotificationPreferences, error)
+	PendingDigestNotifications(ctx context.Context, userID string, limit int64) ([]models.Notification, error)
+	MarkNotificationsEmailed(ctx context.Context, ids []primitive.ObjectID) error
+}
+
+// RunDigests emails users their unread notifications at their chosen frequency until
+// ctx is done. Every replica may run it; each digest is claimed by one of them.
+func (nt *Notifier) RunDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestPoll)
+	defer ticker.Stop()
+	for {
+		nt.sendDueDigests(ctx, time.Now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDueDigests sends every digest that is due, claiming them one at a time
+func (nt *Notifier) sendDueDigests(ctx context.Context, clock func() time.Time) {
+	for ctx.Err() == nil {
+		now := clock()
+		prefs, err := nt.digests.ClaimDueDigest(ctx, now, func(p models.NotificationPreferences) time.Time {
+			if next := NextDigest(p.EmailDigest, now); next != nil {
+				return *next
+			}
+			return now.Add(24 * time.Hour)
+		})
+		if errors.Is(err, repositories.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, repositories.ErrNotFound) {
+				slog.ErrorContext(ctx, "Error claiming notification digest", "error", err)
+			}
+			return
+		}
+		if err := nt.sendDigest(tenant.WithTenant(ctx, prefs.TenantID), prefs); err != nil {
+			slog.ErrorContext(ctx, "Error sending digest", "recipient_id", prefs.UserID, "tenant_id", prefs.TenantID, "error", err)
+		}
+	}
+}
+
+// sendDigest emails one user the unread notifications they have not been emailed yet
+func (nt *Notifier) sendDigest(ctx context.Context, prefs models.NotificationPreferences) error {
+	if prefs.Email == "" || prefs.EmailDigest == models.DigestOff {
+		return nil
+	}
+	pending, err := nt.digests.PendingDigestNotifications(ctx, prefs.UserID, maxDigestItems)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "You have %d unread notification(s):\n\n", len(pending))
+	ids := make([]primitive.ObjectID, 0, len(pending))
+	for _, n := range pending {
+		ids = append(ids, n.ID)
+		fmt.Fprintf(&body, "- %s  %s\n", n.CreatedAt.UTC().Format("2006-01-02 15:04 MST"), n.Title)
+		if n.Body != "" {
+			fmt.Fprintf(&body, "  %s\n", n.Body)
+		}
+		if n.Link != "" {
+			fmt.Fprintf(&body, "  %s\n", n.Link)
+		}
+	}
+	body.WriteString("\nChange how often you receive this email in your notification preferences.\n")
+
+	mail := Mail{
+		To:      prefs.Email,
+		Subject: fmt.Sprintf("%d unread notification(s)", len(pending)),
+		Body:    body.String(),
+	}
+	if err := nt.sender.Send(ctx, mail); err != nil {
+		return err
+	}
+	return nt.digests.MarkNotificationsEmailed(ctx, ids)
+}
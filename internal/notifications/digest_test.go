@@ -0,0 +1,294 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// memoryDigests is an in-memory digestStore
+type memoryDigests struct {
+	mu            sync.Mutex
+	prefs         []models.NotificationPreferences
+	notifications []models.Notification
+}
+
+func (m *memoryDigests) ClaimDueDigest(_ context.Context, now time.Time, next func(models.NotificationPreferences) time.Time) (models.NotificationPreferences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.prefs {
+		if p.NextDigestAt != nil && !p.NextDigestAt.After(now) {
+			due := next(p)
+			m.prefs[i].NextDigestAt = &due
+			return p, nil
+		}
+	}
+	return models.NotificationPreferences{}, repositories.ErrNotFound
+}
+
+func (m *memoryDigests) PendingDigestNotifications(ctx context.Context, userID string, limit int64) ([]models.Notification, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []models.Notification
+	for _, n := range m.notifications {
+		if n.TenantID == tenantID && n.UserID == userID && n.ReadAt == nil && n.EmailedAt == nil && int64(len(pending)) < limit {
+			pending = append(pending, n)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryDigests) MarkNotificationsEmailed(ctx context.Context, ids []primitive.ObjectID) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		for i, n := range m.notifications {
+			if n.ID == id && n.TenantID == tenantID {
+				m.notifications[i].EmailedAt = &now
+			}
+		}
+	}
+	return nil
+}
+
+// recordingSender keeps every mail it is asked to send
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func (s *recordingSender) Send(_ context.Context, m Mail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *recordingSender) byRecipient() map[string][]Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string][]Mail{}
+	for _, m := range s.sent {
+		out[m.To] = append(out[m.To], m)
+	}
+	return out
+}
+
+// digestNotifier returns a notifier over an in-memory store and a recording sender
+func digestNotifier(store *memoryDigests) (*Notifier, *recordingSender) {
+	sender := &recordingSender{}
+	return &Notifier{sender: sender, digests: store}, sender
+}
+
+func notificationsFor(tenantID, userID string, n int) []models.Notification {
+	out := make([]models.Notification, n)
+	for i := range out {
+		out[i] = models.Notification{
+			ID:        primitive.NewObjectID(),
+			TenantID:  tenantID,
+			UserID:    userID,
+			Type:      TypeShareReceived,
+			Title:     fmt.Sprintf("notification %d for %s", i+1, userID),
+			CreatedAt: time.Now(),
+		}
+	}
+	return out
+}
+
+func TestDigestsHonourPreferencesAndBatch(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	store := &memoryDigests{
+		prefs: []models.NotificationPreferences{
+			{TenantID: "acme", UserID: "alice", Email: "alice@acme.test", EmailDigest: models.DigestHourly, NextDigestAt: &due},
+			{TenantID: "acme", UserID: "bob", Email: "bob@acme.test", EmailDigest: models.DigestDaily, NextDigestAt: &due},
+			{TenantID: "acme", UserID: "carol", Email: "carol@acme.test", EmailDigest: models.DigestOff, NextDigestAt: &due},
+			{TenantID: "acme", UserID: "dave", EmailDigest: models.DigestDaily, NextDigestAt: &due},
+			{TenantID: "acme", UserID: "erin", Email: "erin@acme.test", EmailDigest: models.DigestHourly, NextDigestAt: &later},
+			{TenantID: "globex", UserID: "alice", Email: "alice@globex.test", EmailDigest: models.DigestDaily, NextDigestAt: &due},
+		},
+	}
+	store.notifications = append(store.notifications, notificationsFor("acme", "alice", maxDigestItems+10)...)
+	store.notifications = append(store.notifications, notificationsFor("acme", "bob", 2)...)
+	store.notifications = append(store.notifications, notificationsFor("acme", "carol", 3)...)
+	store.notifications = append(store.notifications, notificationsFor("acme", "dave", 3)...)
+	store.notifications = append(store.notifications, notificationsFor("acme", "erin", 3)...)
+	store.notifications = append(store.notifications, notificationsFor("globex", "alice", 1)...)
+	read := now
+	store.notifications[len(store.notifications)-1].ReadAt = &read // globex alice has read hers
+	notifier, sender := digestNotifier(store)
+
+	notifier.sendDueDigests(context.Background(), func() time.Time { return now })
+
+	sent := sender.byRecipient()
+	want := map[string]int{"alice@acme.test": maxDigestItems, "bob@acme.test": 2}
+	if len(sent) != len(want) {
+		t.Fatalf("sent digests to %v, want only %v", keys(sent), keys(want))
+	}
+	for to, items := range want {
+		mails := sent[to]
+		if len(mails) != 1 {
+			t.Fatalf("%s got %d digests, want 1", to, len(mails))
+		}
+		if subject := fmt.Sprintf("%d unread notification(s)", items); mails[0].Subject != subject {
+			t.Errorf("%s subject = %q, want %q", to, mails[0].Subject, subject)
+		}
+	}
+	if body := sent["bob@acme.test"][0].Body; strings.Contains(body, "alice") {
+		t.Errorf("bob's digest lists another user's notifications:\n%s", body)
+	}
+
+	// The ten notifications beyond the batch wait for alice's next hourly digest
+	for _, p := range store.prefs {
+		if p.UserID == "alice" && p.TenantID == "acme" && !p.NextDigestAt.Equal(now.Add(time.Hour)) {
+			t.Errorf("alice's next digest is at %v, want an hour after %v", p.NextDigestAt, now)
+		}
+		if p.UserID == "bob" && !p.NextDigestAt.Equal(now.Add(24*time.Hour)) {
+			t.Errorf("bob's next digest is at %v, want a day after %v", p.NextDigestAt, now)
+		}
+	}
+	next := now.Add(time.Hour)
+	notifier.sendDueDigests(context.Background(), func() time.Time { return next })
+	if mails := sender.byRecipient()["alice@acme.test"]; len(mails) != 2 || mails[1].Subject != "10 unread notification(s)" {
+		t.Errorf("alice's second digest: got %d digests %+v, want the 10 remaining notifications", len(mails), mails)
+	}
+	if mails := sender.byRecipient()["erin@acme.test"]; len(mails) != 1 {
+		t.Errorf("erin got %d digests once hers came due, want 1", len(mails))
+	}
+}
+
+func TestSMTPSenderDeliversToRecipient(t *testing.T) {
+	server := startSMTPSink(t)
+	sender := SMTPSender{Addr: server.addr, From: "noreply@docs.test"}
+	mail := Mail{To: "alice@acme.test", Subject: "2 unread notification(s)", Body: "line one\nline two\n"}
+	if err := sender.Send(context.Background(), mail); err != nil {
+		t.Fatal(err)
+	}
+	got := <-server.received
+	if got.from != "noreply@docs.test" || len(got.to) != 1 || got.to[0] != "alice@acme.test" {
+		t.Errorf("envelope from %q to %v, want noreply@docs.test to [alice@acme.test]", got.from, got.to)
+	}
+	if !strings.Contains(got.data, "Subject: 2 unread notification(s)\r\n") || !strings.Contains(got.data, "line one\r\nline two\r\n") {
+		t.Errorf("unexpected message:\n%s", got.data)
+	}
+
+	if err := sender.Send(context.Background(), Mail{To: "alice@acme.test\r\nBcc: eve@evil.test", Subject: "x"}); err == nil {
+		t.Error("sender accepted a recipient with an injected header")
+	}
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+type smtpSink struct {
+	addr     string
+	received chan smtpMessage
+}
+
+// startSMTPSink accepts SMTP sessions on a loopback port and reports each message
+func startSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	sink := &smtpSink{addr: ln.Addr().String(), received: make(chan smtpMessage, 4)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 sink ready")
+	var msg smtpMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			_ = tp.PrintfLine("250 sink")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			msg.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			_ = tp.PrintfLine("250 ok")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			_ = tp.PrintfLine("250 ok")
+		case verb == "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := readData(tp.Reader.R)
+			if err != nil {
+				return
+			}
+			msg.data = data
+			_ = tp.PrintfLine("250 queued")
+			s.received <- msg
+			msg = smtpMessage{}
+		case verb == "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+// readData reads a DATA section up to the lone "." line, keeping CRLF line endings
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+
+	"UploadDocument-Saas/config"
+)
+
+// Mail is a plain-text email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, m Mail) error
+}
+
+// SMTPSender sends through an SMTP server, such as a local sink listening on localhost:1025
+type SMTPSender struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+func (s SMTPSender) Send(ctx context.Context, m Mail) error {
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + m.To + "\r\n" +
+		"Subject: " + m.Subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + strings.ReplaceAll(m.Body, "\n", "\r\n")
+	return smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, []byte(msg))
+}
+
+// LogSender writes mail to the log instead of sending it; used when no SMTP server is configured
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, m Mail) error {
+	slog.InfoContext(ctx, "Email", "to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
+
+// NewSender returns a sender for the SMTP server cfg names, or LogSender when it names none
+func NewSender(cfg config.SMTPConfig) Sender {
+	if cfg.Addr == "" {
+		return LogSender{}
+	}
+	return SMTPSender{Addr: cfg.Addr, From: cfg.From, Username: cfg.Username, Password: cfg.Password}
+}
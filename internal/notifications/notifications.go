@@ -0,0 +1,91 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/websocket"
+)
+
+// Notification types
+const (
+	TypeShareReceived = "share.received"
+	TypeFolderUpload  = "folder.upload"
+	TypeFolderVersion = "folder.version"
+	TypeFolderComment = "folder.comment"
+	TypeFolderDelete  = "folder.delete"
+)
+
+// Types lists every notification type a user may mute
+var Types = []string{TypeShareReceived, TypeFolderUpload, TypeFolderVersion, TypeFolderComment, TypeFolderDelete}
+
+// Hub events sent on the recipient's user topic
+const (
+	EventCreated = "notification.created"
+	EventRead    = "notification.read"
+)
+
+// Notifier stores notifications, pushes them to their users through the hub and emails
+// digests of the unread ones
+type Notifier struct {
+	store   *repositories.Store
+	hub     *websocket.Hub
+	sender  Sender
+	digests digestStore
+}
+
+// New returns a notifier keeping notifications in store, publishing them to hub and
+// sending digests through sender
+func New(store *repositories.Store, hub *websocket.Hub, sender Sender) *Notifier {
+	return &Notifier{store: store, hub: hub, sender: sender, digests: store}
+}
+
+// Notify stores n for its user in the tenant carried by ctx, unless the user has muted
+// its type, and pushes it to the user's open connections along with the unread count
+func (nt *Notifier) Notify(ctx context.Context, n models.Notification) {
+	prefs, err := nt.store.GetNotificationPreferences(ctx, n.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading notification preferences", "recipient_id", n.UserID, "error", err)
+	}
+	for _, muted := range prefs.Muted {
+		if muted == n.Type {
+			return
+		}
+	}
+
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	if err := nt.store.InsertNotification(ctx, &n); err != nil {
+		slog.ErrorContext(ctx, "Error storing notification", "type", n.Type, "recipient_id", n.UserID, "error", err)
+		return
+	}
+	nt.PublishUnread(ctx, n.TenantID, n.UserID, EventCreated, &n)
+}
+
+// PublishUnread sends event to userID's user topic with the current unread count and,
+// when given, the notification it concerns
+func (nt *Notifier) PublishUnread(ctx context.Context, tenantID, userID, event string, n *models.Notification) {
+	unread, err := nt.store.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting unread notifications", "recipient_id", userID, "error", err)
+	}
+	payload := map[string]interface{}{"unread_count": unread}
+	if n != nil {
+		payload["notification"] = n
+	}
+	nt.hub.Publish(tenantID, websocket.UserTopic(userID), event, payload)
+}
+
+// Known reports whether t is a notification type
+func Known(t string) bool {
+	for _, known := range Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
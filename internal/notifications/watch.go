@@ -0,0 +1,89 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/models"
+)
+
+// watchTypes maps the document events watchers hear about to notification types
+var watchTypes = map[string]string{
+	events.DocumentUploaded:       TypeFolderUpload,
+	events.DocumentVersionCreated: TypeFolderVersion,
+	events.DocumentCommented:      TypeFolderComment,
+	events.DocumentDeleted:        TypeFolderDelete,
+}
+
+// WatchFolders notifies folder watchers of document events emitted on bus from now on
+func (nt *Notifier) WatchFolders(bus *events.Bus) {
+	bus.Subscribe(nt.notifyWatchers)
+}
+
+// notifyWatchers notifies everyone watching the folder a document event happened in,
+// except the user who caused it
+func (nt *Notifier) notifyWatchers(ctx context.Context, e events.Event) {
+	notificationType, ok := watchTypes[e.Type]
+	if !ok {
+		return
+	}
+	data, ok := e.Data.(events.DocumentEvent)
+	if !ok {
+		return
+	}
+	doc := data.Document
+	watches, err := nt.store.FolderWatchers(ctx, doc.FolderID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding folder watchers", "folder_id", doc.FolderID.Hex(), "error", err)
+		return
+	}
+
+	actor := e.ActorID
+	if actor == "" {
+		actor = "Someone"
+	}
+	var title, link string
+	switch e.Type {
+	case events.DocumentUploaded:
+		title = actor + " uploaded " + doc.Name
+	case events.DocumentVersionCreated:
+		title = actor + " uploaded a new version of " + doc.Name
+	case events.DocumentCommented:
+		title = actor + " commented on " + doc.Name
+	case events.DocumentDeleted:
+		title = actor + " deleted " + doc.Name
+	}
+	if e.Type != events.DocumentDeleted {
+		link = "/api/document/" + doc.ID.Hex()
+	}
+	body := ""
+	if data.Comment != nil {
+		body = data.Comment.Body
+		if runes := []rune(body); len(runes) > 200 {
+			body = string(runes[:200]) + "…"
+		}
+	}
+
+	notified := map[string]bool{e.ActorID: true}
+	for _, w := range watches {
+		if notified[w.UserID] {
+			continue
+		}
+		notified[w.UserID] = true
+		nt.Notify(ctx, models.Notification{
+			UserID:  w.UserID,
+			Type:    notificationType,
+			Title:   title,
+			Body:    body,
+			Link:    link,
+			ActorID: e.ActorID,
+			Data: map[string]string{
+				"event_id":    e.ID,
+				"document_id": doc.ID.Hex(),
+				"folder_id":   doc.FolderID.Hex(),
+				"watch_id":    w.ID.Hex(),
+			},
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"io"
+	"strings"
+)
+
+// maxContentBytes bounds the extracted text sent to the search index
+const maxContentBytes = 256 * 1024
+
+// extractText returns the searchable text of a document. Only plain text is
+// understood today; other types index on metadata alone.
+func extractText(docType string, r io.Reader) (string, error) {
+	if strings.ToLower(docType) != ".txt" {
+		return "", nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxContentBytes))
+	if err != nil {
+		return "", err
+	}
+	// Drop invalid sequences, including a partial rune cut by the size limit
+	return strings.ToValidUTF8(string(data), ""), nil
+}
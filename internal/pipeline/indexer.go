@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/internal/tracing"
+)
+
+// indexerGroup is the Kafka consumer group shared by every replica's indexer
+const indexerGroup = "document-indexer"
+
+// RunIndexer consumes queued documents from Kafka and indexes them in Elasticsearch
+// until ctx is cancelled
+func (p *Pipeline) RunIndexer(ctx context.Context) {
+	reader := p.opts.NewReader(indexerGroup)
+	defer reader.Close()
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			slog.ErrorContext(ctx, "Error reading indexing queue", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		metrics.ObserveKafkaConsume(indexerGroup, msg)
+		msgCtx, span := tracing.StartConsumer(ctx, indexerGroup, msg)
+		var job Job
+		if err := json.Unmarshal(msg.Value, &job); err != nil {
+			slog.WarnContext(msgCtx, "Dropping malformed indexing message", "offset", msg.Offset, "error", err)
+			tracing.End(span, err)
+			continue
+		}
+		job.Tracker.hub = p.opts.Hub
+		if err := p.indexJob(msgCtx, job); err != nil {
+			p.postpone(msgCtx, job, err)
+		}
+		span.End()
+	}
+}
+
+// indexJob indexes the document of job and marks it ready. It returns an error only
+// when the search cluster is unavailable and the job should be tried again later;
+// other failures mark the document failed.
+func (p *Pipeline) indexJob(ctx context.Context, job Job) error {
+	ctx, cancel := context.WithTimeout(tenant.WithTenant(ctx, job.Document.TenantID), stageTimeout)
+	defer cancel()
+
+	// Index the document as it is now; it may have been moved or deleted since upload
+	current, err := p.opts.Store.GetDocument(ctx, job.Document.ID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		slog.InfoContext(ctx, "Skipping index of deleted document", "document_id", job.Document.ID.Hex())
+		return nil
+	}
+	if err == nil {
+		current.Content = job.Document.Content
+		job.Document = current
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	wg.Add(1)
+	go p.opts.Store.IndexDocument(ctx, job.Document, &wg, errCh)
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		if errors.Is(err, repositories.ErrSearchUnavailable) {
+			return err
+		}
+		p.fail(ctx, job, StageIndexed, "indexing failed", err)
+		return nil
+	}
+
+	if err := p.opts.Store.UpdateDocumentStatus(ctx, job.Document.ID, models.DocumentStatusReady); err != nil {
+		slog.ErrorContext(ctx, "Error marking document ready", "document_id", job.Document.ID.Hex(), "error", err)
+	}
+	job.Tracker.Report(StageIndexed, 100)
+	return nil
+}
@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"UploadDocument-Saas/internal/breaker"
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/storage"
+	"UploadDocument-Saas/internal/tenant"
+	"UploadDocument-Saas/internal/tracing"
+	"UploadDocument-Saas/internal/websocket"
+)
+
+// stageTimeout bounds the background work done for one upload
+const stageTimeout = 5 * time.Minute
+
+// Job is an uploaded document moving through scanning, extraction and indexing.
+// It is also the payload of the indexing message on Kafka.
+type Job struct {
+	Tracker  Tracker         `json:"tracker"`
+	Document models.Document `json:"document"`
+}
+
+// Options connects the pipeline to its Kafka topic, the records, the stored blobs and
+// the hub that carries progress to uploaders
+type Options struct {
+	Writer    *kafka.Writer                      // queues documents for the indexer
+	NewReader func(groupID string) *kafka.Reader // consumes the indexer's queue
+	Store     *repositories.Store
+	Storage   storage.Storage
+	Hub       *websocket.Hub
+	Scanner   Scanner // DefaultScanner when nil
+}
+
+// Pipeline scans, extracts and indexes uploaded documents
+type Pipeline struct {
+	opts Options
+	// queueBreaker stops writing to Kafka for a while after repeated failures
+	queueBreaker *breaker.Breaker
+	// inFlight counts Process calls whose stages are still running
+	inFlight atomic.Int64
+}
+
+// New returns a pipeline using opts
+func New(opts Options) *Pipeline {
+	if opts.Scanner == nil {
+		opts.Scanner = DefaultScanner()
+	}
+	return &Pipeline{opts: opts, queueBreaker: breaker.New("kafka", 5, 30*time.Second)}
+}
+
+// NewTracker returns a tracker publishing the progress of uploadID to userID of tenantID
+func (p *Pipeline) NewTracker(tenantID, userID, uploadID string) Tracker {
+	return Tracker{TenantID: tenantID, UserID: userID, UploadID: uploadID, hub: p.opts.Hub}
+}
+
+// Process runs the post-upload stages in the background: scan, extract, then hand
+// the document to the indexer worker through Kafka. The stages continue the trace
+// of ctx, the upload request, but not its cancellation.
+func (p *Pipeline) Process(ctx context.Context, job Job) {
+	p.inFlight.Add(1)
+	go func() {
+		defer p.inFlight.Add(-1)
+		ctx, cancel := context.WithTimeout(tenant.WithTenant(tracing.Detach(ctx), job.Document.TenantID), stageTimeout)
+		defer cancel()
+		ctx, span := tracing.Start(ctx, "pipeline.process", trace.WithAttributes(attribute.String("document.id", job.Document.ID.Hex())))
+		defer span.End()
+		p.process(ctx, job)
+	}()
+}
+
+// Wait blocks until every document handed to Process has been queued for indexing or
+// has failed, or until ctx is done
+func (p *Pipeline) Wait(ctx context.Context) error {
+	for p.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) process(ctx context.Context, job Job) {
+	doc := &job.Document
+	tracker := job.Tracker
+
+	scanCtx, span := tracing.Start(ctx, "pipeline.scan")
+	obj, err := p.opts.Storage.Open(scanCtx, doc.StorageKey, doc.KeyVersion)
+	if err != nil {
+		tracing.End(span, err)
+		p.fail(ctx, job, StageScanned, "could not read stored file", err)
+		return
+	}
+	clean, reason, err := p.opts.Scanner.Scan(obj)
+	obj.Close()
+	tracing.End(span, err)
+	if err != nil {
+		p.fail(ctx, job, StageScanned, "scan failed", err)
+		return
+	}
+	if !clean {
+		slog.WarnContext(ctx, "Quarantined document", "document_id", doc.ID.Hex(), "reason", reason)
+		if err := p.opts.Store.UpdateDocumentStatus(ctx, doc.ID, models.DocumentStatusQuarantined); err != nil {
+			slog.ErrorContext(ctx, "Error quarantining document", "document_id", doc.ID.Hex(), "error", err)
+		}
+		tracker.Fail(StageScanned, "malware detected: "+reason)
+		return
+	}
+	if err := p.opts.Store.MarkDocumentScanned(ctx, doc.ID, doc.StorageKey); err != nil {
+		p.fail(ctx, job, StageScanned, "could not record scan result", err)
+		return
+	}
+	tracker.Report(StageScanned, 100)
+
+	extractCtx, span := tracing.Start(ctx, "pipeline.extract")
+	obj, err = p.opts.Storage.Open(extractCtx, doc.StorageKey, doc.KeyVersion)
+	if err != nil {
+		tracing.End(span, err)
+		p.fail(ctx, job, StageExtracted, "could not read stored file", err)
+		return
+	}
+	doc.Content, err = extractText(doc.Type, obj)
+	obj.Close()
+	tracing.End(span, err)
+	if err != nil {
+		p.fail(ctx, job, StageExtracted, "text extraction failed", err)
+		return
+	}
+	tracker.Report(StageExtracted, 100)
+
+	// Without Kafka the document waits in the index queue instead of failing
+	if err := p.publish(ctx, job); err != nil {
+		p.postpone(ctx, job, err)
+	}
+}
+
+// publish hands job to the indexer worker through Kafka
+func (p *Pipeline) publish(ctx context.Context, job Job) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	writer := p.opts.Writer
+	msg := kafka.Message{
+		Key:   []byte(job.Document.ID.Hex()),
+		Value: payload,
+	}
+	return p.queueBreaker.Do(func() error {
+		start := time.Now()
+		produceCtx, span := tracing.StartProducer(ctx, writer.Topic, &msg)
+		err := writer.WriteMessages(produceCtx, msg)
+		tracing.End(span, err)
+		metrics.ObserveKafkaProduce(writer.Topic, 1, start, err)
+		return err
+	})
+}
+
+// fail marks the document as failed and reports the failure to the uploader
+func (p *Pipeline) fail(ctx context.Context, job Job, stage Stage, reason string, err error) {
+	slog.ErrorContext(ctx, "Pipeline stage failed", "stage", stage, "document_id", job.Document.ID.Hex(), "error", err)
+	if err := p.opts.Store.UpdateDocumentStatus(ctx, job.Document.ID, models.DocumentStatusFailed); err != nil {
+		slog.ErrorContext(ctx, "Error marking document failed", "document_id", job.Document.ID.Hex(), "error", err)
+	}
+	job.Tracker.Fail(stage, reason)
+}
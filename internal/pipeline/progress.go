@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"io"
+	"time"
+
+	"UploadDocument-Saas/internal/websocket"
+)
+
+type Stage string
+
+const (
+	StageReceived  Stage = "received"
+	StageStored    Stage = "stored"
+	StageScanned   Stage = "scanned"
+	StageExtracted Stage = "extracted"
+	StageIndexed   Stage = "indexed"
+	StageFailed    Stage = "failed"
+)
+
+// ProgressEvent is the hub event name carrying upload progress
+const ProgressEvent = "upload.progress"
+
+// Progress is published to the uploader's user topic at each pipeline stage
+type Progress struct {
+	UploadID   string    `json:"upload_id"`
+	DocumentID string    `json:"document_id,omitempty"`
+	Stage      Stage     `json:"stage"`
+	Percent    *int      `json:"percent,omitempty"`
+	FailedAt   Stage     `json:"failed_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Tracker reports progress for one upload, keyed by the client-supplied upload ID.
+// Create it with Pipeline.NewTracker; a tracker without a hub reports nothing.
+type Tracker struct {
+	TenantID   string `json:"tenant_id"`
+	UserID     string `json:"user_id"`
+	UploadID   string `json:"upload_id"`
+	DocumentID string `json:"document_id,omitempty"`
+	hub        *websocket.Hub
+}
+
+func (t Tracker) publish(p Progress) {
+	if t.hub == nil {
+		return
+	}
+	p.UploadID = t.UploadID
+	p.DocumentID = t.DocumentID
+	p.Timestamp = time.Now()
+	t.hub.Publish(t.TenantID, websocket.UserTopic(t.UserID), ProgressEvent, p)
+}
+
+// Report publishes that stage has reached percent; a negative percent means unknown
+func (t Tracker) Report(stage Stage, percent int) {
+	p := Progress{Stage: stage}
+	if percent >= 0 {
+		p.Percent = &percent
+	}
+	t.publish(p)
+}
+
+// Fail publishes that the upload failed during stage
+func (t Tracker) Fail(stage Stage, reason string) {
+	t.publish(Progress{Stage: StageFailed, FailedAt: stage, Error: reason})
+}
+
+// Reader wraps r so that reading it reports StageStored progress in 10% steps
+func (t Tracker) Reader(r io.Reader, total int64) io.Reader {
+	return &progressReader{r: r, total: total, tracker: t, last: -1}
+}
+
+type progressReader struct {
+	r       io.Reader
+	total   int64
+	read    int64
+	last    int
+	tracker Tracker
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.total > 0 {
+		percent := int(p.read * 100 / p.total)
+		if step := percent / 10 * 10; step > p.last && step < 100 {
+			p.last = step
+			p.tracker.Report(StageStored, step)
+		}
+	}
+	return n, err
+}
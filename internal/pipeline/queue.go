@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+const (
+	// queuePollInterval is how often RunIndexQueue looks for due tasks when idle
+	queuePollInterval = 15 * time.Second
+	// queueLease hides a claimed task from other replicas while it is indexed
+	queueLease = stageTimeout + time.Minute
+	// baseQueueBackoff doubles after every failed attempt, up to maxQueueBackoff
+	baseQueueBackoff = 30 * time.Second
+	maxQueueBackoff  = 10 * time.Minute
+)
+
+// postpone puts job in the index queue, to be indexed by RunIndexQueue once Kafka or
+// Elasticsearch, whichever failed with cause, is back. The upload stays processing.
+func (p *Pipeline) postpone(ctx context.Context, job Job, cause error) {
+	ctx = tenant.WithTenant(ctx, job.Document.TenantID)
+	payload, err := json.Marshal(job)
+	if err == nil {
+		err = p.opts.Store.QueueIndexTask(ctx, job.Document.ID, payload, cause.Error(), time.Now().Add(baseQueueBackoff))
+	}
+	if err != nil {
+		p.fail(ctx, job, StageIndexed, "could not queue for indexing", errors.Join(cause, err))
+		return
+	}
+	slog.WarnContext(ctx, "Queued document for indexing later", "document_id", job.Document.ID.Hex(), "cause", cause.Error())
+}
+
+// RunIndexQueue indexes queued documents directly in Elasticsearch, bypassing Kafka,
+// until ctx is done. Every replica may run it; tasks are leased so each is indexed once.
+func (p *Pipeline) RunIndexQueue(ctx context.Context) {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain everything that is due, unless the search cluster is still refusing calls
+		for ctx.Err() == nil && p.opts.Store.SearchAvailable() {
+			task, err := p.opts.Store.ClaimDueIndexTask(ctx, time.Now(), queueLease)
+			if errors.Is(err, repositories.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Error claiming index task", "error", err)
+				break
+			}
+			taskCtx := tenant.WithTenant(ctx, task.TenantID)
+			job := Job{Tracker: Tracker{hub: p.opts.Hub}}
+			if err := json.Unmarshal(task.Payload, &job); err != nil {
+				slog.WarnContext(taskCtx, "Dropping malformed index task", "document_id", task.DocumentID.Hex(), "error", err)
+			} else if err := p.indexJob(taskCtx, job); err != nil {
+				next := time.Now().Add(queueBackoff(task.Attempts))
+				if err := p.opts.Store.RescheduleIndexTask(taskCtx, task.ID, err.Error(), next); err != nil {
+					slog.ErrorContext(taskCtx, "Error rescheduling index task", "document_id", task.DocumentID.Hex(), "error", err)
+				}
+				continue
+			}
+			if err := p.opts.Store.DeleteIndexTask(taskCtx, task.ID); err != nil {
+				slog.ErrorContext(taskCtx, "Error removing index task", "document_id", task.DocumentID.Hex(), "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueBackoff is the delay before the attempt following the attempts-th failure
+func queueBackoff(attempts int) time.Duration {
+	delay := baseQueueBackoff
+	for i := 1; i < attempts && delay < maxQueueBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxQueueBackoff {
+		delay = maxQueueBackoff
+	}
+	return delay
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"bytes"
+	"io"
+)
+
+// maxScanBytes bounds how much of a document the scanner reads
+const maxScanBytes = 32 * 1024 * 1024
+
+// Scanner inspects document content for malware
+type Scanner interface {
+	Scan(r io.Reader) (clean bool, reason string, err error)
+}
+
+// SignatureScanner flags content containing any known byte signature
+type SignatureScanner struct {
+	Signatures map[string][]byte
+}
+
+// DefaultScanner detects the EICAR test file; pass a real engine in Options.Scanner
+func DefaultScanner() Scanner {
+	return SignatureScanner{Signatures: map[string][]byte{
+		"EICAR-Test-File": []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`),
+	}}
+}
+
+func (s SignatureScanner) Scan(r io.Reader) (bool, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxScanBytes))
+	if err != nil {
+		return false, "", err
+	}
+	for name, sig := range s.Signatures {
+		if bytes.Contains(data, sig) {
+			return false, name, nil
+		}
+	}
+	return true, "", nil
+}
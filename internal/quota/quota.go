@@ -0,0 +1,162 @@
+// Package quota enforces per-tenant storage quotas and keeps usage accounting honest.
+//
+// Usage counters live in Mongo (see repositories.Store.ReserveUsage) and are adjusted on every
+// upload, new version, delete, move and purge. RunReconcile periodically recomputes them
+// from the documents themselves to correct any drift, once a tenant's counters have
+// settled so that reservations of uploads still in flight are not taken for drift.
+package quota
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// settlePeriod is how long a tenant's usage must go unchanged before RunReconcile
+// corrects it. An upload holds its reservation before its document is stored, so a
+// reconciliation during the upload would see the reservation as drift.
+const settlePeriod = 15 * time.Minute
+
+var (
+	// ErrExceeded means the tenant has no room left for the upload
+	ErrExceeded = repositories.ErrQuotaExceeded
+	// ErrTooLarge means the upload alone is larger than the tenant's whole quota
+	ErrTooLarge = errors.New("upload is larger than the storage quota")
+)
+
+// Limits is a tenant's quota. Zero means unlimited.
+type Limits struct {
+	MaxBytes     int64 `json:"max_bytes"`
+	MaxDocuments int64 `json:"max_documents"`
+}
+
+// Quotas holds each tenant's limits and how often usage is reconciled, and keeps the
+// usage counters in store
+type Quotas struct {
+	store             *repositories.Store
+	defaults          Limits
+	overrides         map[string]Limits // by tenant ID
+	reconcileInterval time.Duration
+}
+
+// New builds the quotas from the quota config section, keeping usage in store
+func New(cfg config.QuotaConfig, store *repositories.Store) (*Quotas, error) {
+	overrides, err := cfg.Overrides()
+	if err != nil {
+		return nil, err
+	}
+	q := &Quotas{
+		store:             store,
+		defaults:          Limits{MaxBytes: cfg.MaxBytes, MaxDocuments: cfg.MaxDocuments},
+		overrides:         make(map[string]Limits, len(overrides)),
+		reconcileInterval: cfg.ReconcileInterval,
+	}
+	for tenantID, o := range overrides {
+		q.overrides[tenantID] = Limits{MaxBytes: o.MaxBytes, MaxDocuments: o.MaxDocuments}
+	}
+	return q, nil
+}
+
+// For returns the quota of tenantID: its override if it has one, else the default
+func (q *Quotas) For(tenantID string) Limits {
+	if limits, ok := q.overrides[tenantID]; ok {
+		return limits
+	}
+	return q.defaults
+}
+
+// Reserve charges bytes and documents to the caller's tenant and folderID before they
+// are stored, failing with ErrTooLarge or ErrExceeded when they do not fit. Give the
+// reservation back with Adjust if storing fails.
+func (q *Quotas) Reserve(ctx context.Context, folderID primitive.ObjectID, bytes, documents int64) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	limits := q.For(tenantID)
+	if limits.MaxBytes > 0 && bytes > limits.MaxBytes {
+		return ErrTooLarge
+	}
+	return q.store.ReserveUsage(ctx, folderID, bytes, documents, limits.MaxBytes, limits.MaxDocuments)
+}
+
+// Adjust applies a usage change that needs no quota check, such as a delete or purge.
+// Failures are logged; reconciliation repairs the counters.
+func (q *Quotas) Adjust(ctx context.Context, folderID primitive.ObjectID, bytes, documents int64) {
+	if bytes == 0 && documents == 0 {
+		return
+	}
+	if err := q.store.AddUsage(ctx, folderID, bytes, documents); err != nil {
+		slog.ErrorContext(ctx, "Error updating usage", "folder_id", folderID.Hex(), "error", err)
+	}
+}
+
+// Reconcile recomputes the caller's tenant usage from Mongo and returns the corrected
+// total. Changes made while it runs are kept; a reservation taken before it started for
+// an upload whose document is not stored yet is lost until the next reconciliation.
+func (q *Quotas) Reconcile(ctx context.Context) (models.Usage, error) {
+	before, err := q.store.GetTenantUsage(ctx)
+	if err != nil {
+		return before, err
+	}
+	snapshot, err := q.store.ListFolderUsage(ctx)
+	if err != nil {
+		return before, err
+	}
+	folders, err := q.store.ComputeUsage(ctx)
+	if err != nil {
+		return before, err
+	}
+	after, err := q.store.CorrectUsage(ctx, before, snapshot, folders)
+	if err != nil {
+		return after, err
+	}
+	if after.Bytes != before.Bytes || after.Documents != before.Documents {
+		slog.InfoContext(ctx, "Reconciled usage", "tenant_id", after.TenantID,
+			"bytes", after.Bytes, "documents", after.Documents,
+			"previous_bytes", before.Bytes, "previous_documents", before.Documents)
+	}
+	return after, nil
+}
+
+// RunReconcile reconciles the usage of every tenant whose counters have settled each
+// reconcile interval until ctx is done
+func (q *Quotas) RunReconcile(ctx context.Context) {
+	ticker := time.NewTicker(q.reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		tenants, err := q.store.UsageTenants(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing tenants for usage reconciliation", "error", err)
+			continue
+		}
+		for _, id := range tenants {
+			tenantCtx := tenant.WithTenant(ctx, id)
+			usage, err := q.store.GetTenantUsage(tenantCtx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error reading usage for reconciliation", "tenant_id", id, "error", err)
+				continue
+			}
+			if time.Since(usage.UpdatedAt) < settlePeriod {
+				slog.DebugContext(ctx, "Usage still changing; reconciliation postponed", "tenant_id", id)
+				continue
+			}
+			if _, err := q.Reconcile(tenantCtx); err != nil {
+				slog.ErrorContext(ctx, "Error reconciling usage", "tenant_id", id, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+func TestReserveRejectsUploadsLargerThanTheWholeQuota(t *testing.T) {
+	cfg := config.Defaults().Quota
+	cfg.MaxBytes = 1000
+	cfg.TenantOverrides = "globex=5000:10"
+	// Without a store, any call that reaches the usage counters panics
+	q, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tenantID string
+		bytes    int64
+	}{
+		{"acme", 1001},
+		{"acme", 1 << 40},
+		{"globex", 5001},
+	}
+	for _, tt := range tests {
+		ctx := tenant.WithTenant(context.Background(), tt.tenantID)
+		if err := q.Reserve(ctx, primitive.NewObjectID(), tt.bytes, 1); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Reserve(%s, %d bytes) = %v, want %v", tt.tenantID, tt.bytes, err, ErrTooLarge)
+		}
+	}
+}
+
+func TestReserveRequiresATenant(t *testing.T) {
+	q, err := New(config.Defaults().Quota, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Reserve(context.Background(), primitive.NewObjectID(), 1, 1); !errors.Is(err, tenant.ErrMissing) {
+		t.Errorf("Reserve without a tenant = %v, want %v", err, tenant.ErrMissing)
+	}
+}
+
+func TestForPrefersTheTenantsOverride(t *testing.T) {
+	cfg := config.Defaults().Quota
+	cfg.MaxBytes, cfg.MaxDocuments = 1000, 10
+	cfg.TenantOverrides = "globex=5000:0"
+	q, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.For("acme"); got != (Limits{MaxBytes: 1000, MaxDocuments: 10}) {
+		t.Errorf("For(acme) = %+v, want the defaults", got)
+	}
+	if got := q.For("globex"); got != (Limits{MaxBytes: 5000, MaxDocuments: 0}) {
+		t.Errorf("For(globex) = %+v, want its override", got)
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled, after which it can be forgotten
+}
+
+// MemoryStore keeps buckets in process memory; limits are per replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, charges []Charge) ([]Result, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	buckets := make([]*bucket, len(charges))
+	allowed := true
+	for i, ch := range charges {
+		buckets[i] = s.refill(ch.Key, ch.Limit, now)
+		allowed = allowed && buckets[i].tokens >= 1
+	}
+	results := make([]Result, len(charges))
+	for i, ch := range charges {
+		b := buckets[i]
+		hadToken := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		results[i] = result(ch.Limit, b.tokens, hadToken)
+		b.full = now.Add(results[i].ResetAfter)
+	}
+	return results, nil
+}
+
+// refill returns key's bucket topped up for the time since it was last used, creating
+// a full one when there is none. Callers hold s.mu.
+func (s *MemoryStore) refill(key string, limit Limit, now time.Time) *bucket {
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(b.tokens+now.Sub(b.updated).Seconds()*limit.Rate, float64(limit.Burst))
+	b.updated = now
+	return b
+}
+
+// sweep drops buckets that have refilled, since a new bucket starts full anyway.
+// Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
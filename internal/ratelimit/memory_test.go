@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a MemoryStore clock moved by hand
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func testStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = clock.Now
+	return s, clock
+}
+
+// take spends from one bucket
+func take(t *testing.T, s *MemoryStore, key string, limit Limit) Result {
+	t.Helper()
+	results, err := s.Take(context.Background(), []Charge{{Key: key, Limit: limit}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results[0]
+}
+
+func TestBucketAllowsABurstThenRefuses(t *testing.T) {
+	s, _ := testStore()
+	limit := Limit{Rate: 1, Burst: 3} // one token a second
+	for i := 2; i >= 0; i-- {
+		res := take(t, s, "k", limit)
+		if !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Fatalf("take = %+v, want allowed with %d remaining", res, i)
+		}
+	}
+	res := take(t, s, "k", limit)
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("take from an empty bucket = %+v, want refused", res)
+	}
+	if res.RetryAfter != time.Second || res.ResetAfter != 3*time.Second {
+		t.Errorf("RetryAfter = %s, ResetAfter = %s; want 1s and 3s", res.RetryAfter, res.ResetAfter)
+	}
+}
+
+func TestBucketRefillsAtItsRateUpToItsBurst(t *testing.T) {
+	s, clock := testStore()
+	limit := Limit{Rate: 0.5, Burst: 2} // one token every two seconds
+	take(t, s, "k", limit)
+	take(t, s, "k", limit)
+
+	clock.Advance(time.Second)
+	if res := take(t, s, "k", limit); res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("take after half a token = %+v, want refused for another second", res)
+	}
+	clock.Advance(time.Second)
+	if res := take(t, s, "k", limit); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("take after a whole token = %+v, want allowed", res)
+	}
+
+	// A long idle spell refills only up to the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if res := take(t, s, "k", limit); !res.Allowed {
+			t.Fatalf("take %d after an hour = %+v, want allowed", i, res)
+		}
+	}
+	if res := take(t, s, "k", limit); res.Allowed {
+		t.Errorf("third take after an hour = %+v, want refused", res)
+	}
+}
+
+func TestRefusedTakeChargesNoBucket(t *testing.T) {
+	s, _ := testStore()
+	roomy := Limit{Rate: 1, Burst: 10}
+	tight := Limit{Rate: 1, Burst: 1}
+	charges := []Charge{{Key: "user", Limit: roomy}, {Key: "tenant", Limit: tight}}
+
+	if results, _ := s.Take(context.Background(), charges); !results[0].Allowed || !results[1].Allowed {
+		t.Fatalf("first take = %+v, want allowed", results)
+	}
+	for i := 0; i < 5; i++ {
+		results, _ := s.Take(context.Background(), charges)
+		if !results[0].Allowed || results[1].Allowed {
+			t.Fatalf("take %d = %+v, want refused by the tenant bucket only", i, results)
+		}
+		if results[0].Remaining != 9 {
+			t.Fatalf("refused take %d left the user bucket %d tokens, want 9", i, results[0].Remaining)
+		}
+	}
+	if res := take(t, s, "user", roomy); !res.Allowed || res.Remaining != 8 {
+		t.Errorf("user bucket after refusals = %+v, want 8 remaining", res)
+	}
+}
+
+func TestSweepForgetsRefilledBuckets(t *testing.T) {
+	s, clock := testStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	take(t, s, "idle", limit)
+	clock.Advance(sweepInterval + time.Second)
+	take(t, s, "busy", limit)
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("a refilled bucket was kept")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("a bucket in use was dropped")
+	}
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"UploadDocument-Saas/config"
+)
+
+// Budgets group routes that share a request allowance
+const (
+	BudgetRead   = "read"
+	BudgetUpload = "upload"
+	BudgetSearch = "search"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is the time an empty bucket takes to refill completely
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result is the state of a bucket after taking from it
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a token is available, when not Allowed
+}
+
+// Charge is one bucket a request spends a token from
+type Charge struct {
+	Key   string
+	Limit Limit
+}
+
+// Store keeps token buckets. Take spends one token from every bucket in charges when
+// each of them has one and from none otherwise, so a refused request costs nothing,
+// and returns the state of each bucket in order; a bucket's Result is Allowed when it
+// had a token.
+type Store interface {
+	Take(ctx context.Context, charges []Charge) ([]Result, error)
+}
+
+// result derives a Result from a bucket holding tokens after a take attempt
+func result(limit Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return r
+}
+
+// Limiter takes requests from the token buckets of each budget
+type Limiter struct {
+	limits       map[string]Limit
+	tenantFactor float64
+	store        Store
+}
+
+// New builds a Limiter from the rate limit config section, keeping buckets in Redis
+// when the store is "redis" and in memory for a single node otherwise
+func New(cfg config.RateLimitConfig) (*Limiter, error) {
+	l := &Limiter{limits: map[string]Limit{}, tenantFactor: cfg.TenantFactor, store: NewMemoryStore()}
+	for budget, spec := range cfg.Budgets() {
+		rate, err := config.ParseRate(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %s: %w", budget, err)
+		}
+		l.limits[budget] = Limit{Rate: float64(rate.Requests) / rate.Period.Seconds(), Burst: rate.Burst}
+	}
+	if l.tenantFactor < 1 {
+		l.tenantFactor = 1
+	}
+	if cfg.Store == "redis" {
+		l.store = NewRedisStore(cfg.RedisAddr, cfg.RedisPassword)
+	}
+	return l, nil
+}
+
+// LimitFor returns the per-caller limit of budget
+func (l *Limiter) LimitFor(budget string) Limit {
+	if limit, ok := l.limits[budget]; ok {
+		return limit
+	}
+	return l.limits[BudgetRead]
+}
+
+// TenantLimitFor returns the limit shared by a whole tenant for budget, scaled from the
+// per-caller limit by the tenant factor
+func (l *Limiter) TenantLimitFor(budget string) Limit {
+	limit := l.LimitFor(budget)
+	return Limit{Rate: limit.Rate * l.tenantFactor, Burst: int(float64(limit.Burst) * l.tenantFactor)}
+}
+
+// Take spends one token from every bucket in charges, or from none when any of them is empty
+func (l *Limiter) Take(ctx context.Context, charges []Charge) ([]Result, error) {
+	return l.store.Take(ctx, charges)
+}
+
+// Close releases the store's connections, if it holds any
+func (l *Limiter) Close() error {
+	if closer, ok := l.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
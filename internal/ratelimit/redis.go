@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// takeScript refills KEYS and takes a token from each of them, or from none when any is
+// empty, atomically using the Redis server clock so every replica sees the same buckets.
+// ARGV holds rate, burst for each key in turn. It returns {had token, tokens * 1000} per key.
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local tokens = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+  local rate = tonumber(ARGV[2 * i - 1])
+  local burst = tonumber(ARGV[2 * i])
+  local state = redis.call('HMGET', key, 'tokens', 'ts')
+  local n = tonumber(state[1])
+  local ts = tonumber(state[2])
+  if n == nil then
+    n = burst
+    ts = now
+  end
+  tokens[i] = math.min(burst, n + math.max(0, now - ts) * rate)
+  if tokens[i] < 1 then
+    allowed = false
+  end
+end
+
+local out = {}
+for i, key in ipairs(KEYS) do
+  local rate = tonumber(ARGV[2 * i - 1])
+  local burst = tonumber(ARGV[2 * i])
+  local had = 0
+  if tokens[i] >= 1 then
+    had = 1
+  end
+  if allowed then
+    tokens[i] = tokens[i] - 1
+  end
+  redis.call('HSET', key, 'tokens', tostring(tokens[i]), 'ts', tostring(now))
+  redis.call('PEXPIRE', key, math.ceil((burst - tokens[i]) / rate * 1000) + 1000)
+  out[2 * i - 1] = had
+  out[2 * i] = math.floor(tokens[i] * 1000)
+end
+return out
+`)
+
+// RedisStore keeps buckets in Redis so that limits hold across a cluster
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis server at addr
+func NewRedisStore(addr, password string) *RedisStore {
+	return &RedisStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     password,
+			DialTimeout:  2 * time.Second,
+			ReadTimeout:  500 * time.Millisecond,
+			WriteTimeout: 500 * time.Millisecond,
+		}),
+		prefix: "ratelimit:",
+	}
+}
+
+func (s *RedisStore) Take(ctx context.Context, charges []Charge) ([]Result, error) {
+	keys := make([]string, len(charges))
+	args := make([]interface{}, 0, 2*len(charges))
+	for i, ch := range charges {
+		keys[i] = s.prefix + ch.Key
+		args = append(args, ch.Limit.Rate, ch.Limit.Burst)
+	}
+	res, err := takeScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 2*len(charges) {
+		return nil, fmt.Errorf("rate limit script returned %d values for %d buckets", len(res), len(charges))
+	}
+	results := make([]Result, len(charges))
+	for i, ch := range charges {
+		results[i] = result(ch.Limit, float64(res[2*i+1])/1000, res[2*i] == 1)
+	}
+	return results, nil
+}
+
+// Close releases the Redis connection pool
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+// The audit collection is append-only: this file deliberately exposes no update or delete.
+
+const (
+	auditAppendAttempts = 5
+	// auditLockStripes bounds the per-tenant append locks however many tenants there are
+	auditLockStripes = 64
+)
+
+// AuditFilter narrows an audit log query
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+func (s *Store) getAuditCollection() *mongo.Collection {
+	coll := s.collection("audit_events")
+	s.indexOnce("audit_events").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		})
+		if err != nil {
+			slog.Error("Error creating audit indexes", "error", err)
+		}
+	})
+	return coll
+}
+
+// auditHash chains an event to its predecessor. It covers every field except the ID and the hash itself.
+func auditHash(e *models.AuditEvent) string {
+	payload, _ := json.Marshal(struct {
+		TenantID   string            `json:"tenant_id"`
+		Seq        int64             `json:"seq"`
+		Timestamp  int64             `json:"ts"`
+		ActorID    string            `json:"actor_id"`
+		ActorType  string            `json:"actor_type"`
+		IP         string            `json:"ip"`
+		UserAgent  string            `json:"user_agent"`
+		Action     string            `json:"action"`
+		TargetType string            `json:"target_type"`
+		TargetID   string            `json:"target_id"`
+		Details    map[string]string `json:"details,omitempty"`
+	}{e.TenantID, e.Seq, e.Timestamp.UnixMilli(), e.ActorID, e.ActorType, e.IP, e.UserAgent,
+		e.Action, e.TargetType, e.TargetID, e.Details})
+	sum := sha256.Sum256(append([]byte(e.PrevHash), payload...))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditLock returns the lock that serializes appends to tenantID's chain. Tenants
+// sharing a stripe only wait for each other.
+func (s *Store) auditLock(tenantID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(tenantID))
+	return &s.auditLocks[h.Sum32()%auditLockStripes]
+}
+
+// AppendAuditEvent appends an event to the caller's tenant audit chain
+func (s *Store) AppendAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	if err := stampTenant(ctx, &event.TenantID); err != nil {
+		return err
+	}
+	lock := s.auditLock(event.TenantID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Mongo stores milliseconds; truncate so the hash can be recomputed from the stored record
+	event.Timestamp = event.Timestamp.UTC().Truncate(time.Millisecond)
+	coll := s.getAuditCollection()
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var last models.AuditEvent
+		err := coll.FindOne(ctx, bson.M{"tenant_id": event.TenantID},
+			options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}),
+		).Decode(&last)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		event.ID = primitive.NewObjectID()
+		event.Seq = last.Seq + 1
+		event.PrevHash = last.Hash
+		event.Hash = auditHash(event)
+		_, err = coll.InsertOne(ctx, event)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("append audit event: too much contention on tenant %s", event.TenantID)
+}
+
+func auditQuery(filter AuditFilter) bson.M {
+	q := bson.M{}
+	if filter.ActorID != "" {
+		q["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		q["action"] = filter.Action
+	}
+	if filter.TargetType != "" {
+		q["target_type"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		q["target_id"] = filter.TargetID
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		ts := bson.M{}
+		if !filter.From.IsZero() {
+			ts["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			ts["$lte"] = filter.To
+		}
+		q["timestamp"] = ts
+	}
+	return q
+}
+
+// FindAuditEvents returns up to limit events matching filter in the caller's tenant, newest first
+func (s *Store) FindAuditEvents(ctx context.Context, filter AuditFilter, limit int64) ([]models.AuditEvent, error) {
+	q, err := scoped(ctx, auditQuery(filter))
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(limit)
+	cur, err := s.getAuditCollection().Find(ctx, q, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []models.AuditEvent{}
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// VerifyAuditChain walks the caller's tenant chain in order and returns the sequence
+// number of the first event whose hash or link does not match (0 when intact)
+func (s *Store) VerifyAuditChain(ctx context.Context) (checked int64, brokenAt int64, err error) {
+	q, err := scoped(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	cur, err := s.getAuditCollection().Find(ctx, q, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var chain auditChain
+	for cur.Next(ctx) {
+		var event models.AuditEvent
+		if err := cur.Decode(&event); err != nil {
+			return chain.checked, 0, err
+		}
+		if !chain.next(&event) {
+			return chain.checked, chain.checked + 1, nil
+		}
+	}
+	return chain.checked, 0, cur.Err()
+}
+
+// auditChain checks events one by one in sequence order
+type auditChain struct {
+	checked  int64
+	prevHash string
+}
+
+// next reports whether event follows the events checked so far: it has the next
+// sequence number, links to the previous hash and its own hash matches its fields
+func (c *auditChain) next(event *models.AuditEvent) bool {
+	if event.Seq != c.checked+1 || event.PrevHash != c.prevHash || auditHash(event) != event.Hash {
+		return false
+	}
+	c.checked++
+	c.prevHash = event.Hash
+	return true
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// auditEvents builds an intact chain of n events of tenantID
+func auditEvents(tenantID string, n int) []models.AuditEvent {
+	events := make([]models.AuditEvent, n)
+	prevHash := ""
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range events {
+		e := &events[i]
+		e.TenantID, e.Seq, e.Timestamp = tenantID, int64(i+1), start.Add(time.Duration(i)*time.Second)
+		e.ActorID, e.Action, e.TargetType, e.TargetID = "alice", "document.deleted", "document", "doc-1"
+		e.PrevHash = prevHash
+		e.Hash = auditHash(e)
+		prevHash = e.Hash
+	}
+	return events
+}
+
+// brokenAt runs events through an auditChain and returns the sequence number of the
+// first one that does not follow, 0 when the chain is intact
+func brokenAt(events []models.AuditEvent) int64 {
+	var chain auditChain
+	for i := range events {
+		if !chain.next(&events[i]) {
+			return chain.checked + 1
+		}
+	}
+	return 0
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]models.AuditEvent) []models.AuditEvent
+		want   int64
+	}{
+		{"intact", func(e []models.AuditEvent) []models.AuditEvent { return e }, 0},
+		{"action edited", func(e []models.AuditEvent) []models.AuditEvent {
+			e[2].Action = "document.viewed"
+			return e
+		}, 3},
+		{"details added", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1].Details = map[string]string{"reason": "cleanup"}
+			return e
+		}, 2},
+		{"edited and re-hashed", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1].ActorID = "mallory"
+			e[1].Hash = auditHash(&e[1])
+			return e
+		}, 3},
+		{"middle event deleted", func(e []models.AuditEvent) []models.AuditEvent {
+			return append(e[:2], e[3:]...)
+		}, 3},
+		{"first event deleted", func(e []models.AuditEvent) []models.AuditEvent {
+			return e[1:]
+		}, 1},
+		{"events reordered", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, 2},
+		{"renumbered after a deletion", func(e []models.AuditEvent) []models.AuditEvent {
+			e = append(e[:2], e[3:]...)
+			for i := 2; i < len(e); i++ {
+				e[i].Seq--
+				e[i].Hash = auditHash(&e[i])
+			}
+			return e
+		}, 3},
+		{"moved to another tenant", func(e []models.AuditEvent) []models.AuditEvent {
+			e[0].TenantID = "globex"
+			return e
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := brokenAt(tt.tamper(auditEvents("acme", 5))); got != tt.want {
+				t.Errorf("chain broken at %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditLockIsStablePerTenant(t *testing.T) {
+	s := &Store{}
+	if s.auditLock("acme") != s.auditLock("acme") {
+		t.Error("one tenant got two different locks")
+	}
+}
+
+func TestConcurrentAuditAppendsKeepTheChainIntact(t *testing.T) {
+	store := testStore(t)
+	// A second store over the same database has its own locks, like another replica,
+	// so only the unique index and the retry keep the two from forking the chain
+	replica := New(store.db, nil)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	const perStore = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perStore)
+	for _, s := range []*Store{store, replica} {
+		for i := 0; i < perStore; i++ {
+			wg.Add(1)
+			go func(s *Store) {
+				defer wg.Done()
+				errs <- s.AppendAuditEvent(ctx, &models.AuditEvent{
+					Timestamp: time.Now(), ActorID: "alice", Action: "document.viewed",
+				})
+			}(s)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	appended := int64(0)
+	for err := range errs {
+		// Heavy contention may exhaust the retries; a refused append must not fork the chain
+		if err == nil {
+			appended++
+		}
+	}
+	if appended < perStore {
+		t.Errorf("only %d of %d appends succeeded", appended, 2*perStore)
+	}
+
+	checked, broken, err := store.VerifyAuditChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broken != 0 || checked != appended {
+		t.Errorf("VerifyAuditChain = %d checked, broken at %d; want %d checked, intact", checked, broken, appended)
+	}
+}
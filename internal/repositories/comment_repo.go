@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getCommentCollection() *mongo.Collection {
+	return s.collection("comments")
+}
+
+// InsertComment stores a comment in the caller's tenant
+func (s *Store) InsertComment(ctx context.Context, comment *models.Comment) error {
+	if err := stampTenant(ctx, &comment.TenantID); err != nil {
+		return err
+	}
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	_, err := s.getCommentCollection().InsertOne(ctx, comment)
+	return err
+}
+
+// ListComments returns a document's comments, oldest first
+func (s *Store) ListComments(ctx context.Context, documentID primitive.ObjectID) ([]models.Comment, error) {
+	filter, err := scoped(ctx, bson.M{"document_id": documentID})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getCommentCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	comments := []models.Comment{}
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// DeleteComments removes every comment on a document
+func (s *Store) DeleteComments(ctx context.Context, documentID primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"document_id": documentID})
+	if err != nil {
+		return err
+	}
+	_, err = s.getCommentCollection().DeleteMany(ctx, filter)
+	return err
+}
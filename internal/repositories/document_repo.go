@@ -2,44 +2,70 @@ package repositories
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"UploadDocument-Saas/config"
 	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
 )
 
-var (
-	documentCollection *mongo.Collection
-	docOnce            sync.Once
-)
+// ErrTenantMismatch is returned when a record is written for a tenant other than the caller's
+var ErrTenantMismatch = errors.New("record belongs to another tenant")
+
+func (s *Store) getDocumentCollection() *mongo.Collection {
+	return s.collection("documents")
+}
+
+// stampTenant sets the caller's tenant on a new record, rejecting records that claim another tenant
+func stampTenant(ctx context.Context, recordTenant *string) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	if *recordTenant != "" && *recordTenant != tenantID {
+		return ErrTenantMismatch
+	}
+	*recordTenant = tenantID
+	return nil
+}
 
-func getDocumentCollection() *mongo.Collection {
-	docOnce.Do(func() {
-		client := config.GetMongoClient()
-		documentCollection = client.Database("testdb").Collection("documents")
-	})
-	return documentCollection
+// live restricts filter to documents that have not been deleted
+func live(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
 }
 
 // InsertDocument inserts a document concurrently
-func InsertDocument(ctx context.Context, doc models.Document, wg *sync.WaitGroup, errCh chan<- error) {
+func (s *Store) InsertDocument(ctx context.Context, doc models.Document, wg *sync.WaitGroup, errCh chan<- error) {
 	defer wg.Done()
-	_, err := getDocumentCollection().InsertOne(ctx, doc)
+	if err := stampTenant(ctx, &doc.TenantID); err != nil {
+		errCh <- err
+		return
+	}
+	_, err := s.getDocumentCollection().InsertOne(ctx, doc)
 	if err != nil {
 		errCh <- err
 		return
 	}
-	log.Println("Inserted document:", doc.ID)
+	slog.DebugContext(ctx, "Inserted document", "document_id", doc.ID.Hex())
 }
 
 // FindDocuments concurrently finds all documents
-func FindDocuments(ctx context.Context, filter bson.M, wg *sync.WaitGroup, docsCh chan<- models.Document, errCh chan<- error) {
+func (s *Store) FindDocuments(ctx context.Context, filter bson.M, wg *sync.WaitGroup, docsCh chan<- models.Document, errCh chan<- error) {
 	defer wg.Done()
-	cur, err := getDocumentCollection().Find(ctx, filter)
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	cur, err := s.getDocumentCollection().Find(ctx, live(filter))
 	if err != nil {
 		errCh <- err
 		return
@@ -54,3 +80,120 @@ func FindDocuments(ctx context.Context, filter bson.M, wg *sync.WaitGroup, docsC
 		docsCh <- doc
 	}
 }
+
+// GetDocument returns a single document by ID within the caller's tenant
+func (s *Store) GetDocument(ctx context.Context, id primitive.ObjectID) (models.Document, error) {
+	var doc models.Document
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return doc, err
+	}
+	err = s.getDocumentCollection().FindOne(ctx, live(filter)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc, ErrNotFound
+	}
+	return doc, err
+}
+
+// ListDocuments returns one page of documents matching filter along with the total count
+func (s *Store) ListDocuments(ctx context.Context, filter bson.M, page, limit int64) ([]models.Document, int64, error) {
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter = live(filter)
+	total, err := s.getDocumentCollection().CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "uploaded_at", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+	cur, err := s.getDocumentCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs := []models.Document{}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	return docs, total, nil
+}
+
+// UpdateDocumentStatus records the processing status of a document
+func (s *Store) UpdateDocumentStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	res, err := s.getDocumentCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkDocumentScanned records that the file at storageKey passed the malware scan. A
+// document replaced by a newer version meanwhile is left alone.
+func (s *Store) MarkDocumentScanned(ctx context.Context, id primitive.ObjectID, storageKey string) error {
+	filter, err := scoped(ctx, bson.M{"_id": id, "storage_key": storageKey})
+	if err != nil {
+		return err
+	}
+	_, err = s.getDocumentCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"scanned_at": time.Now()}})
+	return err
+}
+
+// MoveDocument moves a document to folderID and returns it as it was before the move
+func (s *Store) MoveDocument(ctx context.Context, id, folderID primitive.ObjectID) (models.Document, error) {
+	var doc models.Document
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return doc, err
+	}
+	err = s.getDocumentCollection().FindOneAndUpdate(ctx, live(filter),
+		bson.M{"$set": bson.M{"folder_id": folderID}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc, ErrNotFound
+	}
+	return doc, err
+}
+
+// SoftDeleteDocument marks a document deleted by userID; it disappears from every
+// read while its stored blob is kept
+func (s *Store) SoftDeleteDocument(ctx context.Context, id primitive.ObjectID, userID string) (models.Document, error) {
+	var doc models.Document
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return doc, err
+	}
+	err = s.getDocumentCollection().FindOneAndUpdate(ctx, live(filter),
+		bson.M{"$set": bson.M{"deleted_at": time.Now(), "deleted_by": userID}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc, ErrNotFound
+	}
+	return doc, err
+}
+
+// PurgeDocument permanently removes a soft-deleted document record and returns it.
+// ErrNotFound means there is no deleted document with that ID.
+func (s *Store) PurgeDocument(ctx context.Context, id primitive.ObjectID) (models.Document, error) {
+	var doc models.Document
+	filter, err := scoped(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$exists": true}})
+	if err != nil {
+		return doc, err
+	}
+	err = s.getDocumentCollection().FindOneAndDelete(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc, ErrNotFound
+	}
+	return doc, err
+}
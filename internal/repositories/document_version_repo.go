@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getDocumentVersionCollection() *mongo.Collection {
+	coll := s.collection("document_versions")
+	s.indexOnce("document_versions").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "document_id", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			slog.Error("Error creating document version index", "error", err)
+		}
+	})
+	return coll
+}
+
+// ReplaceDocumentVersion archives current as a superseded version and points the
+// document at the new file described by next. ErrConflict means another upload
+// replaced current first.
+func (s *Store) ReplaceDocumentVersion(ctx context.Context, current models.Document, next models.Document) (models.Document, error) {
+	old := models.DocumentVersion{
+		ID:         primitive.NewObjectID(),
+		TenantID:   current.TenantID,
+		DocumentID: current.ID,
+		Version:    current.Version,
+		Name:       current.Name,
+		Size:       current.Size,
+		Type:       current.Type,
+		StorageKey: current.StorageKey,
+		KeyVersion: current.KeyVersion,
+		ScannedAt:  current.ScannedAt,
+		UploadedBy: current.UploadedBy,
+		UploadedAt: current.UploadedAt,
+		ReplacedAt: time.Now(),
+	}
+	if err := stampTenant(ctx, &old.TenantID); err != nil {
+		return next, err
+	}
+	if _, err := s.getDocumentVersionCollection().InsertOne(ctx, old); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return next, ErrConflict
+		}
+		return next, err
+	}
+
+	filter, err := scoped(ctx, bson.M{"_id": current.ID, "version": current.Version})
+	if err != nil {
+		return next, err
+	}
+	var updated models.Document
+	err = s.getDocumentCollection().FindOneAndUpdate(ctx, live(filter),
+		bson.M{"$unset": bson.M{"scanned_at": ""}, "$set": bson.M{
+			"name":        next.Name,
+			"size":        next.Size,
+			"type":        next.Type,
+			"version":     current.Version + 1,
+			"storage_key": next.StorageKey,
+			"key_version": next.KeyVersion,
+			"status":      models.DocumentStatusProcessing,
+			"uploaded_by": next.UploadedBy,
+			"uploaded_at": next.UploadedAt,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		_, _ = s.getDocumentVersionCollection().DeleteOne(ctx, bson.M{"_id": old.ID})
+		return next, ErrConflict
+	}
+	return updated, err
+}
+
+// ListDocumentVersions returns a document's superseded versions, newest first
+func (s *Store) ListDocumentVersions(ctx context.Context, documentID primitive.ObjectID) ([]models.DocumentVersion, error) {
+	filter, err := scoped(ctx, bson.M{"document_id": documentID})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getDocumentVersionCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	versions := []models.DocumentVersion{}
+	if err := cur.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetDocumentVersion returns superseded version of a document of the caller's tenant
+func (s *Store) GetDocumentVersion(ctx context.Context, documentID primitive.ObjectID, version int) (models.DocumentVersion, error) {
+	var v models.DocumentVersion
+	filter, err := scoped(ctx, bson.M{"document_id": documentID, "version": version})
+	if err != nil {
+		return v, err
+	}
+	err = s.getDocumentVersionCollection().FindOne(ctx, filter).Decode(&v)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return v, ErrNotFound
+	}
+	return v, err
+}
+
+// DocumentVersionBytes returns the bytes held by a document's superseded versions
+func (s *Store) DocumentVersionBytes(ctx context.Context, documentID primitive.ObjectID) (int64, error) {
+	versions, err := s.ListDocumentVersions(ctx, documentID)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, v := range versions {
+		total += v.Size
+	}
+	return total, nil
+}
+
+// DeleteDocumentVersions removes a document's superseded versions and returns them so
+// their stored files can be deleted
+func (s *Store) DeleteDocumentVersions(ctx context.Context, documentID primitive.ObjectID) ([]models.DocumentVersion, error) {
+	versions, err := s.ListDocumentVersions(ctx, documentID)
+	if err != nil || len(versions) == 0 {
+		return versions, err
+	}
+	filter, err := scoped(ctx, bson.M{"document_id": documentID})
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.getDocumentVersionCollection().DeleteMany(ctx, filter)
+	return versions, err
+}
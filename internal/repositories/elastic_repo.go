@@ -4,50 +4,201 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
 
-	"UploadDocument-Saas/config"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/breaker"
 	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
 )
 
+const documentIndex = "documents"
+
+// ErrSearchUnavailable means the search cluster failed or its circuit breaker is open.
+// The call may succeed later.
+var ErrSearchUnavailable = errors.New("search is unavailable")
+
+// SearchAvailable reports whether calls to the search cluster are being let through
+func (s *Store) SearchAvailable() bool {
+	return s.searchBreaker.State() != breaker.Open
+}
+
+// callSearch sends a request to the search cluster through its breaker. Transport
+// failures and server errors count against the cluster and are returned as
+// ErrSearchUnavailable; other error responses are left to the caller.
+func (s *Store) callSearch(do func() (*esapi.Response, error)) (*esapi.Response, error) {
+	var res *esapi.Response
+	err := s.searchBreaker.Do(func() error {
+		var err error
+		res, err = do()
+		if err == nil && res.StatusCode >= 500 {
+			res.Body.Close()
+			return fmt.Errorf("elasticsearch: %s", res.Status())
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSearchUnavailable, err)
+	}
+	return res, nil
+}
+
+// ensureDocumentIndex creates the documents index with tenant_id mapped as a keyword
+// so that tenant filters match exactly instead of against analyzed text. It is retried
+// on every call until it succeeds.
+func (s *Store) ensureDocumentIndex(ctx context.Context) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if s.indexReady {
+		return nil
+	}
+	client := s.search
+	res, err := s.callSearch(func() (*esapi.Response, error) {
+		return client.Indices.Exists([]string{documentIndex}, client.Indices.Exists.WithContext(ctx))
+	})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == 404 {
+		mapping := `{"mappings":{"properties":{"tenant_id":{"type":"keyword"},"folder_id":{"type":"keyword"}}}}`
+		res, err = s.callSearch(func() (*esapi.Response, error) {
+			return client.Indices.Create(
+				documentIndex,
+				client.Indices.Create.WithBody(strings.NewReader(mapping)),
+				client.Indices.Create.WithContext(ctx),
+			)
+		})
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		// Another replica may have created it first
+		if res.IsError() && res.StatusCode != 400 {
+			return fmt.Errorf("elasticsearch create index: %s", res.Status())
+		}
+	}
+	s.indexReady = true
+	return nil
+}
+
 // IndexDocument concurrently indexes a document in Elasticsearch
-func IndexDocument(ctx context.Context, doc models.Document, wg *sync.WaitGroup, errCh chan<- error) {
+func (s *Store) IndexDocument(ctx context.Context, doc models.Document, wg *sync.WaitGroup, errCh chan<- error) {
 	defer wg.Done()
-	client := config.GetElasticClient()
+	if err := stampTenant(ctx, &doc.TenantID); err != nil {
+		errCh <- err
+		return
+	}
+	if err := s.ensureDocumentIndex(ctx); err != nil {
+		errCh <- err
+		return
+	}
+	client := s.search
 	body, _ := json.Marshal(doc)
-	res, err := client.Index(
-		"documents",
-		bytes.NewReader(body),
-		client.Index.WithContext(ctx),
-	)
+	res, err := s.callSearch(func() (*esapi.Response, error) {
+		return client.Index(
+			documentIndex,
+			bytes.NewReader(body),
+			client.Index.WithDocumentID(doc.ID.Hex()),
+			client.Index.WithContext(ctx),
+		)
+	})
 	if err != nil {
 		errCh <- err
 		return
 	}
 	defer res.Body.Close()
-	log.Println("Indexed document:", doc.ID)
+	if res.IsError() {
+		errCh <- fmt.Errorf("elasticsearch index %s: %s", doc.ID.Hex(), res.Status())
+		return
+	}
+	slog.DebugContext(ctx, "Indexed document", "document_id", doc.ID.Hex())
+}
+
+// UpdateDocumentIndex applies a partial update to an indexed document of the caller's tenant
+func (s *Store) UpdateDocumentIndex(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	if _, err := tenant.Require(ctx); err != nil {
+		return err
+	}
+	client := s.search
+	body, err := json.Marshal(map[string]interface{}{"doc": fields})
+	if err != nil {
+		return err
+	}
+	res, err := s.callSearch(func() (*esapi.Response, error) {
+		return client.Update(documentIndex, id.Hex(), bytes.NewReader(body), client.Update.WithContext(ctx))
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("elasticsearch update %s: %s", id.Hex(), res.Status())
+	}
+	return nil
+}
+
+// DeleteDocumentIndex removes a document of the caller's tenant from the search index
+func (s *Store) DeleteDocumentIndex(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := tenant.Require(ctx); err != nil {
+		return err
+	}
+	client := s.search
+	res, err := s.callSearch(func() (*esapi.Response, error) {
+		return client.Delete(documentIndex, id.Hex(), client.Delete.WithContext(ctx))
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("elasticsearch delete %s: %s", id.Hex(), res.Status())
+	}
+	return nil
 }
 
-// SearchDocuments concurrently searches documents in Elasticsearch
-func SearchDocuments(ctx context.Context, query map[string]interface{}, wg *sync.WaitGroup, docsCh chan<- models.Document, errCh chan<- error) {
+// SearchDocuments concurrently searches documents in Elasticsearch.
+// The caller's query is wrapped in a bool filter on the tenant from ctx.
+func (s *Store) SearchDocuments(ctx context.Context, query map[string]interface{}, wg *sync.WaitGroup, docsCh chan<- models.Document, errCh chan<- error) {
 	defer wg.Done()
-	client := config.GetElasticClient()
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	client := s.search
 	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+	if err := json.NewEncoder(&buf).Encode(tenantQuery(query, tenantID)); err != nil {
 		errCh <- err
 		return
 	}
-	res, err := client.Search(
-		client.Search.WithContext(ctx),
-		client.Search.WithIndex("documents"),
-		client.Search.WithBody(&buf),
-	)
+	res, err := s.callSearch(func() (*esapi.Response, error) {
+		return client.Search(
+			client.Search.WithContext(ctx),
+			client.Search.WithIndex(documentIndex),
+			client.Search.WithBody(&buf),
+			client.Search.WithSourceExcludes("content"),
+		)
+	})
 	if err != nil {
 		errCh <- err
 		return
 	}
 	defer res.Body.Close()
+	// Nothing has been indexed yet
+	if res.StatusCode == 404 {
+		return
+	}
+	if res.IsError() {
+		errCh <- fmt.Errorf("elasticsearch search: %s", res.Status())
+		return
+	}
 	var r struct {
 		Hits struct {
 			Hits []struct {
@@ -60,6 +211,28 @@ func SearchDocuments(ctx context.Context, query map[string]interface{}, wg *sync
 		return
 	}
 	for _, hit := range r.Hits.Hits {
+		if hit.Source.TenantID != tenantID {
+			continue
+		}
 		docsCh <- hit.Source
 	}
 }
+
+// tenantQuery returns a copy of a search body whose query only matches tenantID's documents
+func tenantQuery(body map[string]interface{}, tenantID string) map[string]interface{} {
+	out := make(map[string]interface{}, len(body)+1)
+	for k, v := range body {
+		out[k] = v
+	}
+	inner, ok := body["query"]
+	if !ok {
+		inner = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	out["query"] = map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   inner,
+			"filter": []interface{}{map[string]interface{}{"term": map[string]interface{}{"tenant_id": tenantID}}},
+		},
+	}
+	return out
+}
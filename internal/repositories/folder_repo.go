@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getFolderCollection() *mongo.Collection {
+	return s.collection("folders")
+}
+
+// InsertFolder stores a new folder in the caller's tenant
+func (s *Store) InsertFolder(ctx context.Context, folder *models.Folder) error {
+	if err := stampTenant(ctx, &folder.TenantID); err != nil {
+		return err
+	}
+	if folder.ID.IsZero() {
+		folder.ID = primitive.NewObjectID()
+	}
+	_, err := s.getFolderCollection().InsertOne(ctx, folder)
+	return err
+}
+
+// GetFolder returns a folder by ID within the caller's tenant
+func (s *Store) GetFolder(ctx context.Context, id primitive.ObjectID) (models.Folder, error) {
+	var folder models.Folder
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return folder, err
+	}
+	err = s.getFolderCollection().FindOne(ctx, filter).Decode(&folder)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return folder, ErrNotFound
+	}
+	return folder, err
+}
+
+// ListFolders returns every folder in the caller's tenant
+func (s *Store) ListFolders(ctx context.Context) ([]models.Folder, error) {
+	filter, err := scoped(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getFolderCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	folders := []models.Folder{}
+	if err := cur.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// IncrementFolderDocumentCount adjusts a folder's document counter by delta
+func (s *Store) IncrementFolderDocumentCount(ctx context.Context, id primitive.ObjectID, delta int) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	_, err = s.getFolderCollection().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"document_count": delta}})
+	return err
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+// maxFolderDepth bounds walks up the folder tree
+const maxFolderDepth = 64
+
+func (s *Store) getFolderWatchCollection() *mongo.Collection {
+	coll := s.collection("folder_watches")
+	s.indexOnce("folder_watches").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "folder_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "folder_id", Value: 1}}},
+		})
+		if err != nil {
+			slog.Error("Error creating folder watch indexes", "error", err)
+		}
+	})
+	return coll
+}
+
+// UpsertFolderWatch starts or updates watch.UserID's watch on watch.FolderID and returns it
+func (s *Store) UpsertFolderWatch(ctx context.Context, watch models.FolderWatch) (models.FolderWatch, error) {
+	if err := stampTenant(ctx, &watch.TenantID); err != nil {
+		return watch, err
+	}
+	filter, err := scoped(ctx, bson.M{"user_id": watch.UserID, "folder_id": watch.FolderID})
+	if err != nil {
+		return watch, err
+	}
+	var out models.FolderWatch
+	err = s.getFolderWatchCollection().FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$set":         bson.M{"recursive": watch.Recursive},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": watch.CreatedAt},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&out)
+	return out, err
+}
+
+// DeleteFolderWatch stops userID watching folderID
+func (s *Store) DeleteFolderWatch(ctx context.Context, userID string, folderID primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"user_id": userID, "folder_id": folderID})
+	if err != nil {
+		return err
+	}
+	res, err := s.getFolderWatchCollection().DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListFolderWatches returns what userID watches
+func (s *Store) ListFolderWatches(ctx context.Context, userID string) ([]models.FolderWatch, error) {
+	filter, err := scoped(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getFolderWatchCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	watches := []models.FolderWatch{}
+	if err := cur.All(ctx, &watches); err != nil {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// FolderWatchers returns the watches covering folderID: watches on the folder itself
+// and recursive watches on any of its ancestors, including the tenant root
+func (s *Store) FolderWatchers(ctx context.Context, folderID primitive.ObjectID) ([]models.FolderWatch, error) {
+	ancestors, err := s.folderAncestors(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := scoped(ctx, bson.M{"$or": bson.A{
+		bson.M{"folder_id": folderID},
+		bson.M{"folder_id": bson.M{"$in": ancestors}, "recursive": true},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getFolderWatchCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	watches := []models.FolderWatch{}
+	if err := cur.All(ctx, &watches); err != nil {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// folderAncestors returns the IDs of the folders above folderID, ending with the tenant root
+func (s *Store) folderAncestors(ctx context.Context, folderID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var ancestors []primitive.ObjectID
+	current := folderID
+	for depth := 0; !current.IsZero() && depth < maxFolderDepth; depth++ {
+		folder, err := s.GetFolder(ctx, current)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if folder.ParentID == nil {
+			break
+		}
+		current = *folder.ParentID
+		ancestors = append(ancestors, current)
+	}
+	if !folderID.IsZero() {
+		ancestors = append(ancestors, primitive.NilObjectID)
+	}
+	return ancestors, nil
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getIndexTaskCollection() *mongo.Collection {
+	coll := s.collection("index_tasks")
+	s.indexOnce("index_tasks").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "next_attempt_at", Value: 1}}},
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "document_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		})
+		if err != nil {
+			slog.Error("Error creating index task indexes", "error", err)
+		}
+	})
+	return coll
+}
+
+// QueueIndexTask queues a document of the caller's tenant to be indexed from payload at
+// next. Queueing a document that is already waiting replaces its payload.
+func (s *Store) QueueIndexTask(ctx context.Context, documentID primitive.ObjectID, payload []byte, reason string, next time.Time) error {
+	filter, err := scoped(ctx, bson.M{"document_id": documentID})
+	if err != nil {
+		return err
+	}
+	_, err = s.getIndexTaskCollection().UpdateOne(ctx, filter,
+		bson.M{
+			"$set":         bson.M{"payload": payload, "last_error": reason, "next_attempt_at": next},
+			"$setOnInsert": bson.M{"attempts": 0, "created_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// ClaimDueIndexTask leases the task that has been due longest, across all tenants, so
+// that only one replica attempts it until lease has passed. It returns ErrNotFound when
+// nothing is due.
+func (s *Store) ClaimDueIndexTask(ctx context.Context, now time.Time, lease time.Duration) (models.IndexTask, error) {
+	var task models.IndexTask
+	err := s.getIndexTaskCollection().FindOneAndUpdate(ctx,
+		bson.M{"next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return task, ErrNotFound
+	}
+	return task, err
+}
+
+// RescheduleIndexTask records why an attempt failed and when to try again
+func (s *Store) RescheduleIndexTask(ctx context.Context, id primitive.ObjectID, reason string, next time.Time) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	_, err = s.getIndexTaskCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_error": reason, "next_attempt_at": next}})
+	return err
+}
+
+// DeleteIndexTask removes a task of the caller's tenant once it no longer needs doing
+func (s *Store) DeleteIndexTask(ctx context.Context, id primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	_, err = s.getIndexTaskCollection().DeleteOne(ctx, filter)
+	return err
+}
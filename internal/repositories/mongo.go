@@ -0,0 +1,72 @@
+// Package repositories stores the application's records in MongoDB and its search
+// index in Elasticsearch, scoping every tenant-owned query to the tenant in the context.
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"UploadDocument-Saas/internal/breaker"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// ErrNotFound is returned when a record does not exist in the caller's tenant
+var ErrNotFound = errors.New("record not found")
+
+// Store reads and writes the records in one MongoDB database and the search index in
+// one Elasticsearch cluster
+type Store struct {
+	db     *mongo.Database
+	search *elasticsearch.Client
+	// searchBreaker stops calling the cluster for a while after repeated failures
+	searchBreaker *breaker.Breaker
+
+	indexMu    sync.Mutex
+	indexReady bool
+	// indexes holds a *sync.Once per collection guarding the creation of its indexes
+	indexes sync.Map
+	// auditLocks serializes appends per tenant within this process, a tenant always
+	// taking the same stripe; the unique (tenant_id, seq) index catches races between
+	// replicas
+	auditLocks [auditLockStripes]sync.Mutex
+}
+
+// New returns a store over db and the search cluster of search
+func New(db *mongo.Database, search *elasticsearch.Client) *Store {
+	return &Store{
+		db:            db,
+		search:        search,
+		searchBreaker: breaker.New("elasticsearch", 5, 30*time.Second),
+	}
+}
+
+func (s *Store) collection(name string) *mongo.Collection {
+	return s.db.Collection(name)
+}
+
+// indexOnce guards the creation of the indexes of the named collection
+func (s *Store) indexOnce(name string) *sync.Once {
+	once, _ := s.indexes.LoadOrStore(name, new(sync.Once))
+	return once.(*sync.Once)
+}
+
+// scoped returns a copy of filter restricted to the tenant carried by ctx.
+// Every tenant-owned query must go through it.
+func scoped(ctx context.Context, filter bson.M) (bson.M, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := bson.M{}
+	for k, v := range filter {
+		out[k] = v
+	}
+	out["tenant_id"] = tenantID
+	return out, nil
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// testStore returns a store over a fresh database on the deployment named by
+// MONGO_TEST_URI, dropped when the test ends. Without it the test is skipped.
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("repositories_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return New(db, nil)
+}
+
+func insertDocument(t *testing.T, store *Store, ctx context.Context, doc models.Document) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	wg.Add(1)
+	store.InsertDocument(ctx, doc, &wg, errCh)
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordsAreInvisibleToOtherTenants(t *testing.T) {
+	store := testStore(t)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	folder := models.Folder{Name: "contracts", CreatedAt: time.Now()}
+	if err := store.InsertFolder(acme, &folder); err != nil {
+		t.Fatal(err)
+	}
+	doc := models.Document{ID: primitive.NewObjectID(), Name: "nda.pdf", FolderID: folder.ID, UploadedAt: time.Now()}
+	insertDocument(t, store, acme, doc)
+
+	if _, err := store.GetFolder(acme, folder.ID); err != nil {
+		t.Fatalf("owner cannot read its folder: %v", err)
+	}
+	if _, err := store.GetDocument(acme, doc.ID); err != nil {
+		t.Fatalf("owner cannot read its document: %v", err)
+	}
+
+	// The other tenant knows the IDs but must not be able to tell the records exist
+	if _, err := store.GetFolder(globex, folder.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFolder from another tenant = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetDocument(globex, doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDocument from another tenant = %v, want ErrNotFound", err)
+	}
+	if err := store.UpdateDocumentStatus(globex, doc.ID, models.DocumentStatusFailed); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateDocumentStatus from another tenant = %v, want ErrNotFound", err)
+	}
+	if docs, total, err := store.ListDocuments(globex, bson.M{"folder_id": folder.ID}, 1, 10); err != nil || total != 0 || len(docs) != 0 {
+		t.Errorf("ListDocuments from another tenant = %d documents (total %d), %v; want none", len(docs), total, err)
+	}
+	if got, err := store.GetDocument(acme, doc.ID); err != nil || got.Status == models.DocumentStatusFailed {
+		t.Errorf("another tenant changed the document: status %q, %v", got.Status, err)
+	}
+}
+
+func TestCorrectUsageKeepsChangesMadeSinceTheSnapshot(t *testing.T) {
+	store := testStore(t)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	folderID := primitive.NewObjectID()
+	doc := models.Document{ID: primitive.NewObjectID(), Name: "a.pdf", Size: 100, FolderID: folderID, UploadedAt: time.Now()}
+	insertDocument(t, store, ctx, doc)
+	// The counters have drifted: they claim 300 bytes in two documents
+	if err := store.AddUsage(ctx, folderID, 300, 2); err != nil {
+		t.Fatal(err)
+	}
+	stale := primitive.NewObjectID()
+	if err := store.AddUsage(ctx, stale, 50, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := store.GetTenantUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshotFolders, err := store.ListFolderUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders, err := store.ComputeUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An upload reserves its bytes while the reconciliation runs
+	if err := store.ReserveUsage(ctx, folderID, 40, 1, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := store.CorrectUsage(ctx, snapshot, snapshotFolders, folders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Bytes != 140 || usage.Documents != 2 || usage.ReconciledAt == nil {
+		t.Errorf("tenant usage = %+v, want 140 bytes in 2 documents, reconciled", usage)
+	}
+	rows, err := store.ListFolderUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].FolderID != folderID || rows[0].Bytes != 140 || rows[0].Documents != 2 {
+		t.Errorf("folder usage = %+v, want only %s with 140 bytes in 2 documents", rows, folderID.Hex())
+	}
+}
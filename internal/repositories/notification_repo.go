@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getNotificationCollection() *mongo.Collection {
+	coll := s.collection("notifications")
+	s.indexOnce("notifications").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		})
+		if err != nil {
+			slog.Error("Error creating notification index", "error", err)
+		}
+	})
+	return coll
+}
+
+func (s *Store) getNotificationPreferencesCollection() *mongo.Collection {
+	coll := s.collection("notification_preferences")
+	s.indexOnce("notification_preferences").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "next_digest_at", Value: 1}}},
+		})
+		if err != nil {
+			slog.Error("Error creating notification preference indexes", "error", err)
+		}
+	})
+	return coll
+}
+
+// InsertNotification stores a notification in the caller's tenant
+func (s *Store) InsertNotification(ctx context.Context, n *models.Notification) error {
+	if err := stampTenant(ctx, &n.TenantID); err != nil {
+		return err
+	}
+	if n.ID.IsZero() {
+		n.ID = primitive.NewObjectID()
+	}
+	_, err := s.getNotificationCollection().InsertOne(ctx, n)
+	return err
+}
+
+// ListNotifications returns userID's notifications newest first, optionally only unread
+// ones and only those created before the notification before (for paging)
+func (s *Store) ListNotifications(ctx context.Context, userID string, unreadOnly bool, before primitive.ObjectID, limit int64) ([]models.Notification, error) {
+	match := bson.M{"user_id": userID}
+	if unreadOnly {
+		match["read_at"] = bson.M{"$exists": false}
+	}
+	if !before.IsZero() {
+		match["_id"] = bson.M{"$lt": before}
+	}
+	filter, err := scoped(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cur, err := s.getNotificationCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.Notification{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CountUnreadNotifications counts userID's unread notifications
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID string) (int64, error) {
+	filter, err := scoped(ctx, bson.M{"user_id": userID, "read_at": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	return s.getNotificationCollection().CountDocuments(ctx, filter)
+}
+
+// MarkNotificationsRead marks userID's notifications read; nil ids marks all of them.
+// It returns how many changed.
+func (s *Store) MarkNotificationsRead(ctx context.Context, userID string, ids []primitive.ObjectID) (int64, error) {
+	match := bson.M{"user_id": userID, "read_at": bson.M{"$exists": false}}
+	if ids != nil {
+		match["_id"] = bson.M{"$in": ids}
+	}
+	filter, err := scoped(ctx, match)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.getNotificationCollection().UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// PendingDigestNotifications returns userID's unread notifications not yet emailed, oldest first
+func (s *Store) PendingDigestNotifications(ctx context.Context, userID string, limit int64) ([]models.Notification, error) {
+	filter, err := scoped(ctx, bson.M{
+		"user_id":    userID,
+		"read_at":    bson.M{"$exists": false},
+		"emailed_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	cur, err := s.getNotificationCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.Notification{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MarkNotificationsEmailed records that notifications went out in a digest
+func (s *Store) MarkNotificationsEmailed(ctx context.Context, ids []primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	_, err = s.getNotificationCollection().UpdateMany(ctx, filter, bson.M{"$set": bson.M{"emailed_at": time.Now()}})
+	return err
+}
+
+// GetNotificationPreferences returns userID's preferences, or the defaults when none are stored
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID string) (models.NotificationPreferences, error) {
+	prefs := models.NotificationPreferences{UserID: userID, Muted: []string{}, EmailDigest: models.DigestOff}
+	filter, err := scoped(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return prefs, err
+	}
+	err = s.getNotificationPreferencesCollection().FindOne(ctx, filter).Decode(&prefs)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return prefs, nil
+	}
+	return prefs, err
+}
+
+// SaveNotificationPreferences stores prefs for their user in the caller's tenant
+func (s *Store) SaveNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	if err := stampTenant(ctx, &prefs.TenantID); err != nil {
+		return err
+	}
+	filter, err := scoped(ctx, bson.M{"user_id": prefs.UserID})
+	if err != nil {
+		return err
+	}
+	_, err = s.getNotificationPreferencesCollection().ReplaceOne(ctx, filter, prefs, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ClaimDueDigest finds a user, across all tenants, whose email digest is due and moves
+// their next digest to next, so that only one sender handles it. It returns
+// ErrNotFound when no digest is due.
+func (s *Store) ClaimDueDigest(ctx context.Context, now time.Time, next func(models.NotificationPreferences) time.Time) (models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	coll := s.getNotificationPreferencesCollection()
+	err := coll.FindOne(ctx, bson.M{"next_digest_at": bson.M{"$lte": now}},
+		options.FindOne().SetSort(bson.D{{Key: "next_digest_at", Value: 1}}),
+	).Decode(&prefs)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return prefs, ErrNotFound
+	}
+	if err != nil {
+		return prefs, err
+	}
+	res, err := coll.UpdateOne(ctx,
+		bson.M{"tenant_id": prefs.TenantID, "user_id": prefs.UserID, "next_digest_at": prefs.NextDigestAt},
+		bson.M{"$set": bson.M{"next_digest_at": next(prefs)}},
+	)
+	if err != nil {
+		return prefs, err
+	}
+	if res.ModifiedCount == 0 {
+		return prefs, ErrConflict
+	}
+	return prefs, nil
+}
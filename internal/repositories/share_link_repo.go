@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+// ErrShareUnavailable is returned when a share link is revoked, expired or out of downloads
+var ErrShareUnavailable = errors.New("share link is no longer available")
+
+func (s *Store) getShareLinkCollection() *mongo.Collection {
+	coll := s.collection("share_links")
+	s.indexOnce("share_links").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			slog.Error("Error creating share link index", "error", err)
+		}
+	})
+	return coll
+}
+
+// InsertShareLink stores a new share link in the caller's tenant
+func (s *Store) InsertShareLink(ctx context.Context, link *models.ShareLink) error {
+	if err := stampTenant(ctx, &link.TenantID); err != nil {
+		return err
+	}
+	if link.ID.IsZero() {
+		link.ID = primitive.NewObjectID()
+	}
+	_, err := s.getShareLinkCollection().InsertOne(ctx, link)
+	return err
+}
+
+// ListShareLinks returns the share links created by userID in the caller's tenant
+func (s *Store) ListShareLinks(ctx context.Context, userID string) ([]models.ShareLink, error) {
+	filter, err := scoped(ctx, bson.M{"created_by": userID})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getShareLinkCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	links := []models.ShareLink{}
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// RevokeShareLink marks a share link as revoked. An empty userID revokes
+// regardless of creator (used for tenant admins).
+func (s *Store) RevokeShareLink(ctx context.Context, id primitive.ObjectID, userID string) (models.ShareLink, error) {
+	var link models.ShareLink
+	match := bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}}
+	if userID != "" {
+		match["created_by"] = userID
+	}
+	filter, err := scoped(ctx, match)
+	if err != nil {
+		return link, err
+	}
+	err = s.getShareLinkCollection().FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return link, ErrNotFound
+	}
+	return link, err
+}
+
+// GetReceivedShareLink returns a share link of the caller's tenant that names userID
+// as a recipient
+func (s *Store) GetReceivedShareLink(ctx context.Context, id primitive.ObjectID, userID string) (models.ShareLink, error) {
+	var link models.ShareLink
+	filter, err := scoped(ctx, bson.M{"_id": id, "recipients": userID})
+	if err != nil {
+		return link, err
+	}
+	err = s.getShareLinkCollection().FindOne(ctx, filter).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return link, ErrNotFound
+	}
+	return link, err
+}
+
+// FindShareLinkByTokenHash looks a share link up by its token hash. This is the one
+// deliberately unscoped lookup: the link itself is what establishes the tenant for
+// anonymous /s/:token requests.
+func (s *Store) FindShareLinkByTokenHash(ctx context.Context, tokenHash string) (models.ShareLink, error) {
+	var link models.ShareLink
+	err := s.getShareLinkCollection().FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return link, ErrNotFound
+	}
+	return link, err
+}
+
+// ConsumeShareDownload atomically counts one download against a share link,
+// failing if the link is revoked, expired or has reached its download limit
+func (s *Store) ConsumeShareDownload(ctx context.Context, id primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{
+		"_id":        id,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+		"$or": bson.A{
+			bson.M{"max_downloads": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$download_count", "$max_downloads"}}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	res, err := s.getShareLinkCollection().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"download_count": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrShareUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+// ErrConflict is returned when a write loses a race against a concurrent writer
+var ErrConflict = errors.New("record already exists")
+
+func (s *Store) getTenantKeyCollection() *mongo.Collection {
+	coll := s.collection("tenant_keys")
+	s.indexOnce("tenant_keys").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			slog.Error("Error creating tenant key index", "error", err)
+		}
+	})
+	return coll
+}
+
+// InsertTenantKey stores a new wrapped data key; ErrConflict means another writer created that version first
+func (s *Store) InsertTenantKey(ctx context.Context, key *models.TenantKey) error {
+	if err := stampTenant(ctx, &key.TenantID); err != nil {
+		return err
+	}
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	_, err := s.getTenantKeyCollection().InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetTenantKey returns a specific data key version for the caller's tenant
+func (s *Store) GetTenantKey(ctx context.Context, version int) (models.TenantKey, error) {
+	var key models.TenantKey
+	filter, err := scoped(ctx, bson.M{"version": version})
+	if err != nil {
+		return key, err
+	}
+	err = s.getTenantKeyCollection().FindOne(ctx, filter).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return key, ErrNotFound
+	}
+	return key, err
+}
+
+// GetActiveTenantKey returns the newest active data key for the caller's tenant
+func (s *Store) GetActiveTenantKey(ctx context.Context) (models.TenantKey, error) {
+	var key models.TenantKey
+	filter, err := scoped(ctx, bson.M{"active": true})
+	if err != nil {
+		return key, err
+	}
+	err = s.getTenantKeyCollection().FindOne(ctx, filter,
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}),
+	).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return key, ErrNotFound
+	}
+	return key, err
+}
+
+// ListTenantKeys returns every data key version of the caller's tenant
+func (s *Store) ListTenantKeys(ctx context.Context) ([]models.TenantKey, error) {
+	filter, err := scoped(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getTenantKeyCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.TenantKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeactivateTenantKeys marks every data key of the caller's tenant below version as no longer used for new writes
+func (s *Store) DeactivateTenantKeys(ctx context.Context, below int) error {
+	filter, err := scoped(ctx, bson.M{"version": bson.M{"$lt": below}})
+	if err != nil {
+		return err
+	}
+	_, err = s.getTenantKeyCollection().UpdateMany(ctx, filter, bson.M{"$set": bson.M{"active": false}})
+	return err
+}
+
+// UpdateWrappedTenantKey replaces the wrapped form of a data key after re-wrapping it under a new master key
+func (s *Store) UpdateWrappedTenantKey(ctx context.Context, id primitive.ObjectID, wrapped []byte, masterVersion int) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	_, err = s.getTenantKeyCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"wrapped_key":    wrapped,
+		"master_version": masterVersion,
+		"rewrapped_at":   time.Now(),
+	}})
+	return err
+}
+
+// TenantsWithStaleKeys lists tenants holding data keys wrapped by a master key older than
+// masterVersion. It is unscoped because master key rotation is a platform-wide job.
+func (s *Store) TenantsWithStaleKeys(ctx context.Context, masterVersion int) ([]string, error) {
+	values, err := s.getTenantKeyCollection().Distinct(ctx, "tenant_id", bson.M{"master_version": bson.M{"$lt": masterVersion}})
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]string, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			tenants = append(tenants, id)
+		}
+	}
+	return tenants, nil
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+func TestScopedPinsTheCallersTenant(t *testing.T) {
+	id := primitive.NewObjectID()
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	// A tenant_id smuggled into the filter must not widen or move the scope
+	filter := bson.M{"_id": id, "tenant_id": "globex"}
+	got, err := scoped(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.M{"_id": id, "tenant_id": "acme"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("scoped = %v, want %v", got, want)
+	}
+	if filter["tenant_id"] != "globex" {
+		t.Errorf("scoped modified the caller's filter: %v", filter)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"no tenant":      context.Background(),
+		"invalid tenant": tenant.WithTenant(context.Background(), "acme/../globex"),
+	} {
+		if _, err := scoped(ctx, bson.M{"_id": id}); err == nil {
+			t.Errorf("%s: scoped returned a filter", name)
+		}
+	}
+}
+
+func TestStampTenantRefusesRecordsOfAnotherTenant(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	tests := []struct {
+		record string
+		want   error
+	}{
+		{"", nil},
+		{"acme", nil},
+		{"globex", ErrTenantMismatch},
+	}
+	for _, tt := range tests {
+		record := tt.record
+		err := stampTenant(ctx, &record)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("stampTenant(%q) = %v, want %v", tt.record, err, tt.want)
+		}
+		if err == nil && record != "acme" {
+			t.Errorf("stampTenant(%q) stamped %q, want acme", tt.record, record)
+		}
+	}
+
+	record := ""
+	if err := stampTenant(context.Background(), &record); !errors.Is(err, tenant.ErrMissing) {
+		t.Errorf("stampTenant without a tenant = %v, want %v", err, tenant.ErrMissing)
+	}
+}
+
+// The store below has no database: a repository call that reached MongoDB instead
+// of refusing on its tenant first would panic
+
+func TestRepositoryInsertsRefuseRecordsOfAnotherTenant(t *testing.T) {
+	store := &Store{}
+	globex := tenant.WithTenant(context.Background(), "globex")
+	inserts := map[string]func() error{
+		"InsertFolder":    func() error { return store.InsertFolder(globex, &models.Folder{TenantID: "acme"}) },
+		"InsertComment":   func() error { return store.InsertComment(globex, &models.Comment{TenantID: "acme"}) },
+		"InsertWebhook":   func() error { return store.InsertWebhook(globex, &models.Webhook{TenantID: "acme"}) },
+		"InsertTenantKey": func() error { return store.InsertTenantKey(globex, &models.TenantKey{TenantID: "acme"}) },
+		"AppendAuditEvent": func() error {
+			return store.AppendAuditEvent(globex, &models.AuditEvent{TenantID: "acme"})
+		},
+		"InsertDocument": func() error {
+			var wg sync.WaitGroup
+			errCh := make(chan error, 1)
+			wg.Add(1)
+			store.InsertDocument(globex, models.Document{TenantID: "acme"}, &wg, errCh)
+			wg.Wait()
+			return <-errCh
+		},
+	}
+	for name, insert := range inserts {
+		if err := insert(); !errors.Is(err, ErrTenantMismatch) {
+			t.Errorf("%s of an acme record from globex = %v, want %v", name, err, ErrTenantMismatch)
+		}
+	}
+}
+
+func TestRepositoryCallsRequireATenant(t *testing.T) {
+	store := &Store{}
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	calls := map[string]func() error{
+		"GetFolder":            func() error { _, err := store.GetFolder(ctx, id); return err },
+		"ListFolders":          func() error { _, err := store.ListFolders(ctx); return err },
+		"GetDocument":          func() error { _, err := store.GetDocument(ctx, id); return err },
+		"UpdateDocumentStatus": func() error { return store.UpdateDocumentStatus(ctx, id, models.DocumentStatusFailed) },
+		"ListDocuments":        func() error { _, _, err := store.ListDocuments(ctx, bson.M{"tenant_id": "acme"}, 1, 10); return err },
+		"GetWebhook":           func() error { _, err := store.GetWebhook(ctx, id); return err },
+		"ListTenantKeys":       func() error { _, err := store.ListTenantKeys(ctx); return err },
+		"GetTenantUsage":       func() error { _, err := store.GetTenantUsage(ctx); return err },
+		"ListFolderUsage":      func() error { _, err := store.ListFolderUsage(ctx); return err },
+		"InsertFolder":         func() error { return store.InsertFolder(ctx, &models.Folder{TenantID: "acme"}) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, tenant.ErrMissing) {
+			t.Errorf("%s without a tenant = %v, want %v", name, err, tenant.ErrMissing)
+		}
+	}
+}
+
+func TestTenantQueryAddsTermFilter(t *testing.T) {
+	match := map[string]interface{}{"match": map[string]interface{}{"content": "invoice"}}
+	body := map[string]interface{}{"query": match, "size": 20}
+
+	got := tenantQuery(body, "acme")
+	want := map[string]interface{}{
+		"size": 20,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   match,
+				"filter": []interface{}{map[string]interface{}{"term": map[string]interface{}{"tenant_id": "acme"}}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tenantQuery = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(body["query"], match) {
+		t.Errorf("tenantQuery modified the caller's body: %v", body)
+	}
+
+	// A body without a query still only matches the tenant's documents
+	all := tenantQuery(map[string]interface{}{}, "globex")
+	boolQuery := all["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	if !reflect.DeepEqual(boolQuery["must"], map[string]interface{}{"match_all": map[string]interface{}{}}) {
+		t.Errorf("must = %v, want match_all", boolQuery["must"])
+	}
+	if !reflect.DeepEqual(boolQuery["filter"], []interface{}{map[string]interface{}{"term": map[string]interface{}{"tenant_id": "globex"}}}) {
+		t.Errorf("filter = %v, want a tenant_id term for globex", boolQuery["filter"])
+	}
+}
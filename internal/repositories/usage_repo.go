@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// ErrQuotaExceeded is returned when reserving usage would take a tenant past its quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+func (s *Store) getUsageCollection() *mongo.Collection {
+	coll := s.collection("usage")
+	s.indexOnce("usage").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "scope", Value: 1}, {Key: "folder_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			slog.Error("Error creating usage index", "error", err)
+		}
+	})
+	return coll
+}
+
+func usageKey(tenantID, scope string, folderID primitive.ObjectID) bson.M {
+	return bson.M{"tenant_id": tenantID, "scope": scope, "folder_id": folderID}
+}
+
+func usageDelta(bytes, documents int64) bson.M {
+	return bson.M{
+		"$inc": bson.M{"bytes": bytes, "documents": documents},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+}
+
+// ReserveUsage atomically adds bytes and documents to the caller's tenant and to folderID,
+// failing with ErrQuotaExceeded if the tenant would go past maxBytes or maxDocuments.
+// A limit of zero or less is unlimited. Undo a reservation with AddUsage.
+func (s *Store) ReserveUsage(ctx context.Context, folderID primitive.ObjectID, bytes, documents, maxBytes, maxDocuments int64) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	coll := s.getUsageCollection()
+	key := usageKey(tenantID, models.UsageScopeTenant, primitive.NilObjectID)
+
+	// Make sure the tenant row exists so the guarded update below can match it
+	_, err = coll.UpdateOne(ctx, key,
+		bson.M{"$setOnInsert": bson.M{"bytes": int64(0), "documents": int64(0), "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	guarded := bson.M{}
+	for k, v := range key {
+		guarded[k] = v
+	}
+	if maxBytes > 0 && bytes > 0 {
+		guarded["bytes"] = bson.M{"$lte": maxBytes - bytes}
+	}
+	if maxDocuments > 0 && documents > 0 {
+		guarded["documents"] = bson.M{"$lte": maxDocuments - documents}
+	}
+	res, err := coll.UpdateOne(ctx, guarded, usageDelta(bytes, documents))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrQuotaExceeded
+	}
+
+	_, err = coll.UpdateOne(ctx, usageKey(tenantID, models.UsageScopeFolder, folderID),
+		usageDelta(bytes, documents), options.Update().SetUpsert(true))
+	return err
+}
+
+// AddUsage adds bytes and documents, which may be negative, to the caller's tenant and to folderID
+func (s *Store) AddUsage(ctx context.Context, folderID primitive.ObjectID, bytes, documents int64) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	coll := s.getUsageCollection()
+	for _, key := range []bson.M{
+		usageKey(tenantID, models.UsageScopeTenant, primitive.NilObjectID),
+		usageKey(tenantID, models.UsageScopeFolder, folderID),
+	} {
+		if _, err := coll.UpdateOne(ctx, key, usageDelta(bytes, documents), options.Update().SetUpsert(true)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTenantUsage returns the caller's tenant-wide usage, zero if nothing was stored yet
+func (s *Store) GetTenantUsage(ctx context.Context) (models.Usage, error) {
+	usage := models.Usage{Scope: models.UsageScopeTenant}
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return usage, err
+	}
+	usage.TenantID = tenantID
+	err = s.getUsageCollection().FindOne(ctx, usageKey(tenantID, models.UsageScopeTenant, primitive.NilObjectID)).Decode(&usage)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// ListFolderUsage returns the usage of every folder in the caller's tenant, largest first
+func (s *Store) ListFolderUsage(ctx context.Context) ([]models.Usage, error) {
+	filter, err := scoped(ctx, bson.M{"scope": models.UsageScopeFolder})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getUsageCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "bytes", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	folders := []models.Usage{}
+	if err := cur.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// ComputeUsage recomputes the caller's per-folder usage from the documents and
+// document_versions collections
+func (s *Store) ComputeUsage(ctx context.Context) (map[primitive.ObjectID]models.Usage, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		FolderID  primitive.ObjectID `bson:"_id"`
+		Bytes     int64              `bson:"bytes"`
+		Documents int64              `bson:"documents"`
+	}
+
+	cur, err := s.getDocumentCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant_id": tenantID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$folder_id",
+			"bytes":     bson.M{"$sum": "$size"},
+			"documents": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$ifNull": bson.A{"$deleted_at", false}}, 0, 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	folders := map[primitive.ObjectID]models.Usage{}
+	for _, r := range rows {
+		folders[r.FolderID] = models.Usage{TenantID: tenantID, Scope: models.UsageScopeFolder, FolderID: r.FolderID, Bytes: r.Bytes, Documents: r.Documents}
+	}
+
+	// Superseded versions count towards the folder their document is in now
+	rows = nil
+	cur, err = s.getDocumentVersionCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant_id": tenantID}}},
+		{{Key: "$lookup", Value: bson.M{"from": "documents", "localField": "document_id", "foreignField": "_id", "as": "document"}}},
+		{{Key: "$unwind", Value: "$document"}},
+		{{Key: "$match", Value: bson.M{"document.tenant_id": tenantID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$document.folder_id", "bytes": bson.M{"$sum": "$size"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		u, ok := folders[r.FolderID]
+		if !ok {
+			u = models.Usage{TenantID: tenantID, Scope: models.UsageScopeFolder, FolderID: r.FolderID}
+		}
+		u.Bytes += r.Bytes
+		folders[r.FolderID] = u
+	}
+	return folders, nil
+}
+
+// CorrectUsage moves the caller's usage rows from snapshot and snapshotFolders, as read
+// before ComputeUsage, to folders, as computed by it, and returns the new tenant-wide
+// row. The differences are applied with $inc, so reservations and adjustments made
+// since the snapshot are kept rather than overwritten.
+func (s *Store) CorrectUsage(ctx context.Context, snapshot models.Usage, snapshotFolders []models.Usage, folders map[primitive.ObjectID]models.Usage) (models.Usage, error) {
+	usage := models.Usage{Scope: models.UsageScopeTenant}
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return usage, err
+	}
+	now := time.Now()
+	coll := s.getUsageCollection()
+
+	type delta struct{ bytes, documents int64 }
+	deltas := map[primitive.ObjectID]delta{}
+	var total delta
+	keep := make([]primitive.ObjectID, 0, len(folders))
+	for folderID, u := range folders {
+		deltas[folderID] = delta{u.Bytes, u.Documents}
+		total.bytes += u.Bytes
+		total.documents += u.Documents
+		keep = append(keep, folderID)
+	}
+	for _, u := range snapshotFolders {
+		d := deltas[u.FolderID]
+		deltas[u.FolderID] = delta{d.bytes - u.Bytes, d.documents - u.Documents}
+	}
+	for folderID, d := range deltas {
+		_, err := coll.UpdateOne(ctx, usageKey(tenantID, models.UsageScopeFolder, folderID),
+			bson.M{"$inc": bson.M{"bytes": d.bytes, "documents": d.documents}, "$set": bson.M{"reconciled_at": now}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return usage, err
+		}
+	}
+	// Rows of folders that no longer hold anything; a reservation since the snapshot
+	// leaves its row non-zero and so keeps it
+	_, err = coll.DeleteMany(ctx, bson.M{
+		"tenant_id": tenantID,
+		"scope":     models.UsageScopeFolder,
+		"folder_id": bson.M{"$nin": keep},
+		"bytes":     int64(0),
+		"documents": int64(0),
+	})
+	if err != nil {
+		return usage, err
+	}
+	err = coll.FindOneAndUpdate(ctx, usageKey(tenantID, models.UsageScopeTenant, primitive.NilObjectID),
+		bson.M{
+			"$inc": bson.M{"bytes": total.bytes - snapshot.Bytes, "documents": total.documents - snapshot.Documents},
+			"$set": bson.M{"reconciled_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&usage)
+	return usage, err
+}
+
+// UsageTenants lists every tenant that stores documents or has usage recorded. It is
+// unscoped because reconciliation is a platform-wide job.
+func (s *Store) UsageTenants(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	tenants := []string{}
+	for _, coll := range []*mongo.Collection{s.getDocumentCollection(), s.getUsageCollection()} {
+		values, err := coll.Distinct(ctx, "tenant_id", bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if id, ok := v.(string); ok && !seen[id] {
+				seen[id] = true
+				tenants = append(tenants, id)
+			}
+		}
+	}
+	return tenants, nil
+}
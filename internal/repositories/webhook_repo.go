@@ -0,0 +1,268 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"UploadDocument-Saas/internal/models"
+)
+
+func (s *Store) getWebhookCollection() *mongo.Collection {
+	return s.collection("webhooks")
+}
+
+func (s *Store) getWebhookDeliveryCollection() *mongo.Collection {
+	coll := s.collection("webhook_deliveries")
+	s.indexOnce("webhook_deliveries").Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+			{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		})
+		if err != nil {
+			slog.Error("Error creating webhook delivery indexes", "error", err)
+		}
+	})
+	return coll
+}
+
+// InsertWebhook stores a new webhook in the caller's tenant
+func (s *Store) InsertWebhook(ctx context.Context, hook *models.Webhook) error {
+	if err := stampTenant(ctx, &hook.TenantID); err != nil {
+		return err
+	}
+	if hook.ID.IsZero() {
+		hook.ID = primitive.NewObjectID()
+	}
+	_, err := s.getWebhookCollection().InsertOne(ctx, hook)
+	return err
+}
+
+// ListWebhooks returns the caller's tenant's webhooks
+func (s *Store) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	filter, err := scoped(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getWebhookCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	hooks := []models.Webhook{}
+	if err := cur.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// GetWebhook returns a single webhook within the caller's tenant
+func (s *Store) GetWebhook(ctx context.Context, id primitive.ObjectID) (models.Webhook, error) {
+	var hook models.Webhook
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return hook, err
+	}
+	err = s.getWebhookCollection().FindOne(ctx, filter).Decode(&hook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return hook, ErrNotFound
+	}
+	return hook, err
+}
+
+// UpdateWebhook applies update to a webhook and returns the result
+func (s *Store) UpdateWebhook(ctx context.Context, id primitive.ObjectID, update bson.M) (models.Webhook, error) {
+	var hook models.Webhook
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return hook, err
+	}
+	err = s.getWebhookCollection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&hook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return hook, ErrNotFound
+	}
+	return hook, err
+}
+
+// DeleteWebhook removes a webhook; its delivery log is kept
+func (s *Store) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	res, err := s.getWebhookCollection().DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindWebhooksForEvent returns the caller's tenant's active webhooks subscribed to eventType
+func (s *Store) FindWebhooksForEvent(ctx context.Context, eventType string) ([]models.Webhook, error) {
+	filter, err := scoped(ctx, bson.M{
+		"active": true,
+		"events": bson.M{"$in": bson.A{eventType, models.WebhookAllEvents}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.getWebhookCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	hooks := []models.Webhook{}
+	if err := cur.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// RecordWebhookSuccess clears a webhook's consecutive failure count
+func (s *Store) RecordWebhookSuccess(ctx context.Context, id primitive.ObjectID) error {
+	filter, err := scoped(ctx, bson.M{"_id": id, "consecutive_failures": bson.M{"$ne": 0}})
+	if err != nil {
+		return err
+	}
+	_, err = s.getWebhookCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"consecutive_failures": 0}})
+	return err
+}
+
+// RecordWebhookFailure counts a failed attempt against a webhook and disables it once
+// disableAfter consecutive attempts have failed. It reports whether this call disabled it.
+func (s *Store) RecordWebhookFailure(ctx context.Context, id primitive.ObjectID, disableAfter int, reason string) (bool, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.getWebhookCollection().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"consecutive_failures": 1}}); err != nil {
+		return false, err
+	}
+	filter["active"] = true
+	filter["consecutive_failures"] = bson.M{"$gte": disableAfter}
+	now := time.Now()
+	res, err := s.getWebhookCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"active":          false,
+		"disabled_at":     now,
+		"disabled_reason": reason,
+		"updated_at":      now,
+	}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// InsertWebhookDelivery queues a delivery in the caller's tenant
+func (s *Store) InsertWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	if err := stampTenant(ctx, &d.TenantID); err != nil {
+		return err
+	}
+	if d.ID.IsZero() {
+		d.ID = primitive.NewObjectID()
+	}
+	if d.Attempts == nil {
+		d.Attempts = []models.WebhookAttempt{}
+	}
+	_, err := s.getWebhookDeliveryCollection().InsertOne(ctx, d)
+	return err
+}
+
+// ListWebhookDeliveries returns a webhook's most recent deliveries, newest first
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID primitive.ObjectID, status string, limit int64) ([]models.WebhookDelivery, error) {
+	match := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		match["status"] = status
+	}
+	filter, err := scoped(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cur, err := s.getWebhookDeliveryCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery returns one delivery of a webhook within the caller's tenant
+func (s *Store) GetWebhookDelivery(ctx context.Context, webhookID, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	filter, err := scoped(ctx, bson.M{"_id": id, "webhook_id": webhookID})
+	if err != nil {
+		return d, err
+	}
+	err = s.getWebhookDeliveryCollection().FindOne(ctx, filter).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return d, ErrNotFound
+	}
+	return d, err
+}
+
+// ClaimDueWebhookDelivery leases the oldest pending delivery that is due, across all
+// tenants, so that only one dispatcher attempts it until lease has passed. It returns
+// ErrNotFound when nothing is due.
+func (s *Store) ClaimDueWebhookDelivery(ctx context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := s.getWebhookDeliveryCollection().FindOneAndUpdate(ctx,
+		bson.M{"status": models.WebhookDeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return d, ErrNotFound
+	}
+	return d, err
+}
+
+// RecordWebhookAttempt appends an attempt to a delivery and moves it to status.
+// A pending delivery is retried at next; finished deliveries are stamped complete.
+func (s *Store) RecordWebhookAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status string, next time.Time) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	set := bson.M{"status": status}
+	update := bson.M{"$push": bson.M{"attempts": attempt}, "$set": set}
+	if status == models.WebhookDeliveryPending {
+		set["next_attempt_at"] = next
+	} else {
+		set["completed_at"] = attempt.At
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	}
+	_, err = s.getWebhookDeliveryCollection().UpdateOne(ctx, filter, update)
+	return err
+}
+
+// FailPendingWebhookDeliveries gives up on every queued delivery of a webhook
+func (s *Store) FailPendingWebhookDeliveries(ctx context.Context, webhookID primitive.ObjectID, reason string) error {
+	filter, err := scoped(ctx, bson.M{"webhook_id": webhookID, "status": models.WebhookDeliveryPending})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = s.getWebhookDeliveryCollection().UpdateMany(ctx, filter, bson.M{
+		"$set":   bson.M{"status": models.WebhookDeliveryFailed, "completed_at": now},
+		"$push":  bson.M{"attempts": models.WebhookAttempt{At: now, Error: reason}},
+		"$unset": bson.M{"next_attempt_at": ""},
+	})
+	return err
+}
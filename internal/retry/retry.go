@@ -0,0 +1,42 @@
+// Package retry repeats an operation that fails while a dependency is still coming up.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+const (
+	// initialDelay doubles after every failed attempt, up to maxDelay
+	initialDelay = 500 * time.Millisecond
+	maxDelay     = 15 * time.Second
+)
+
+// Do calls fn until it succeeds or ctx is done, waiting an exponentially growing,
+// jittered delay between attempts. what names the operation in logs and errors.
+func Do[T any](ctx context.Context, what string, fn func(context.Context) (T, error)) (T, error) {
+	delay := initialDelay
+	for attempt := 1; ; attempt++ {
+		v, err := fn(ctx)
+		if err == nil {
+			return v, nil
+		}
+		if ctx.Err() != nil {
+			return v, fmt.Errorf("%s: giving up after %d attempts: %w", what, attempt, err)
+		}
+		// Wait between half and all of delay so replicas do not retry in lockstep
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		slog.Warn("Retrying "+what, "attempt", attempt, "retry_in", wait.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return v, fmt.Errorf("%s: giving up after %d attempts: %w", what, attempt, err)
+		case <-time.After(wait):
+		}
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package signedurl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func testSigner(t *testing.T) *Signer {
+	t.Helper()
+	s, err := New(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func claims(now time.Time) Claims {
+	return Claims{
+		TenantID:   "acme",
+		UserID:     "user-1",
+		DocumentID: "doc-1",
+		Version:    2,
+		ExpiresAt:  now.Add(time.Minute),
+	}
+}
+
+func TestVerifyAcceptsWhatSignMinted(t *testing.T) {
+	now := time.Now()
+	s := testSigner(t)
+	c := claims(now)
+	c.IP = "203.0.113.7"
+	got, err := s.Verify("doc-1", s.Sign(c), "203.0.113.7", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TenantID != c.TenantID || got.UserID != c.UserID || got.Version != c.Version || got.IP != c.IP {
+		t.Errorf("Verify = %+v, want %+v", got, c)
+	}
+
+	// Another replica with the same secret accepts the URL
+	if _, err := testSigner(t).Verify("doc-1", s.Sign(c), "203.0.113.7", now); err != nil {
+		t.Errorf("a signer with the same secret rejected the URL: %v", err)
+	}
+}
+
+func TestVerifyRejectsExpiredURLs(t *testing.T) {
+	now := time.Now()
+	s := testSigner(t)
+	q := s.Sign(claims(now))
+	if _, err := s.Verify("doc-1", q, "", now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify after expiry = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyRejectsTamperedClaims(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name, param, value string
+	}{
+		{"tenant", "t", "globex"},
+		{"user", "u", "user-2"},
+		{"expiry extended", "exp", "9999999999"},
+		{"version", "v", "1"},
+		{"version not a number", "v", "two"},
+		{"signature", "sig", strings.Repeat("A", 43)},
+		{"ip binding added", "ip", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testSigner(t)
+			q := s.Sign(claims(now))
+			q.Set(tt.param, tt.value)
+			if _, err := s.Verify("doc-1", q, "203.0.113.7", now); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	s := testSigner(t)
+	if _, err := s.Verify("doc-2", s.Sign(claims(now)), "", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify for another document = %v, want %v", err, ErrInvalidSignature)
+	}
+	other, err := New(strings.Repeat("x", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Verify("doc-1", s.Sign(claims(now)), "", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another secret = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyEnforcesIPBinding(t *testing.T) {
+	now := time.Now()
+	s := testSigner(t)
+	c := claims(now)
+	c.IP = "203.0.113.7"
+	if _, err := s.Verify("doc-1", s.Sign(c), "198.51.100.1", now); !errors.Is(err, ErrIPMismatch) {
+		t.Errorf("Verify from another address = %v, want %v", err, ErrIPMismatch)
+	}
+
+	// Dropping the binding from the URL breaks the signature
+	q := s.Sign(c)
+	q.Del("ip")
+	if _, err := s.Verify("doc-1", q, "198.51.100.1", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify without the ip parameter = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	// An unbound URL works from any address
+	if _, err := s.Verify("doc-1", s.Sign(claims(now)), "198.51.100.1", now); err != nil {
+		t.Errorf("unbound URL rejected: %v", err)
+	}
+}
+
+func TestEphemeralKeysDoNotCrossSigners(t *testing.T) {
+	now := time.Now()
+	a, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Verify("doc-1", a.Sign(claims(now)), "", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another ephemeral key = %v, want %v", err, ErrInvalidSignature)
+	}
+}
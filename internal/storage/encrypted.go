@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Encrypted blob layout:
+//
+//	magic "VEK1" | data key version (uint32) | nonce prefix (8 bytes) | chunk...
+//
+// Each chunk seals up to chunkSize plaintext bytes with AES-GCM under the nonce
+// prefix plus a big-endian chunk counter. The final chunk is always shorter than
+// chunkSize (possibly empty) and is sealed with a "final" flag as associated data,
+// so truncation and reordering are detected.
+const (
+	chunkSize    = 64 * 1024
+	tagSize      = 16
+	headerSize   = 4 + 4 + 8
+	sealedChunk  = chunkSize + tagSize
+	noncePrefix  = 8
+	encryptMagic = "VEK1"
+)
+
+// ErrCorrupt is returned when an encrypted blob fails authentication
+var ErrCorrupt = errors.New("storage: encrypted object is corrupt or tampered")
+
+// KeySource supplies per-tenant data keys for encryption at rest
+type KeySource interface {
+	ActiveDataKey(ctx context.Context) (int, []byte, error)
+	DataKey(ctx context.Context, version int) ([]byte, error)
+}
+
+// Encrypted is a Storage that envelope-encrypts blobs with the tenant's data key
+// before handing them to an inner Storage
+type Encrypted struct {
+	inner Storage
+	keys  KeySource
+}
+
+// NewEncrypted wraps inner with streaming AES-GCM encryption
+func NewEncrypted(inner Storage, keys KeySource) *Encrypted {
+	return &Encrypted{inner: inner, keys: keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, noncePrefix+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefix:], index)
+	return nonce
+}
+
+func finalFlag(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Put encrypts r with the tenant's active data key and stores the ciphertext at key
+func (e *Encrypted) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	if err := checkKey(ctx, key); err != nil {
+		return ObjectInfo{}, err
+	}
+	version, dek, err := e.keys.ActiveDataKey(ctx)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	header := make([]byte, headerSize)
+	copy(header, encryptMagic)
+	binary.BigEndian.PutUint32(header[4:], uint32(version))
+	if _, err := rand.Read(header[8:]); err != nil {
+		return ObjectInfo{}, err
+	}
+	prefix := header[8:]
+
+	pr, pw := io.Pipe()
+	var plainSize int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := pw.Write(header); err != nil {
+			return
+		}
+		buf := make([]byte, chunkSize)
+		sealed := make([]byte, 0, sealedChunk)
+		for index := uint32(0); ; index++ {
+			n, err := io.ReadFull(r, buf)
+			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				pw.CloseWithError(err)
+				return
+			}
+			plainSize += int64(n)
+			final := n < chunkSize
+			sealed = gcm.Seal(sealed[:0], chunkNonce(prefix, index), buf[:n], finalFlag(final))
+			if _, err := pw.Write(sealed); err != nil {
+				return
+			}
+			if final {
+				pw.Close()
+				return
+			}
+		}
+	}()
+
+	_, err = e.inner.Put(ctx, key, pr)
+	pr.CloseWithError(err)
+	<-done
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: plainSize, KeyVersion: version}, nil
+}
+
+// Open returns a decrypting, seekable view of the object at key. A blob without the
+// encryption header is returned as-is only when keyVersion is 0, i.e. it was recorded
+// as written before encryption was enabled; otherwise it is treated as corrupt.
+func (e *Encrypted) Open(ctx context.Context, key string, keyVersion int) (Object, error) {
+	obj, err := e.inner.Open(ctx, key, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(obj, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		obj.Close()
+		return nil, err
+	}
+	if n < headerSize || !bytes.Equal(header[:4], []byte(encryptMagic)) {
+		if keyVersion != 0 {
+			obj.Close()
+			return nil, ErrCorrupt
+		}
+		// Legacy plaintext blob
+		if _, err := obj.Seek(0, io.SeekStart); err != nil {
+			obj.Close()
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	dek, err := e.keys.DataKey(ctx, int(binary.BigEndian.Uint32(header[4:])))
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	body := obj.Size() - headerSize
+	chunks := (body + sealedChunk - 1) / sealedChunk
+	if chunks < 1 {
+		obj.Close()
+		return nil, ErrCorrupt
+	}
+	return &decryptingObject{
+		inner:  obj,
+		gcm:    gcm,
+		prefix: append([]byte(nil), header[8:]...),
+		chunks: chunks,
+		size:   body - chunks*tagSize,
+		cached: -1,
+	}, nil
+}
+
+// Delete removes the object stored at key
+func (e *Encrypted) Delete(ctx context.Context, key string) error {
+	return e.inner.Delete(ctx, key)
+}
+
+// decryptingObject decrypts one chunk at a time, so Seek is cheap and Range
+// requests only decrypt the chunks they touch
+type decryptingObject struct {
+	inner  Object
+	gcm    cipher.AEAD
+	prefix []byte
+	chunks int64
+	size   int64
+	pos    int64
+	cached int64
+	plain  []byte
+	sealed []byte
+}
+
+func (d *decryptingObject) Size() int64 {
+	return d.size
+}
+
+func (d *decryptingObject) load(index int64) error {
+	if index == d.cached {
+		return nil
+	}
+	if _, err := d.inner.Seek(headerSize+index*sealedChunk, io.SeekStart); err != nil {
+		return err
+	}
+	if d.sealed == nil {
+		d.sealed = make([]byte, sealedChunk)
+	}
+	n, err := io.ReadFull(d.inner, d.sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	final := index == d.chunks-1
+	plain, err := d.gcm.Open(d.plain[:0], chunkNonce(d.prefix, uint32(index)), d.sealed[:n], finalFlag(final))
+	if err != nil {
+		d.cached = -1
+		return ErrCorrupt
+	}
+	d.plain, d.cached = plain, index
+	return nil
+}
+
+func (d *decryptingObject) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	index := d.pos / chunkSize
+	if err := d.load(index); err != nil {
+		return 0, err
+	}
+	n := copy(p, d.plain[d.pos-index*chunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *decryptingObject) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = d.pos + offset
+	case io.SeekEnd:
+		pos = d.size + offset
+	default:
+		return 0, errors.New("storage: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+	d.pos = pos
+	return pos, nil
+}
+
+func (d *decryptingObject) Close() error {
+	return d.inner.Close()
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"UploadDocument-Saas/internal/tenant"
+)
+
+// staticKeys is a KeySource with one fixed data key per version; the highest is active
+type staticKeys map[int][]byte
+
+func (k staticKeys) ActiveDataKey(context.Context) (int, []byte, error) {
+	active := 0
+	for version := range k {
+		if version > active {
+			active = version
+		}
+	}
+	return active, k[active], nil
+}
+
+func (k staticKeys) DataKey(_ context.Context, version int) ([]byte, error) {
+	key, ok := k[version]
+	if !ok {
+		return nil, errors.New("unknown data key version")
+	}
+	return key, nil
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encryptedStore returns an Encrypted over a Local rooted at the returned directory
+func encryptedStore(t *testing.T) (*Encrypted, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return NewEncrypted(NewLocal(dir), staticKeys{1: randomBytes(t, 32)}), dir
+}
+
+func putBlob(t *testing.T, e *Encrypted, ctx context.Context, plain []byte) string {
+	t.Helper()
+	key, err := NewKey(ctx, "blob.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := e.Put(ctx, key, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(plain)) || info.KeyVersion != 1 {
+		t.Fatalf("Put = %+v, want size %d and key version 1", info, len(plain))
+	}
+	return key
+}
+
+func readBlob(e *Encrypted, ctx context.Context, key string) ([]byte, error) {
+	obj, err := e.Open(ctx, key, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+func TestEncryptedRoundTrips(t *testing.T) {
+	e, _ := encryptedStore(t)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		plain := randomBytes(t, size)
+		key := putBlob(t, e, ctx, plain)
+
+		obj, err := e.Open(ctx, key, 1)
+		if err != nil {
+			t.Fatalf("%d bytes: Open: %v", size, err)
+		}
+		if obj.Size() != int64(size) {
+			t.Errorf("%d bytes: Size = %d", size, obj.Size())
+		}
+		got, err := io.ReadAll(obj)
+		obj.Close()
+		if err != nil {
+			t.Fatalf("%d bytes: read: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("%d bytes: read back %d different bytes", size, len(got))
+		}
+	}
+}
+
+func TestEncryptedRejectsTamperedBlobs(t *testing.T) {
+	plain := make([]byte, 2*chunkSize+100) // two full chunks and a final one
+	tests := []struct {
+		name   string
+		tamper func(blob []byte) []byte
+	}{
+		{"truncated", func(blob []byte) []byte {
+			return blob[:len(blob)-10]
+		}},
+		{"final chunk dropped", func(blob []byte) []byte {
+			return blob[:headerSize+2*sealedChunk]
+		}},
+		{"chunks reordered", func(blob []byte) []byte {
+			out := append([]byte(nil), blob[:headerSize]...)
+			out = append(out, blob[headerSize+sealedChunk:headerSize+2*sealedChunk]...)
+			out = append(out, blob[headerSize:headerSize+sealedChunk]...)
+			return append(out, blob[headerSize+2*sealedChunk:]...)
+		}},
+		{"ciphertext flipped", func(blob []byte) []byte {
+			blob[headerSize+chunkSize/2] ^= 1
+			return blob
+		}},
+		{"header only", func(blob []byte) []byte {
+			return blob[:headerSize]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, dir := encryptedStore(t)
+			ctx := tenant.WithTenant(context.Background(), "acme")
+			key := putBlob(t, e, ctx, plain)
+			path := filepath.Join(dir, filepath.FromSlash(key))
+			blob, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.tamper(blob), 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := readBlob(e, ctx, key); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("read %d bytes with error %v, want %v", len(got), err, ErrCorrupt)
+			}
+		})
+	}
+}
+
+func TestEncryptedReadsFromMidChunkOffsets(t *testing.T) {
+	e, _ := encryptedStore(t)
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	plain := randomBytes(t, 3*chunkSize+123)
+	key := putBlob(t, e, ctx, plain)
+
+	obj, err := e.Open(ctx, key, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	// Out of order, so that chunks are reloaded as well as reused
+	for _, offset := range []int{chunkSize + 7, 0, 2*chunkSize - 50, chunkSize - 1, len(plain) - 1, 3 * chunkSize, 1} {
+		if _, err := obj.Seek(int64(offset), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		want := plain[offset:min(offset+300, len(plain))]
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(obj, got); err != nil {
+			t.Fatalf("read at %d: %v", offset, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("read at %d returned different bytes", offset)
+		}
+	}
+	if _, err := obj.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := obj.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("read past the end = %d, %v; want 0, EOF", n, err)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"UploadDocument-Saas/internal/metrics"
+	"UploadDocument-Saas/internal/tracing"
+)
+
+// instrumented traces and records metrics for every call to the wrapped Storage
+type instrumented struct {
+	inner Storage
+}
+
+func (s instrumented) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage.put")
+	info, err := s.inner.Put(ctx, key, r)
+	span.SetAttributes(attribute.Int64("storage.size", info.Size))
+	tracing.End(span, err)
+	metrics.ObserveStorage("put", start, err)
+	return info, err
+}
+
+func (s instrumented) Open(ctx context.Context, key string, keyVersion int) (Object, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage.open")
+	obj, err := s.inner.Open(ctx, key, keyVersion)
+	tracing.End(span, err)
+	metrics.ObserveStorage("open", start, err)
+	return obj, err
+}
+
+func (s instrumented) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage.delete")
+	err := s.inner.Delete(ctx, key)
+	tracing.End(span, err)
+	metrics.ObserveStorage("delete", start, err)
+	return err
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+var (
+	// ErrNotFound is returned when no object exists under a key
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrForbidden is returned when a key does not belong to the caller's tenant
+	ErrForbidden = errors.New("storage: key outside tenant scope")
+)
+
+// Object is a readable, seekable stored blob
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+// ObjectInfo describes a stored blob
+type ObjectInfo struct {
+	Size       int64 // plaintext size
+	KeyVersion int   // tenant data key version, 0 when stored unencrypted
+}
+
+// Storage persists document blobs under tenant-prefixed keys. Open takes the KeyVersion
+// Put reported for the blob, so that only blobs recorded as unencrypted are ever
+// served without authentication.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error)
+	Open(ctx context.Context, key string, keyVersion int) (Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the storage backend rooted at the configured upload directory, encrypting
+// blobs at rest with the per-tenant data keys and recording metrics for every call
+func New(cfg config.StorageConfig, keys KeySource) Storage {
+	return instrumented{NewEncrypted(NewLocal(cfg.UploadDir), keys)}
+}
+
+// NewKey builds a unique storage key for filename under the tenant in ctx
+func NewKey(ctx context.Context, filename string) (string, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(filepath.Clean("/" + filename))
+	return fmt.Sprintf("%s/%d_%s", tenantID, time.Now().UnixNano(), name), nil
+}
+
+// checkKey ensures key lives under the tenant prefix carried by ctx
+func checkKey(ctx context.Context, key string) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(key, tenantID+"/") {
+		return ErrForbidden
+	}
+	// Dots inside a name are fine; only a ".." segment could climb out of the prefix
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// Local stores blobs on the local filesystem
+type Local struct {
+	root string
+}
+
+// NewLocal returns a filesystem storage rooted at dir
+func NewLocal(dir string) *Local {
+	return &Local{root: dir}
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+// Put writes r to key, replacing any existing object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (ObjectInfo, error) {
+	if err := checkKey(ctx, key); err != nil {
+		return ObjectInfo{}, err
+	}
+	p := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return ObjectInfo{}, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(p)
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: n}, nil
+}
+
+// Open returns the object stored at key as it is; Local does not encrypt, so
+// keyVersion is unused
+func (l *Local) Open(ctx context.Context, key string, keyVersion int) (Object, error) {
+	if err := checkKey(ctx, key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &localObject{File: f, size: info.Size()}, nil
+}
+
+// Delete removes the object stored at key
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := checkKey(ctx, key); err != nil {
+		return err
+	}
+	err := os.Remove(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Probe writes, reads back and removes a file under a reserved directory of the root
+func (l *Local) Probe(ctx context.Context) error {
+	dir := filepath.Join(l.root, ".health")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	p := filepath.Join(dir, fmt.Sprintf("probe-%d", time.Now().UnixNano()))
+	want := []byte(p)
+	if err := os.WriteFile(p, want, 0640); err != nil {
+		return err
+	}
+	defer os.Remove(p)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	got, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("storage: probe read back different content")
+	}
+	return nil
+}
+
+type localObject struct {
+	*os.File
+	size int64
+}
+
+func (o *localObject) Size() int64 {
+	return o.size
+}
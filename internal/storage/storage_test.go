@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"UploadDocument-Saas/internal/tenant"
+)
+
+func TestCheckKeyConfinesKeysToTheTenantPrefix(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"acme/1700000000_report.pdf", nil},
+		{"acme/1700000000_archive.tar.gz", nil},
+		{"acme/1700000000_..hidden", nil},
+		{"globex/1700000000_report.pdf", ErrForbidden},
+		{"acme-corp/1700000000_report.pdf", ErrForbidden},
+		{"acme", ErrForbidden},
+		{"/acme/1700000000_report.pdf", ErrForbidden},
+		{"acme/../globex/1700000000_report.pdf", ErrForbidden},
+		{"acme/..", ErrForbidden},
+	}
+	for _, tt := range tests {
+		if err := checkKey(ctx, tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("checkKey(%q) = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+
+	if err := checkKey(context.Background(), "acme/1700000000_report.pdf"); !errors.Is(err, tenant.ErrMissing) {
+		t.Errorf("checkKey without a tenant = %v, want %v", err, tenant.ErrMissing)
+	}
+}
+
+func TestLocalRefusesAnotherTenantsKeys(t *testing.T) {
+	local := NewLocal(t.TempDir())
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	key, err := NewKey(acme, "../../report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Put(acme, key, bytes.NewReader([]byte("quarterly numbers"))); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := local.Open(acme, key, 0)
+	if err != nil {
+		t.Fatalf("owner cannot open its blob: %v", err)
+	}
+	got, _ := io.ReadAll(obj)
+	obj.Close()
+	if string(got) != "quarterly numbers" {
+		t.Errorf("read %q back", got)
+	}
+
+	if _, err := local.Open(globex, key, 0); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Open from another tenant = %v, want ErrForbidden", err)
+	}
+	if err := local.Delete(globex, key); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Delete from another tenant = %v, want ErrForbidden", err)
+	}
+}
@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+type ctxKey struct{}
+
+// ErrMissing is returned when a tenant-scoped operation runs without a tenant in its context.
+var ErrMissing = errors.New("tenant: no tenant in context")
+
+// ErrInvalid is returned for tenant IDs that are not safe to use as keys or path segments.
+var ErrInvalid = errors.New("tenant: invalid tenant id")
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Valid reports whether id is a well-formed tenant ID
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// WithTenant returns a copy of ctx carrying the given tenant ID
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ID stored in ctx, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Require returns the tenant ID stored in ctx or an error if it is missing or malformed
+func Require(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissing
+	}
+	if !Valid(id) {
+		return "", ErrInvalid
+	}
+	return id, nil
+}
@@ -0,0 +1,248 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the backends the API
+// calls: Mongo commands, Elasticsearch requests and Kafka messages.
+//
+// Options.Exporter picks the exporter: "otlp" (configured by the standard
+// OTEL_EXPORTER_OTLP_* variables), "stdout", "file" or "none". Sampling follows
+// OTEL_TRACES_SAMPLER.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "UploadDocument-Saas"
+
+// Options configures Init
+type Options struct {
+	Exporter    string // otlp, stdout, file or none
+	File        string // where the file exporter appends spans
+	ServiceName string
+}
+
+// Init installs the global tracer provider and W3C trace context propagation. The
+// returned func flushes and stops the exporter; call it on shutdown.
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch kind := strings.ToLower(opts.Exporter); kind {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		var f *os.File
+		if f, err = os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err == nil {
+			closer = f
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns the application's tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span named name as a child of any span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach returns a background context that continues the trace in ctx, for work
+// that outlives the request that started it
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+}
+
+// MongoMonitor wraps next, which may be nil, so that every MongoDB command also gets a
+// client span under the span of the calling context
+func MongoMonitor(next *event.CommandMonitor) *event.CommandMonitor {
+	if next == nil {
+		next = &event.CommandMonitor{}
+	}
+	var spans sync.Map // request ID -> trace.Span
+	finish := func(requestID int64, failure string) {
+		v, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		if failure != "" {
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+			_, span := Start(ctx, "mongo."+e.CommandName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+				semconv.DBCollectionName(collection),
+			))
+			spans.Store(e.RequestID, span)
+			if next.Started != nil {
+				next.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, "")
+			if next.Succeeded != nil {
+				next.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.Failure)
+			if next.Failed != nil {
+				next.Failed(ctx, e)
+			}
+		},
+	}
+}
+
+// ElasticTransport wraps next, or http.DefaultTransport when nil, with a client span
+// per Elasticsearch request
+func ElasticTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx, span := Start(req.Context(), "elasticsearch "+req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemElasticsearch,
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.URL.Path),
+		))
+		req = req.WithContext(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		res, err := next.RoundTrip(req)
+		if err == nil {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+			if res.StatusCode >= 500 {
+				span.SetStatus(codes.Error, res.Status)
+			}
+		}
+		End(span, err)
+		return res, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// StartProducer starts a producer span for writing msg to topic and injects its trace
+// context into msg's headers so the consumer can continue the trace
+func StartProducer(ctx context.Context, topic string, msg *kafka.Message) (context.Context, trace.Span) {
+	ctx, span := Start(ctx, "kafka.produce "+topic, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		semconv.MessagingSystemKafka,
+		semconv.MessagingDestinationName(topic),
+		semconv.MessagingOperationTypePublish,
+	))
+	otel.GetTextMapPropagator().Inject(ctx, kafkaCarrier{msg})
+	return ctx, span
+}
+
+// StartConsumer starts a consumer span for msg, read by group, continuing the trace
+// its producer injected into the headers
+func StartConsumer(ctx context.Context, group string, msg kafka.Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, kafkaCarrier{&msg})
+	return Start(ctx, "kafka.consume "+msg.Topic, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		semconv.MessagingSystemKafka,
+		semconv.MessagingDestinationName(msg.Topic),
+		semconv.MessagingKafkaConsumerGroup(group),
+		semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+	))
+}
+
+// kafkaCarrier adapts Kafka message headers to a propagation.TextMapCarrier
+type kafkaCarrier struct {
+	msg *kafka.Message
+}
+
+func (c kafkaCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if h.Key == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
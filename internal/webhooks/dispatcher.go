@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/models"
+	"UploadDocument-Saas/internal/repositories"
+	"UploadDocument-Saas/internal/tenant"
+)
+
+const (
+	// pollInterval is how often the dispatcher looks for due deliveries when idle
+	pollInterval = 2 * time.Second
+	// claimLease is how long a claimed delivery is hidden from other dispatchers
+	claimLease = time.Minute
+	// requestTimeout bounds one HTTP attempt
+	requestTimeout = 10 * time.Second
+	// baseBackoff doubles after every failed attempt, up to maxBackoff
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// maxResponseBody is how much of a response is kept in the delivery log
+	maxResponseBody = 1024
+)
+
+// Dispatcher queues deliveries of the events on its bus and sends them within the limits
+// of the webhooks config section
+type Dispatcher struct {
+	cfg    config.WebhooksConfig
+	store  *repositories.Store
+	events *events.Bus
+	client *http.Client
+}
+
+// NewDispatcher returns a dispatcher for cfg keeping webhooks and deliveries in store
+// and queueing a delivery for every event emitted on bus from now on. Unless
+// cfg.AllowInternal is set, its client refuses to connect to internal addresses.
+func NewDispatcher(cfg config.WebhooksConfig, store *repositories.Store, bus *events.Bus) *Dispatcher {
+	w := &Dispatcher{
+		cfg:    cfg,
+		store:  store,
+		events: bus,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: newTransport(cfg.AllowInternal),
+			// Never follow redirects; a 3xx is reported as a failed attempt
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	if bus != nil {
+		bus.Subscribe(w.enqueue)
+	}
+	return w
+}
+
+// Run sends due deliveries with cfg.Workers workers until ctx is done, then waits for
+// the attempts in flight. Every replica may run it; deliveries are leased so each
+// attempt is made once, and a slow endpoint holds up one worker rather than every
+// other tenant's deliveries.
+func (w *Dispatcher) Run(ctx context.Context) {
+	var workers sync.WaitGroup
+	for i := 0; i < max(w.cfg.Workers, 1); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			w.work(ctx)
+		}()
+	}
+	workers.Wait()
+}
+
+// work claims and attempts due deliveries one at a time until ctx is done
+func (w *Dispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain everything that is due before sleeping again
+		for ctx.Err() == nil {
+			d, err := w.store.ClaimDueWebhookDelivery(ctx, time.Now(), claimLease)
+			if errors.Is(err, repositories.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Error claiming webhook delivery", "error", err)
+				break
+			}
+			w.attempt(tenant.WithTenant(ctx, d.TenantID), d)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry with
+// exponential backoff or giving up after MaxAttempts
+func (w *Dispatcher) attempt(ctx context.Context, d models.WebhookDelivery) {
+	hook, err := w.store.GetWebhook(ctx, d.WebhookID)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && !hook.Active) {
+		reason := "webhook deleted"
+		if err == nil {
+			reason = "webhook disabled"
+		}
+		w.finish(ctx, d, models.WebhookAttempt{At: time.Now(), Error: reason}, models.WebhookDeliveryFailed, time.Time{})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading webhook", "webhook_id", d.WebhookID.Hex(), "error", err)
+		return
+	}
+
+	result := w.send(ctx, hook, d)
+	if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+		w.finish(ctx, d, result, models.WebhookDeliverySucceeded, time.Time{})
+		if err := w.store.RecordWebhookSuccess(ctx, hook.ID); err != nil {
+			slog.ErrorContext(ctx, "Error resetting webhook failures", "webhook_id", hook.ID.Hex(), "error", err)
+		}
+		return
+	}
+
+	tries := len(d.Attempts) + 1
+	if tries >= w.cfg.MaxAttempts {
+		w.finish(ctx, d, result, models.WebhookDeliveryFailed, time.Time{})
+	} else {
+		w.finish(ctx, d, result, models.WebhookDeliveryPending, time.Now().Add(backoff(tries)))
+	}
+
+	disabled, err := w.store.RecordWebhookFailure(ctx, hook.ID, w.cfg.DisableAfter, "too many consecutive failed deliveries")
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording webhook failure", "webhook_id", hook.ID.Hex(), "error", err)
+		return
+	}
+	if disabled {
+		slog.WarnContext(ctx, "Disabled webhook after consecutive failures", "webhook_id", hook.ID.Hex(), "tenant_id", hook.TenantID, "failures", w.cfg.DisableAfter)
+		if err := w.store.FailPendingWebhookDeliveries(ctx, hook.ID, "webhook disabled"); err != nil {
+			slog.ErrorContext(ctx, "Error failing pending webhook deliveries", "webhook_id", hook.ID.Hex(), "error", err)
+		}
+	}
+}
+
+// send makes one signed POST of the delivery's payload
+func (w *Dispatcher) send(ctx context.Context, hook models.Webhook, d models.WebhookDelivery) models.WebhookAttempt {
+	started := time.Now()
+	result := models.WebhookAttempt{At: started}
+	body := []byte(d.Payload)
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "UploadDocument-Saas-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, d.ID.Hex())
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, started, body))
+
+	res, err := w.client.Do(req)
+	result.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer res.Body.Close()
+	result.StatusCode = res.StatusCode
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	result.ResponseBody = string(bytes.ToValidUTF8(snippet, nil))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("unexpected status %d", res.StatusCode)
+	}
+	return result
+}
+
+func (w *Dispatcher) finish(ctx context.Context, d models.WebhookDelivery, result models.WebhookAttempt, status string, next time.Time) {
+	if err := w.store.RecordWebhookAttempt(ctx, d.ID, result, status, next); err != nil {
+		slog.ErrorContext(ctx, "Error recording webhook delivery", "delivery_id", d.ID.Hex(), "error", err)
+	}
+}
+
+// backoff is the delay before the attempt following the tries-th failure
+func backoff(tries int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < tries && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
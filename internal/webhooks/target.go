@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget is returned for webhook hosts that resolve to loopback, private,
+// link-local or unspecified addresses while internal targets are not allowed
+var ErrForbiddenTarget = errors.New("webhook URL must not point at an internal address")
+
+// internalAddr reports whether ip must not be reached by tenant-supplied URLs
+func internalAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// dialControl runs after DNS resolution for every connection the dispatcher opens, so a
+// host that resolves differently at delivery time than at registration is still caught
+func dialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook dial %s: %w", address, err)
+	}
+	if internalAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, addrPort.Addr())
+	}
+	return nil
+}
+
+// newTransport returns the dispatcher transport. Proxies from the environment are not
+// used because the guard would then only see the proxy's address.
+func newTransport(allow bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !allow {
+		dialer.Control = dialControl
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// checkHost resolves host and rejects it when any of its addresses is internal
+func (w *Dispatcher) checkHost(ctx context.Context, host string) error {
+	if w.cfg.AllowInternal {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if internalAddr(ip) {
+			return ErrForbiddenTarget
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: host %s does not resolve", ErrInvalidURL, host)
+	}
+	for _, ip := range addrs {
+		if internalAddr(ip) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"UploadDocument-Saas/config"
+)
+
+func TestValidateURLRejectsInternalTargets(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://93.184.215.14/hook", nil},
+		{"http://127.0.0.1:8080/hook", ErrForbiddenTarget},
+		{"http://[::1]/hook", ErrForbiddenTarget},
+		{"http://10.1.2.3/hook", ErrForbiddenTarget},
+		{"http://192.168.0.10/hook", ErrForbiddenTarget},
+		{"http://169.254.169.254/latest/meta-data", ErrForbiddenTarget},
+		{"http://0.0.0.0/hook", ErrForbiddenTarget},
+		{"http://[::ffff:127.0.0.1]/hook", ErrForbiddenTarget},
+		{"http://localhost/hook", ErrForbiddenTarget},
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"/relative", ErrInvalidURL},
+	}
+	w := NewDispatcher(config.Defaults().Webhooks, nil, nil)
+	for _, tt := range tests {
+		err := w.ValidateURL(context.Background(), tt.url)
+		if !errors.Is(err, tt.want) && !(tt.want == nil && err == nil) {
+			t.Errorf("ValidateURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesInternalAddressesAtDialTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	guarded := &http.Client{Transport: newTransport(false)}
+	if _, err := guarded.Get(server.URL); !errors.Is(err, ErrForbiddenTarget) {
+		t.Fatalf("guarded client reached %s: err = %v", server.URL, err)
+	}
+
+	open := &http.Client{Transport: newTransport(true)}
+	res, err := open.Get(server.URL)
+	if err != nil {
+		t.Fatalf("client allowing internal targets failed: %v", err)
+	}
+	res.Body.Close()
+}
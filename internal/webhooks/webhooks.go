@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"UploadDocument-Saas/internal/events"
+	"UploadDocument-Saas/internal/models"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// ErrInvalidURL is returned for webhook URLs that are not absolute http(s) URLs
+var ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+
+// ErrInvalidEvent is returned for event filters naming an unknown event type
+var ErrInvalidEvent = errors.New("unknown event type")
+
+// NewSecret returns a random signing secret for a new webhook
+func NewSecret() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return "whsec_" + hex.EncodeToString(buf)
+}
+
+// ValidateURL checks that raw is an absolute http or https URL whose host does not
+// resolve to an internal address. Deliveries check the address again when they dial.
+func (w *Dispatcher) ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	return w.checkHost(ctx, u.Hostname())
+}
+
+// ValidateEvents checks an event filter; "*" subscribes to every event type
+func ValidateEvents(types []string) error {
+	if len(types) == 0 {
+		return fmt.Errorf("%w: at least one event type is required", ErrInvalidEvent)
+	}
+	for _, t := range types {
+		if t != models.WebhookAllEvents && !events.Known(t) {
+			return fmt.Errorf("%w: %s", ErrInvalidEvent, t)
+		}
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers recompute the
+// HMAC with their secret and should reject stale timestamps to prevent replays.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueue queues a delivery of e for every webhook of its tenant subscribed to its type
+func (w *Dispatcher) enqueue(ctx context.Context, e events.Event) {
+	hooks, err := w.store.FindWebhooksForEvent(ctx, e.Type)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding webhooks", "event", e.Type, "error", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error encoding webhook event", "event", e.Type, "event_id", e.ID, "error", err)
+		return
+	}
+	now := time.Now()
+	for _, hook := range hooks {
+		d := models.WebhookDelivery{
+			TenantID:      e.TenantID,
+			WebhookID:     hook.ID,
+			EventID:       e.ID,
+			EventType:     e.Type,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+		if err := w.store.InsertWebhookDelivery(ctx, &d); err != nil {
+			slog.ErrorContext(ctx, "Error queueing webhook delivery", "event", e.Type, "webhook_id", hook.ID.Hex(), "error", err)
+		}
+	}
+}
+
+// Redeliver queues a fresh copy of an earlier delivery for immediate sending
+func (w *Dispatcher) Redeliver(ctx context.Context, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	now := time.Now()
+	d := models.WebhookDelivery{
+		TenantID:      original.TenantID,
+		WebhookID:     original.WebhookID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &original.ID,
+		CreatedAt:     now,
+	}
+	err := w.store.InsertWebhookDelivery(ctx, &d)
+	return d, err
+}
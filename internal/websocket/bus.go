@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Envelope is a hub message as carried between replicas
+type Envelope struct {
+	ID       string          `json:"id"`
+	Origin   string          `json:"origin"`
+	TenantID string          `json:"tenant_id"`
+	Topic    string          `json:"topic"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Bus carries hub messages between server replicas. Every replica's hub publishes
+// what it produces and delivers what it receives to its local subscribers.
+type Bus interface {
+	Publish(ctx context.Context, env Envelope) error
+	// Subscribe calls handler for every envelope until ctx is done
+	Subscribe(ctx context.Context, handler func(Envelope)) error
+	Close() error
+}
+
+// ReplicaID returns an identifier for this process on the bus: the hostname plus a random suffix
+func ReplicaID() string {
+	host, _ := os.Hostname()
+	return host + "-" + newMessageID()[:8]
+}
+
+func newMessageID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// MemoryBus is an in-process Bus. Hubs sharing one MemoryBus behave like replicas
+// sharing a broker, which is how the tests exercise relay and de-duplication. A
+// single replica needs no bus at all (hub.bus=memory).
+type MemoryBus struct {
+	mu       sync.RWMutex
+	handlers map[int]func(Envelope)
+	next     int
+}
+
+// NewMemoryBus returns an empty in-process bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{handlers: make(map[int]func(Envelope))}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, env Envelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(env)
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers, id)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBus) Close() error {
+	return nil
+}
+
+// recentIDs remembers the last N message IDs so each message is delivered once
+// even when it arrives both locally and back through the bus
+type recentIDs struct {
+	set  map[string]struct{}
+	ring []string
+	next int
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{set: make(map[string]struct{}, size), ring: make([]string, size)}
+}
+
+// seen reports whether id was already recorded, recording it if not
+func (r *recentIDs) seen(id string) bool {
+	if _, ok := r.set[id]; ok {
+		return true
+	}
+	if old := r.ring[r.next]; old != "" {
+		delete(r.set, old)
+	}
+	r.ring[r.next] = id
+	r.set[id] = struct{}{}
+	r.next = (r.next + 1) % len(r.ring)
+	return false
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"UploadDocument-Saas/internal/metrics"
+)
+
+// KafkaBus carries hub messages over a Kafka topic. The bus is a fan-out, so rather
+// than a consumer group, which would need a stable name per replica and be left behind
+// on every restart, each replica reads every partition directly from the tail.
+type KafkaBus struct {
+	broker string
+	topic  string
+	writer *kafka.Writer
+
+	mu      sync.Mutex
+	readers []*kafka.Reader
+}
+
+// NewKafkaBus returns a bus on topic
+func NewKafkaBus(broker, topic string) *KafkaBus {
+	return &KafkaBus{
+		broker: broker,
+		topic:  topic,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(broker),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+// Publish writes env keyed by tenant and topic, so a topic's messages stay ordered
+func (b *KafkaBus) Publish(ctx context.Context, env Envelope) error {
+	value, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = b.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(topicKey(env.TenantID, env.Topic)),
+		Value: value,
+	})
+	metrics.ObserveKafkaProduce(b.writer.Topic, 1, start, err)
+	return err
+}
+
+// Subscribe reads every partition of the topic, as it was when the subscription
+// started, and calls handler for each envelope, one at a time
+func (b *KafkaBus) Subscribe(ctx context.Context, handler func(Envelope)) error {
+	partitions, err := b.partitions(ctx)
+	if err != nil {
+		return err
+	}
+	var handlerMu sync.Mutex
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{b.broker},
+			Topic:     b.topic,
+			Partition: partition,
+			MaxWait:   100 * time.Millisecond,
+		})
+		if err := reader.SetOffset(kafka.LastOffset); err != nil {
+			reader.Close()
+			return err
+		}
+		b.mu.Lock()
+		b.readers = append(b.readers, reader)
+		b.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.read(ctx, reader, func(env Envelope) {
+				handlerMu.Lock()
+				defer handlerMu.Unlock()
+				handler(env)
+			})
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// partitions looks up the topic's partition IDs, retrying until the broker answers or
+// ctx is done
+func (b *KafkaBus) partitions(ctx context.Context) ([]int, error) {
+	for {
+		conn, err := kafka.DialContext(ctx, "tcp", b.broker)
+		if err == nil {
+			var partitions []kafka.Partition
+			partitions, err = conn.ReadPartitions(b.topic)
+			conn.Close()
+			if err == nil && len(partitions) > 0 {
+				ids := make([]int, len(partitions))
+				for i, p := range partitions {
+					ids[i] = p.ID
+				}
+				return ids, nil
+			}
+		}
+		slog.Error("Error looking up hub bus partitions", "topic", b.topic, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (b *KafkaBus) read(ctx context.Context, reader *kafka.Reader, handler func(Envelope)) {
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
+				return
+			}
+			slog.Error("Error reading hub bus", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		metrics.ObserveKafkaConsume("hub", msg)
+		var env Envelope
+		if err := json.Unmarshal(msg.Value, &env); err != nil {
+			slog.Warn("Dropping malformed hub bus message", "error", err)
+			continue
+		}
+		handler(env)
+	}
+}
+
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := []error{b.writer.Close()}
+	for _, reader := range b.readers {
+		errs = append(errs, reader.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusRelaysBetweenHubsOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus()
+	a, b := startHub(t), startHub(t)
+	a.ConnectBus(ctx, bus, "replica-a")
+	b.ConnectBus(ctx, bus, "replica-b")
+	waitForSubscribers(t, bus, 2)
+
+	onA := subscribeClient(t, a, "acme", "folder:root")
+	onB := subscribeClient(t, b, "acme", "folder:root")
+
+	a.Publish("acme", "folder:root", "document.created", map[string]string{"name": "report.pdf"})
+	for name, client := range map[string]*Client{"publishing hub": onA, "other hub": onB} {
+		events := receivedEvents(t, client, 200*time.Millisecond)
+		if len(events) != 1 || events[0].Event != "document.created" {
+			t.Errorf("%s: got %d events %+v, want one document.created", name, len(events), events)
+		}
+	}
+}
+
+func TestMemoryBusDropsDuplicateEnvelopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus()
+	h := startHub(t)
+	h.ConnectBus(ctx, bus, "replica-a")
+	waitForSubscribers(t, bus, 1)
+	client := subscribeClient(t, h, "acme", "document:1")
+
+	env := Envelope{
+		ID:       newMessageID(),
+		Origin:   "replica-b",
+		TenantID: "acme",
+		Topic:    "document:1",
+		Event:    "comment.created",
+		Data:     json.RawMessage(`{}`),
+	}
+	// A broker may deliver the same envelope more than once
+	for i := 0; i < 3; i++ {
+		if err := bus.Publish(ctx, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events := receivedEvents(t, client, 200*time.Millisecond); len(events) != 1 {
+		t.Errorf("got %d events for one envelope, want 1", len(events))
+	}
+}
+
+func TestMemoryBusIgnoresOwnEnvelopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus()
+	h := startHub(t)
+	h.ConnectBus(ctx, bus, "replica-a")
+	waitForSubscribers(t, bus, 1)
+	client := subscribeClient(t, h, "acme", "document:1")
+
+	env := Envelope{ID: newMessageID(), Origin: "replica-a", TenantID: "acme", Topic: "document:1", Event: "comment.created"}
+	if err := bus.Publish(ctx, env); err != nil {
+		t.Fatal(err)
+	}
+	if events := receivedEvents(t, client, 100*time.Millisecond); len(events) != 0 {
+		t.Errorf("hub delivered %d of its own envelopes back to itself", len(events))
+	}
+}
+
+// waitForSubscribers waits until n hubs have subscribed to bus
+func waitForSubscribers(t *testing.T, bus *MemoryBus, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		bus.mu.RLock()
+		got := len(bus.handlers)
+		bus.mu.RUnlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("only some of %d hubs subscribed to the bus", n)
+}
@@ -0,0 +1,324 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+
+	"UploadDocument-Saas/internal/auth"
+)
+
+// authTimeout bounds how long an unauthenticated connection may wait before sending its auth frame
+const authTimeout = 10 * time.Second
+
+type Client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	principal *auth.Principal
+	topics    map[string]bool // tenant-qualified topic keys, owned by the hub
+	closeCode int             // set by the hub before it closes send
+	closeText string
+	lastSeen  atomic.Int64 // unix nanos of the last inbound frame or pong
+}
+
+// newClient builds a hub client; conn is nil for clients served over SSE
+func (h *Hub) newClient(conn *websocket.Conn, principal *auth.Principal) *Client {
+	client := &Client{
+		hub:       h,
+		conn:      conn,
+		send:      make(chan []byte, h.cfg.SendBuffer),
+		principal: principal,
+		topics:    make(map[string]bool),
+	}
+	client.touch()
+	return client
+}
+
+func (c *Client) touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (c *Client) lastSeenAt() time.Time {
+	return time.Unix(0, c.lastSeen.Load())
+}
+
+// userKey identifies the client's user across tenants for per-user connection caps
+func (c *Client) userKey() string {
+	return c.principal.TenantID + "|" + c.principal.UserID
+}
+
+// controlFrame is a client to server message:
+//
+//	{"type":"auth","token":"..."}
+//	{"type":"subscribe","topic":"folder:<id>","id":"1"}
+//	{"type":"subscribe","topic":"folder:<id>","id":"1","epoch":"<epoch>","last_seq":41}
+//	{"type":"unsubscribe","topic":"folder:<id>","id":"2"}
+//	{"type":"activity","topic":"document:<id>","state":"typing","field":"name"}
+//	{"type":"ping"}
+type controlFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	ID    string `json:"id,omitempty"`
+	State string `json:"state,omitempty"`
+	Field string `json:"field,omitempty"`
+	// Epoch and LastSeq resume a topic after a reconnect; both come from earlier frames
+	Epoch   string  `json:"epoch,omitempty"`
+	LastSeq *uint64 `json:"last_seq,omitempty"`
+}
+
+// reply is a server to client response to a control frame; ID echoes the request.
+// Ready, subscribed and resync_required replies carry the hub epoch; the latter two
+// also carry the topic's current Seq.
+type reply struct {
+	Type   string `json:"type"`
+	ID     string `json:"id,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// eventFrame is how a hub Message is framed on the wire. Seq increases by one per
+// message on the topic within the hub's epoch.
+type eventFrame struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+	Message
+}
+
+// HandleWebSocket upgrades an authenticated connection. The token may be supplied
+// as a bearer Authorization header, a ?token= query parameter, or in a first
+// {"type":"auth"} frame sent within authTimeout of connecting.
+func (h *Hub) HandleWebSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	principal, err := h.requestPrincipal(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid authorization token",
+		})
+	}
+
+	return websocket.New(func(conn *websocket.Conn) {
+		p := principal
+		if p == nil {
+			var err error
+			if p, err = h.authenticateFirstFrame(conn); err != nil {
+				closeWith(conn, websocket.ClosePolicyViolation, "authentication required")
+				return
+			}
+		}
+		h.serveClient(conn, p)
+	})(c)
+}
+
+// requestPrincipal resolves a bearer Authorization header or ?token= query parameter.
+// It returns a nil principal when the request carries neither.
+func (h *Hub) requestPrincipal(c *fiber.Ctx) (*auth.Principal, error) {
+	token := c.Get("Authorization")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		return nil, nil
+	}
+	return h.tokens.Authenticate(token)
+}
+
+// authenticateFirstFrame reads the connection's first frame and resolves its token
+func (h *Hub) authenticateFirstFrame(conn *websocket.Conn) (*auth.Principal, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(authTimeout)); err != nil {
+		return nil, err
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var frame controlFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Type != "auth" {
+		return nil, errors.New("first frame must be an auth frame")
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return h.tokens.Authenticate(frame.Token)
+}
+
+func closeWith(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+	_ = conn.Close()
+}
+
+// serveClient registers an authenticated connection with the hub and runs its pumps
+func (h *Hub) serveClient(conn *websocket.Conn, principal *auth.Principal) {
+	client := h.newClient(conn, principal)
+	if !h.registerClient(client) {
+		if h.closing.Load() {
+			closeWith(conn, CloseGoingAway, "server shutting down")
+		} else {
+			closeWith(conn, CloseTryAgainLater, "too many connections")
+		}
+		return
+	}
+	client.reply(reply{Type: "ready", UserID: principal.UserID, Epoch: h.epoch})
+
+	var closeOnce sync.Once
+	cleanup := func() {
+		closeOnce.Do(func() {
+			h.unregister <- client
+			_ = conn.Close()
+			h.dropPresence(client)
+			h.conns.Done()
+		})
+	}
+
+	go client.writePump(cleanup)
+
+	// Any inbound frame, including pongs, proves the peer is alive
+	conn.SetReadLimit(maxFrameSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		client.touch()
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	ctx := auth.WithPrincipal(context.Background(), principal)
+	for {
+		mt, message, err := conn.ReadMessage()
+		if err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				h.counters.heartbeatTimeout.Add(1)
+			}
+			break
+		}
+		client.touch()
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+		if mt == websocket.TextMessage || mt == websocket.BinaryMessage {
+			client.handleControl(ctx, message)
+		}
+	}
+	cleanup()
+}
+
+// writePump is the connection's only writer: it drains send, pings the peer every
+// pingPeriod, and sends a close frame once the hub closes send
+func (c *Client) writePump(cleanup func()) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		cleanup()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				code, text := c.closeCode, c.closeText
+				if code == 0 {
+					code = websocket.CloseNormalClosure
+				}
+				closeWith(c.conn, code, text)
+				return
+			}
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// reply queues a response for this client through the hub
+func (c *Client) reply(r reply) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	c.hub.direct <- direct{client: c, data: data}
+}
+
+// subscribe authorizes frame.Topic for the client and follows it
+func (c *Client) subscribe(ctx context.Context, frame controlFrame) error {
+	if err := c.hub.authorizeTopic(ctx, c.principal, frame.Topic); err != nil {
+		if !errors.Is(err, ErrInvalidTopic) && !errors.Is(err, ErrTopicForbidden) {
+			slog.ErrorContext(ctx, "Error authorizing topic", "topic", frame.Topic, "error", err)
+			err = errors.New("subscription failed")
+		}
+		return err
+	}
+	c.follow(frame)
+	return nil
+}
+
+// follow adds an authorized topic to the hub, resuming from frame.LastSeq when given,
+// and records the client's presence there
+func (c *Client) follow(frame controlFrame) {
+	applied := make(chan bool, 1)
+	sub := subscription{
+		client:  c,
+		key:     topicKey(c.principal.TenantID, frame.Topic),
+		add:     true,
+		ack:     reply{Type: "subscribed", ID: frame.ID, Topic: frame.Topic},
+		applied: applied,
+	}
+	if frame.LastSeq != nil {
+		sub.resume, sub.epoch, sub.lastSeq = true, frame.Epoch, *frame.LastSeq
+	}
+	c.hub.subscriptions <- sub
+	if <-applied {
+		c.hub.joinPresence(c, frame.Topic)
+	}
+}
+
+// handleControl processes one control frame from the client
+func (c *Client) handleControl(ctx context.Context, data []byte) {
+	var frame controlFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		c.reply(reply{Type: "error", Error: "malformed frame"})
+		return
+	}
+	switch frame.Type {
+	case "subscribe":
+		if err := c.subscribe(ctx, frame); err != nil {
+			c.reply(reply{Type: "error", ID: frame.ID, Topic: frame.Topic, Error: err.Error()})
+		}
+	case "unsubscribe":
+		c.hub.subscriptions <- subscription{
+			client: c,
+			key:    topicKey(c.principal.TenantID, frame.Topic),
+			ack:    reply{Type: "unsubscribed", ID: frame.ID, Topic: frame.Topic},
+		}
+		c.hub.leavePresence(c, frame.Topic)
+	case "activity":
+		switch frame.State {
+		case PresenceViewing, PresenceTyping, PresenceEditing:
+		default:
+			c.reply(reply{Type: "error", ID: frame.ID, Topic: frame.Topic, Error: "unknown activity state"})
+			return
+		}
+		if err := c.hub.reportActivity(c, frame.Topic, frame.State, frame.Field); err != nil {
+			c.reply(reply{Type: "error", ID: frame.ID, Topic: frame.Topic, Error: err.Error()})
+		}
+	case "ping":
+		c.reply(reply{Type: "pong", ID: frame.ID})
+	case "auth":
+		c.reply(reply{Type: "error", ID: frame.ID, Error: "already authenticated"})
+	default:
+		c.reply(reply{Type: "error", ID: frame.ID, Error: "unknown frame type"})
+	}
+}
@@ -0,0 +1,229 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/auth"
+)
+
+// startHub runs a hub with the default settings for the rest of the test binary
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub(config.Defaults().Hub, nil, nil)
+	go h.Run()
+	return h
+}
+
+// subscribeClient admits a client of tenantID to h, subscribes it to topic and
+// discards the subscription ack
+func subscribeClient(t *testing.T, h *Hub, tenantID, topic string) *Client {
+	t.Helper()
+	client := &Client{
+		hub:       h,
+		send:      make(chan []byte, 64),
+		principal: &auth.Principal{UserID: "user-" + tenantID, TenantID: tenantID, Role: auth.RoleUser},
+		topics:    make(map[string]bool),
+	}
+	if !h.registerClient(client) {
+		t.Fatalf("client of %s was not admitted", tenantID)
+	}
+	applied := make(chan bool, 1)
+	h.subscriptions <- subscription{
+		client:  client,
+		key:     topicKey(tenantID, topic),
+		add:     true,
+		ack:     reply{Type: "subscribed", Topic: topic},
+		applied: applied,
+	}
+	if !<-applied {
+		t.Fatalf("subscription of %s to %s was not applied", tenantID, topic)
+	}
+	<-client.send // the ack
+	return client
+}
+
+// waitSequenced waits until Run has sequenced n published messages; Publish only
+// queues them
+func waitSequenced(t *testing.T, h *Hub, n uint64) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		h.mu.RLock()
+		seq := h.seqClock
+		h.mu.RUnlock()
+		if seq >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hub sequenced %d of %d messages", seq, n)
+		}
+	}
+}
+
+// receivedEvents collects the event frames client receives within wait
+func receivedEvents(t *testing.T, client *Client, wait time.Duration) []eventFrame {
+	t.Helper()
+	var events []eventFrame
+	deadline := time.After(wait)
+	for {
+		select {
+		case data := <-client.send:
+			var frame eventFrame
+			if err := json.Unmarshal(data, &frame); err != nil {
+				t.Fatalf("undecodable frame %s: %v", data, err)
+			}
+			if frame.Type == "event" {
+				events = append(events, frame)
+			}
+		case <-deadline:
+			return events
+		}
+	}
+}
+
+func TestPublishNeverCrossesTenants(t *testing.T) {
+	h := startHub(t)
+	acme := subscribeClient(t, h, "acme", "document:1")
+	globex := subscribeClient(t, h, "globex", "document:1")
+
+	// Both tenants follow a topic with the same ID; only the publisher's tenant hears it
+	h.Publish("globex", "document:1", "comment.created", map[string]string{"body": "confidential"})
+	if events := receivedEvents(t, globex, 200*time.Millisecond); len(events) != 1 {
+		t.Errorf("globex subscriber got %d events, want 1", len(events))
+	}
+	if events := receivedEvents(t, acme, 100*time.Millisecond); len(events) != 0 {
+		t.Errorf("acme subscriber got globex's events: %+v", events)
+	}
+}
+
+func TestRelayedEventsNeverCrossTenants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus()
+	a, b := startHub(t), startHub(t)
+	a.ConnectBus(ctx, bus, "replica-a")
+	b.ConnectBus(ctx, bus, "replica-b")
+	waitForSubscribers(t, bus, 2)
+	acme := subscribeClient(t, b, "acme", "folder:root")
+	globex := subscribeClient(t, b, "globex", "folder:root")
+
+	a.Publish("globex", "folder:root", "document.created", map[string]string{"name": "payroll.xlsx"})
+	if events := receivedEvents(t, globex, 200*time.Millisecond); len(events) != 1 {
+		t.Errorf("globex subscriber on the other replica got %d events, want 1", len(events))
+	}
+	if events := receivedEvents(t, acme, 100*time.Millisecond); len(events) != 0 {
+		t.Errorf("acme subscriber on the other replica got globex's events: %+v", events)
+	}
+}
+
+// resumeClient admits a client of tenantID with a send buffer of the hub's size and
+// resumes topic from lastSeq in the hub's epoch
+func resumeClient(t *testing.T, h *Hub, tenantID, topic string, lastSeq uint64) *Client {
+	t.Helper()
+	client := h.newClient(nil, &auth.Principal{UserID: "user-" + tenantID, TenantID: tenantID, Role: auth.RoleUser})
+	if !h.registerClient(client) {
+		t.Fatalf("client of %s was not admitted", tenantID)
+	}
+	applied := make(chan bool, 1)
+	h.subscriptions <- subscription{
+		client:  client,
+		key:     topicKey(tenantID, topic),
+		add:     true,
+		ack:     reply{Type: "subscribed", Topic: topic},
+		resume:  true,
+		epoch:   h.epoch,
+		lastSeq: lastSeq,
+		applied: applied,
+	}
+	if !<-applied {
+		t.Fatalf("subscription of %s to %s was not applied", tenantID, topic)
+	}
+	return client
+}
+
+// frameTypes drains the frames queued for client and returns their types
+func frameTypes(t *testing.T, client *Client) []string {
+	t.Helper()
+	var types []string
+	for {
+		select {
+		case data := <-client.send:
+			var frame struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(data, &frame); err != nil {
+				t.Fatalf("undecodable frame %s: %v", data, err)
+			}
+			types = append(types, frame.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestResumeBeyondSendBufferResyncs(t *testing.T) {
+	const sendBuffer = 8
+	tests := []struct {
+		name      string
+		published int
+		want      []string
+	}{
+		// Everything missed is still buffered for replay, but more than the client can queue
+		{"gap larger than the send buffer", sendBuffer + 4, []string{"subscribed", "resync_required"}},
+		{"gap that fits", sendBuffer - 1, append([]string{"subscribed"}, repeat("event", sendBuffer-1)...)},
+	}
+	for _, policy := range []string{SlowConsumerDropOldest, SlowConsumerDisconnect} {
+		for _, tt := range tests {
+			t.Run(policy+"/"+tt.name, func(t *testing.T) {
+				cfg := config.Defaults().Hub
+				cfg.SendBuffer, cfg.ReplayBuffer, cfg.SlowConsumerPolicy = sendBuffer, 64, policy
+				h := NewHub(cfg, nil, nil)
+				go h.Run()
+
+				for i := 0; i < tt.published; i++ {
+					h.Publish("acme", "document:1", "comment.created", map[string]int{"n": i})
+				}
+				waitSequenced(t, h, uint64(tt.published))
+				client := resumeClient(t, h, "acme", "document:1", 0)
+				if got := frameTypes(t, client); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("frames = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPublishDropsWhenTheHubFallsBehind(t *testing.T) {
+	// Run is not started, so nothing drains the broadcast queue
+	h := NewHub(config.Defaults().Hub, nil, nil)
+	const extra = 5
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < broadcastBuffer+extra; i++ {
+			h.Publish("acme", "document:1", "comment.created", map[string]int{"n": i})
+		}
+		h.publishLocal("acme", "document:1", "comment.created", map[string]int{"n": -1})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a full broadcast queue")
+	}
+	m := h.Metrics()
+	if m.BroadcastsDropped != extra+1 || m.MessagesPublished != broadcastBuffer+extra+1 {
+		t.Errorf("metrics = %+v, want %d broadcasts dropped of %d published", m, extra+1, broadcastBuffer+extra+1)
+	}
+}
+
+func repeat(s string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = s
+	}
+	return out
+}
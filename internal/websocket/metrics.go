@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hubCounters are monotonically increasing hub statistics
+type hubCounters struct {
+	published        atomic.Uint64
+	delivered        atomic.Uint64
+	dropped          atomic.Uint64
+	broadcastDropped atomic.Uint64
+	slowDisconnects  atomic.Uint64
+	rejected         atomic.Uint64
+	heartbeatTimeout atomic.Uint64
+}
+
+// HubMetrics is a point-in-time snapshot of the hub
+type HubMetrics struct {
+	Clients             int    `json:"clients"`
+	Users               int    `json:"users"`
+	Topics              int    `json:"topics"`
+	MessagesPublished   uint64 `json:"messages_published"`
+	MessagesDelivered   uint64 `json:"messages_delivered"`
+	MessagesDropped     uint64 `json:"messages_dropped"`
+	BroadcastsDropped   uint64 `json:"broadcasts_dropped"`
+	SlowDisconnects     uint64 `json:"slow_consumer_disconnects"`
+	RejectedConnections uint64 `json:"rejected_connections"`
+	HeartbeatTimeouts   uint64 `json:"heartbeat_timeouts"`
+}
+
+// Metrics returns a snapshot of connected clients and message counters
+func (h *Hub) Metrics() HubMetrics {
+	h.mu.RLock()
+	m := HubMetrics{
+		Clients: len(h.clients),
+		Users:   len(h.users),
+		Topics:  len(h.topics),
+	}
+	h.mu.RUnlock()
+	m.MessagesPublished = h.counters.published.Load()
+	m.MessagesDelivered = h.counters.delivered.Load()
+	m.MessagesDropped = h.counters.dropped.Load()
+	m.BroadcastsDropped = h.counters.broadcastDropped.Load()
+	m.SlowDisconnects = h.counters.slowDisconnects.Load()
+	m.RejectedConnections = h.counters.rejected.Load()
+	m.HeartbeatTimeouts = h.counters.heartbeatTimeout.Load()
+	return m
+}
+
+var (
+	hubGauges = map[string]*prometheus.Desc{
+		"clients": prometheus.NewDesc("websocket_connections", "Connected websocket and SSE clients.", nil, nil),
+		"users":   prometheus.NewDesc("websocket_users", "Distinct users with at least one connection.", nil, nil),
+		"topics":  prometheus.NewDesc("websocket_topics", "Topics with at least one subscriber.", nil, nil),
+	}
+	hubCounterDescs = map[string]*prometheus.Desc{
+		"published":        prometheus.NewDesc("websocket_messages_published_total", "Messages published to the hub.", nil, nil),
+		"delivered":        prometheus.NewDesc("websocket_messages_delivered_total", "Messages queued to subscribers.", nil, nil),
+		"dropped":          prometheus.NewDesc("websocket_messages_dropped_total", "Messages dropped for slow consumers.", nil, nil),
+		"broadcastDropped": prometheus.NewDesc("websocket_broadcasts_dropped_total", "Published messages dropped because the hub fell behind.", nil, nil),
+		"slowDisconnects":  prometheus.NewDesc("websocket_slow_consumer_disconnects_total", "Clients disconnected for falling behind.", nil, nil),
+		"rejected":         prometheus.NewDesc("websocket_rejected_connections_total", "Connections refused by the connection caps.", nil, nil),
+		"heartbeatTimeout": prometheus.NewDesc("websocket_heartbeat_timeouts_total", "Connections closed after missing heartbeats.", nil, nil),
+	}
+)
+
+// Describe implements prometheus.Collector
+func (h *Hub) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range hubGauges {
+		ch <- d
+	}
+	for _, d := range hubCounterDescs {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector from a Metrics snapshot
+func (h *Hub) Collect(ch chan<- prometheus.Metric) {
+	m := h.Metrics()
+	for name, v := range map[string]int{"clients": m.Clients, "users": m.Users, "topics": m.Topics} {
+		ch <- prometheus.MustNewConstMetric(hubGauges[name], prometheus.GaugeValue, float64(v))
+	}
+	for name, v := range map[string]uint64{
+		"published":        m.MessagesPublished,
+		"delivered":        m.MessagesDelivered,
+		"dropped":          m.MessagesDropped,
+		"broadcastDropped": m.BroadcastsDropped,
+		"slowDisconnects":  m.SlowDisconnects,
+		"rejected":         m.RejectedConnections,
+		"heartbeatTimeout": m.HeartbeatTimeouts,
+	} {
+		ch <- prometheus.MustNewConstMetric(hubCounterDescs[name], prometheus.CounterValue, float64(v))
+	}
+}
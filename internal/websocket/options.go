@@ -0,0 +1,26 @@
+package websocket
+
+import "time"
+
+const (
+	// writeWait bounds a single frame write
+	writeWait = 10 * time.Second
+	// pongWait is how long a connection may stay silent before it is considered dead
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait so a healthy peer always answers in time
+	pingPeriod = pongWait * 9 / 10
+	// maxFrameSize bounds inbound control frames
+	maxFrameSize = 64 * 1024
+)
+
+// Slow consumer policies applied when a client's send buffer is full
+const (
+	SlowConsumerDropOldest = "drop_oldest"
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// Close codes sent to clients the hub turns away or drops
+const (
+	CloseGoingAway     = 1001
+	CloseTryAgainLater = 1013
+)
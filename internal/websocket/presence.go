@@ -0,0 +1,407 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// presenceTTL is how long a viewer stays listed without a heartbeat: two missed pings
+	presenceTTL = 2 * pingPeriod
+	// activityTTL is how long a typing or editing indicator lasts unless refreshed
+	activityTTL = 15 * time.Second
+	// presenceSweep is how often stale presence and indicators are expired
+	presenceSweep = 5 * time.Second
+	// presenceSync is how often each replica shares its viewers with the others
+	presenceSync = 30 * time.Second
+	// maxActivityField bounds the form field name carried by an indicator
+	maxActivityField = 64
+)
+
+// Presence states a viewer may report
+const (
+	PresenceViewing = "viewing"
+	PresenceTyping  = "typing"
+	PresenceEditing = "editing"
+)
+
+// Presence events delivered to topic subscribers
+const (
+	EventPresenceJoined   = "presence.joined"
+	EventPresenceLeft     = "presence.left"
+	EventPresenceActivity = "presence.activity"
+	// eventPresenceSync carries a replica's viewers over the bus; it is never sent to clients
+	eventPresenceSync = "presence.sync"
+)
+
+// ErrNotViewing is returned for activity on a topic the connection is not subscribed to
+var ErrNotViewing = errors.New("not subscribed to topic")
+
+// Viewer is one user currently looking at a folder or document
+type Viewer struct {
+	UserID string    `json:"user_id"`
+	State  string    `json:"state"`
+	Field  string    `json:"field,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// presenceEvent is the payload of presence events
+type presenceEvent struct {
+	UserID string    `json:"user_id"`
+	State  string    `json:"state,omitempty"`
+	Field  string    `json:"field,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// presenceSnapshot is the payload of presence.sync
+type presenceSnapshot struct {
+	Viewers []Viewer `json:"viewers"`
+}
+
+// presenceEntry is one local connection's presence on one topic
+type presenceEntry struct {
+	since      time.Time
+	state      string
+	field      string
+	activityAt time.Time
+}
+
+// remotePresence is what another replica last reported for one topic
+type remotePresence struct {
+	viewers   map[string]Viewer
+	refreshed time.Time
+}
+
+// presenceTracker records who is viewing which folder and document topics. Local
+// connections are tracked individually; other replicas' viewers are learned from the
+// bus and expire unless refreshed.
+type presenceTracker struct {
+	mu     sync.Mutex
+	local  map[string]map[*Client]*presenceEntry
+	remote map[string]map[string]*remotePresence // topic key -> origin
+}
+
+func newPresenceTracker() *presenceTracker {
+	return &presenceTracker{
+		local:  make(map[string]map[*Client]*presenceEntry),
+		remote: make(map[string]map[string]*remotePresence),
+	}
+}
+
+// tracksPresence reports whether topic is a folder or document topic
+func tracksPresence(topic string) bool {
+	return strings.HasPrefix(topic, "folder:") || strings.HasPrefix(topic, "document:")
+}
+
+// splitTopicKey undoes topicKey
+func splitTopicKey(key string) (tenantID, topic string) {
+	tenantID, topic, _ = strings.Cut(key, "|")
+	return tenantID, topic
+}
+
+// userConnections counts client's user's connections on key. Callers hold p.mu.
+func (p *presenceTracker) userConnections(key, userID string) int {
+	n := 0
+	for c := range p.local[key] {
+		if c.principal.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+// join records client on key, reporting whether it is the user's first connection there
+func (p *presenceTracker) join(client *Client, key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.local[key] == nil {
+		p.local[key] = make(map[*Client]*presenceEntry)
+	}
+	if _, ok := p.local[key][client]; ok {
+		return false
+	}
+	first := p.userConnections(key, client.principal.UserID) == 0
+	p.local[key][client] = &presenceEntry{since: time.Now().UTC(), state: PresenceViewing}
+	return first
+}
+
+// leave forgets client on key, reporting whether the user has no connection left there
+func (p *presenceTracker) leave(client *Client, key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.leaveLocked(client, key)
+}
+
+func (p *presenceTracker) leaveLocked(client *Client, key string) bool {
+	if _, ok := p.local[key][client]; !ok {
+		return false
+	}
+	delete(p.local[key], client)
+	if len(p.local[key]) == 0 {
+		delete(p.local, key)
+	}
+	return p.userConnections(key, client.principal.UserID) == 0
+}
+
+// leaveAll forgets client everywhere, returning the keys its user has left
+func (p *presenceTracker) leaveAll(client *Client) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var left []string
+	for key, clients := range p.local {
+		if _, ok := clients[client]; ok && p.leaveLocked(client, key) {
+			left = append(left, key)
+		}
+	}
+	return left
+}
+
+// setActivity updates client's state on key
+func (p *presenceTracker) setActivity(client *Client, key, state, field string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.local[key][client]
+	if !ok {
+		return ErrNotViewing
+	}
+	entry.state, entry.field, entry.activityAt = state, field, time.Now()
+	if state == PresenceViewing {
+		entry.field = ""
+	}
+	return nil
+}
+
+// observe applies a presence event another replica published
+func (p *presenceTracker) observe(origin, key, event string, data json.RawMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.remote[key] == nil {
+		p.remote[key] = make(map[string]*remotePresence)
+	}
+	rp := p.remote[key][origin]
+	if rp == nil {
+		rp = &remotePresence{viewers: make(map[string]Viewer)}
+		p.remote[key][origin] = rp
+	}
+	rp.refreshed = time.Now()
+
+	if event == eventPresenceSync {
+		var snap presenceSnapshot
+		if json.Unmarshal(data, &snap) != nil {
+			return
+		}
+		rp.viewers = make(map[string]Viewer, len(snap.Viewers))
+		for _, v := range snap.Viewers {
+			rp.viewers[v.UserID] = v
+		}
+		return
+	}
+	var ev presenceEvent
+	if json.Unmarshal(data, &ev) != nil || ev.UserID == "" {
+		return
+	}
+	switch event {
+	case EventPresenceJoined:
+		rp.viewers[ev.UserID] = Viewer{UserID: ev.UserID, State: PresenceViewing, Since: ev.At}
+	case EventPresenceActivity:
+		v := rp.viewers[ev.UserID]
+		v.UserID, v.State, v.Field = ev.UserID, ev.State, ev.Field
+		if v.Since.IsZero() {
+			v.Since = ev.At
+		}
+		rp.viewers[ev.UserID] = v
+	case EventPresenceLeft:
+		delete(rp.viewers, ev.UserID)
+	}
+}
+
+// viewers merges local and remote presence on key, one entry per user
+func (p *presenceTracker) viewers(key string) []Viewer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	byUser := make(map[string]Viewer)
+	merge := func(v Viewer) {
+		cur, ok := byUser[v.UserID]
+		if !ok {
+			byUser[v.UserID] = v
+			return
+		}
+		if stateRank(v.State) > stateRank(cur.State) {
+			cur.State, cur.Field = v.State, v.Field
+		}
+		if v.Since.Before(cur.Since) {
+			cur.Since = v.Since
+		}
+		byUser[v.UserID] = cur
+	}
+	for c, e := range p.local[key] {
+		merge(Viewer{UserID: c.principal.UserID, State: e.state, Field: e.field, Since: e.since})
+	}
+	for _, rp := range p.remote[key] {
+		for _, v := range rp.viewers {
+			merge(v)
+		}
+	}
+	out := make([]Viewer, 0, len(byUser))
+	for _, v := range byUser {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	return out
+}
+
+func stateRank(state string) int {
+	switch state {
+	case PresenceEditing:
+		return 2
+	case PresenceTyping:
+		return 1
+	}
+	return 0
+}
+
+// presenceChange is an event the sweeper needs published
+type presenceChange struct {
+	key   string
+	event string
+	data  presenceEvent
+}
+
+// expire drops connections that missed their heartbeats, clears lapsed indicators and
+// forgets replicas that stopped reporting, returning the events to publish locally
+// (local) and everywhere (global)
+func (p *presenceTracker) expire(now time.Time) (global, local []presenceChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, clients := range p.local {
+		for c, e := range clients {
+			if now.Sub(c.lastSeenAt()) > presenceTTL {
+				if p.leaveLocked(c, key) {
+					global = append(global, presenceChange{key, EventPresenceLeft, presenceEvent{UserID: c.principal.UserID, At: now.UTC()}})
+				}
+				continue
+			}
+			if e.state != PresenceViewing && now.Sub(e.activityAt) > activityTTL {
+				e.state, e.field = PresenceViewing, ""
+				global = append(global, presenceChange{key, EventPresenceActivity, presenceEvent{UserID: c.principal.UserID, State: PresenceViewing, At: now.UTC()}})
+			}
+		}
+	}
+	for key, origins := range p.remote {
+		for origin, rp := range origins {
+			if now.Sub(rp.refreshed) <= presenceTTL {
+				continue
+			}
+			delete(origins, origin)
+			for userID := range rp.viewers {
+				local = append(local, presenceChange{key, EventPresenceLeft, presenceEvent{UserID: userID, At: now.UTC()}})
+			}
+		}
+		if len(origins) == 0 {
+			delete(p.remote, key)
+		}
+	}
+	return global, local
+}
+
+// localSnapshots returns this replica's viewers per topic key
+func (p *presenceTracker) localSnapshots() map[string][]Viewer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string][]Viewer, len(p.local))
+	for key, clients := range p.local {
+		seen := make(map[string]bool)
+		for c, e := range clients {
+			if seen[c.principal.UserID] {
+				continue
+			}
+			seen[c.principal.UserID] = true
+			out[key] = append(out[key], Viewer{UserID: c.principal.UserID, State: e.state, Field: e.field, Since: e.since})
+		}
+	}
+	return out
+}
+
+// publishPresence announces a presence change on key to every replica
+func (h *Hub) publishPresence(key, event string, ev presenceEvent) {
+	tenantID, topic := splitTopicKey(key)
+	h.Publish(tenantID, topic, event, ev)
+}
+
+// joinPresence records client as viewing topic and announces the user's arrival
+func (h *Hub) joinPresence(client *Client, topic string) {
+	if !tracksPresence(topic) {
+		return
+	}
+	key := topicKey(client.principal.TenantID, topic)
+	if h.presence.join(client, key) {
+		h.publishPresence(key, EventPresenceJoined, presenceEvent{UserID: client.principal.UserID, State: PresenceViewing, At: time.Now().UTC()})
+	}
+}
+
+// leavePresence removes client from topic and announces the user's departure
+func (h *Hub) leavePresence(client *Client, topic string) {
+	key := topicKey(client.principal.TenantID, topic)
+	if h.presence.leave(client, key) {
+		h.publishPresence(key, EventPresenceLeft, presenceEvent{UserID: client.principal.UserID, At: time.Now().UTC()})
+	}
+}
+
+// dropPresence removes a disconnected client from every topic it was viewing
+func (h *Hub) dropPresence(client *Client) {
+	for _, key := range h.presence.leaveAll(client) {
+		h.publishPresence(key, EventPresenceLeft, presenceEvent{UserID: client.principal.UserID, At: time.Now().UTC()})
+	}
+}
+
+// reportActivity records a typing or editing indicator for client on topic
+func (h *Hub) reportActivity(client *Client, topic, state, field string) error {
+	if len(field) > maxActivityField {
+		field = field[:maxActivityField]
+	}
+	key := topicKey(client.principal.TenantID, topic)
+	if err := h.presence.setActivity(client, key, state, field); err != nil {
+		return err
+	}
+	h.publishPresence(key, EventPresenceActivity, presenceEvent{UserID: client.principal.UserID, State: state, Field: field, At: time.Now().UTC()})
+	return nil
+}
+
+// Viewers lists who is currently viewing topic within tenantID across all replicas
+func (h *Hub) Viewers(tenantID, topic string) []Viewer {
+	return h.presence.viewers(topicKey(tenantID, topic))
+}
+
+// runPresence expires stale presence and, when a bus is connected, shares this
+// replica's viewers so other replicas can list them
+func (h *Hub) runPresence() {
+	sweep := time.NewTicker(presenceSweep)
+	share := time.NewTicker(presenceSync)
+	defer sweep.Stop()
+	defer share.Stop()
+	for {
+		select {
+		case now := <-sweep.C:
+			global, local := h.presence.expire(now)
+			for _, ch := range global {
+				h.publishPresence(ch.key, ch.event, ch.data)
+			}
+			for _, ch := range local {
+				tenantID, topic := splitTopicKey(ch.key)
+				h.publishLocal(tenantID, topic, ch.event, ch.data)
+			}
+		case <-share.C:
+			if !h.busConnected.Load() {
+				continue
+			}
+			for key, viewers := range h.presence.localSnapshots() {
+				tenantID, topic := splitTopicKey(key)
+				h.relay(tenantID, topic, eventPresenceSync, presenceSnapshot{Viewers: viewers})
+			}
+		}
+	}
+}
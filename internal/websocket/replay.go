@@ -0,0 +1,106 @@
+package websocket
+
+import "time"
+
+// replayRetention is how long an idle topic's replay buffer outlives its last subscriber
+const replayRetention = 10 * time.Minute
+
+// replayEntry is one framed message kept for replay
+type replayEntry struct {
+	seq     uint64
+	payload []byte
+}
+
+// replayBuffer is a bounded ring of a topic's most recent frames with their sequence numbers
+type replayBuffer struct {
+	entries []replayEntry
+	start   int    // index of the oldest entry
+	last    uint64 // sequence number of the newest message, zero before any
+	touched time.Time
+}
+
+func newReplayBuffer(capacity int, base uint64) *replayBuffer {
+	return &replayBuffer{entries: make([]replayEntry, 0, capacity), last: base, touched: time.Now()}
+}
+
+// append stores payload under the next sequence number
+func (b *replayBuffer) append(seq uint64, payload []byte) {
+	b.last = seq
+	b.touched = time.Now()
+	e := replayEntry{seq: seq, payload: payload}
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, e)
+		return
+	}
+	b.entries[b.start] = e
+	b.start = (b.start + 1) % len(b.entries)
+}
+
+// since returns the frames after lastSeq in order, or false when some of them are
+// no longer buffered or lastSeq was never issued
+func (b *replayBuffer) since(lastSeq uint64) ([][]byte, bool) {
+	if lastSeq > b.last {
+		return nil, false
+	}
+	if lastSeq == b.last {
+		return nil, true
+	}
+	if len(b.entries) == 0 || b.entries[b.start].seq > lastSeq+1 {
+		return nil, false
+	}
+	out := make([][]byte, 0, b.last-lastSeq)
+	for i := 0; i < len(b.entries); i++ {
+		e := b.entries[(b.start+i)%len(b.entries)]
+		if e.seq > lastSeq {
+			out = append(out, e.payload)
+		}
+	}
+	return out, true
+}
+
+// nextSeq returns the sequence number for the next message on key, creating the
+// topic's buffer if needed. New buffers start above every number this hub has issued
+// so a recreated topic never reuses a sequence. Callers hold h.mu.
+func (h *Hub) nextSeq(key string) (uint64, *replayBuffer) {
+	buf := h.replay[key]
+	if buf == nil {
+		buf = newReplayBuffer(h.cfg.ReplayBuffer, h.seqClock)
+		h.replay[key] = buf
+	}
+	seq := buf.last + 1
+	if seq > h.seqClock {
+		h.seqClock = seq
+	}
+	return seq, buf
+}
+
+// missed returns the frames published on key after lastSeq in epoch. It reports false
+// when the client must resync because the gap cannot be replayed. Callers hold h.mu.
+func (h *Hub) missed(key, epoch string, lastSeq uint64) ([][]byte, bool) {
+	if epoch != h.epoch {
+		return nil, false
+	}
+	buf := h.replay[key]
+	if buf == nil {
+		return nil, lastSeq == 0
+	}
+	return buf.since(lastSeq)
+}
+
+// currentSeq is the last sequence number issued on key, zero if none. Callers hold h.mu.
+func (h *Hub) currentSeq(key string) uint64 {
+	if buf := h.replay[key]; buf != nil {
+		return buf.last
+	}
+	return 0
+}
+
+// pruneReplay drops buffers of topics nobody follows that have been idle for
+// replayRetention. Callers hold h.mu.
+func (h *Hub) pruneReplay(now time.Time) {
+	for key, buf := range h.replay {
+		if len(h.topics[key]) == 0 && now.Sub(buf.touched) > replayRetention {
+			delete(h.replay, key)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package websocket
+
+import "context"
+
+// Shutdown stops admitting clients and disconnects every connected one, websocket
+// clients with a going-away close frame and SSE clients with a close event, then
+// waits until their connections have ended or ctx is done
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.closing.Store(true)
+	h.mu.Lock()
+	for client := range h.clients {
+		client.closeCode, client.closeText = CloseGoingAway, "server shutting down"
+		h.remove(client)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
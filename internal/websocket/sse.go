@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"UploadDocument-Saas/internal/auth"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, short enough
+// to keep intermediate proxies from timing the stream out
+const sseKeepAlive = 15 * time.Second
+
+// sseRetry is the reconnect delay suggested to EventSource clients, in milliseconds
+const sseRetry = 3000
+
+// HandleEvents streams hub topics as Server-Sent Events for clients that cannot hold a
+// websocket. It must run after middleware.StreamAuthMiddleware, follows every ?topic=
+// given, and resumes from the Last-Event-ID header or ?last_event_id=.
+// Each event's id is a cursor over all requested topics, so a reconnect with the same
+// topics replays what was missed or receives a resync_required event per topic.
+func (h *Hub) HandleEvents(c *fiber.Ctx) error {
+	principal, ok := c.Locals("principal").(*auth.Principal)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid authorization token",
+		})
+	}
+
+	topics := eventTopics(c)
+	if len(topics) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one topic is required",
+		})
+	}
+	if len(topics) > maxTopicsPerClient {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Too many topics",
+		})
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), principal)
+	for _, topic := range topics {
+		if err := h.authorizeTopic(ctx, principal, topic); err != nil {
+			return topicError(c, topic, err)
+		}
+	}
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	cursor := parseEventCursor(lastEventID, len(topics))
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		h.serveEvents(w, principal, topics, cursor)
+	})
+	return nil
+}
+
+// eventTopics collects the distinct ?topic= values (repeated or comma separated) in a
+// stable order, so the cursor in event ids lines up across reconnects
+func eventTopics(c *fiber.Ctx) []string {
+	seen := make(map[string]bool)
+	var topics []string
+	for _, raw := range c.Context().QueryArgs().PeekMulti("topic") {
+		for _, topic := range strings.Split(string(raw), ",") {
+			if topic = strings.TrimSpace(topic); topic != "" && !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func topicError(c *fiber.Ctx, topic string, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidTopic):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid topic " + topic,
+		})
+	case errors.Is(err, ErrTopicForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Topic not accessible: " + topic,
+		})
+	}
+	slog.Error("Error authorizing topic", "topic", topic, "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Internal server error",
+	})
+}
+
+// eventCursor is the position of an event stream in each of its topics. Its text form,
+// used as the SSE event id, is "<epoch>/<seq>,<seq>,..." in topic order with an empty
+// field for topics whose position is not yet known.
+type eventCursor struct {
+	epoch string
+	seqs  []*uint64
+}
+
+func parseEventCursor(id string, topics int) eventCursor {
+	cursor := eventCursor{seqs: make([]*uint64, topics)}
+	epoch, rest, ok := strings.Cut(id, "/")
+	if !ok {
+		return cursor
+	}
+	fields := strings.Split(rest, ",")
+	if len(fields) != topics {
+		return cursor
+	}
+	cursor.epoch = epoch
+	for i, field := range fields {
+		if field == "" {
+			continue
+		}
+		if seq, err := strconv.ParseUint(field, 10, 64); err == nil {
+			cursor.seqs[i] = &seq
+		}
+	}
+	return cursor
+}
+
+func (c eventCursor) String() string {
+	fields := make([]string, len(c.seqs))
+	for i, seq := range c.seqs {
+		if seq != nil {
+			fields[i] = strconv.FormatUint(*seq, 10)
+		}
+	}
+	return c.epoch + "/" + strings.Join(fields, ",")
+}
+
+// serveEvents registers an SSE client with the hub and writes its frames until the
+// peer goes away or the hub drops it
+func (h *Hub) serveEvents(w *bufio.Writer, principal *auth.Principal, topics []string, resume eventCursor) {
+	client := h.newClient(nil, principal)
+	if !h.registerClient(client) {
+		writeEvent(w, "", "error", []byte(`{"type":"error","error":"too many connections"}`))
+		_ = w.Flush()
+		return
+	}
+	defer func() {
+		h.unregister <- client
+		h.dropPresence(client)
+		h.conns.Done()
+	}()
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+	client.reply(reply{Type: "ready", UserID: principal.UserID, Epoch: h.epoch})
+
+	index := make(map[string]int, len(topics))
+	cursor := eventCursor{epoch: h.epoch, seqs: make([]*uint64, len(topics))}
+	for i, topic := range topics {
+		index[topic] = i
+	}
+	// Follow the topics one at a time, writing out each ack and replay before the next,
+	// so that every topic's replay finds the send buffer empty instead of resyncing
+	open := writeQueued(w, client, index, &cursor)
+	for i, topic := range topics {
+		if !open {
+			break
+		}
+		frame := controlFrame{Topic: topic}
+		if resume.seqs[i] != nil {
+			frame.Epoch, frame.LastSeq = resume.epoch, resume.seqs[i]
+			cursor.seqs[i] = resume.seqs[i]
+		}
+		client.follow(frame)
+		open = writeQueued(w, client, index, &cursor)
+	}
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for open {
+		if err := w.Flush(); err != nil {
+			return
+		}
+		client.touch()
+		select {
+		case data, ok := <-client.send:
+			if !ok {
+				open = false
+				break
+			}
+			writeFrame(w, data, index, &cursor)
+			// Drain whatever else is queued before paying for a flush
+			open = writeQueued(w, client, index, &cursor)
+		case <-ticker.C:
+			_, _ = w.WriteString(": keepalive\n\n")
+		}
+	}
+	text := client.closeText
+	if text == "" {
+		text = "closed"
+	}
+	closeFrame, _ := json.Marshal(reply{Type: "close", Error: text})
+	writeEvent(w, "", "close", closeFrame)
+	_ = w.Flush()
+}
+
+// writeQueued writes the frames already queued for client, reporting false once the
+// hub has closed its send channel
+func writeQueued(w *bufio.Writer, client *Client, index map[string]int, cursor *eventCursor) bool {
+	for n := len(client.send); n > 0; n-- {
+		data, ok := <-client.send
+		if !ok {
+			return false
+		}
+		writeFrame(w, data, index, cursor)
+	}
+	return true
+}
+
+// writeFrame renders one hub frame as an SSE event. Hub events are named after their
+// event and carry the updated cursor as their id; replies are named after their type.
+func writeFrame(w *bufio.Writer, data []byte, index map[string]int, cursor *eventCursor) {
+	var head struct {
+		Type  string `json:"type"`
+		Topic string `json:"topic"`
+		Event string `json:"event"`
+		Seq   uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return
+	}
+	i, tracked := index[head.Topic]
+	switch head.Type {
+	case "event":
+		if tracked {
+			seq := head.Seq
+			cursor.seqs[i] = &seq
+		}
+		writeEvent(w, cursor.String(), head.Event, data)
+	case "subscribed", "resync_required":
+		if tracked && (cursor.seqs[i] == nil || head.Type == "resync_required") {
+			seq := head.Seq
+			cursor.seqs[i] = &seq
+		}
+		writeEvent(w, "", head.Type, data)
+	default:
+		writeEvent(w, "", head.Type, data)
+	}
+}
+
+func writeEvent(w *bufio.Writer, id, event string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/auth"
+)
+
+func TestResumingSeveralTopicsReplaysEachOfThem(t *testing.T) {
+	const sendBuffer, missed = 8, 5
+	cfg := config.Defaults().Hub
+	cfg.SendBuffer, cfg.ReplayBuffer = sendBuffer, 64
+	h := NewHub(cfg, nil, nil)
+	go h.Run()
+
+	// Together the replays are larger than the send buffer, each one alone is not
+	topics := []string{"document:1", "document:2", "document:3"}
+	resume := eventCursor{epoch: h.epoch, seqs: make([]*uint64, len(topics))}
+	for i, topic := range topics {
+		// Each topic's buffer starts where the previous one stopped, and the client
+		// saw only the first message of every topic
+		seq := uint64(i*(missed+1) + 1)
+		resume.seqs[i] = &seq
+		for n := 0; n <= missed; n++ {
+			h.Publish("acme", topic, "comment.created", map[string]int{"n": n})
+		}
+	}
+
+	waitSequenced(t, h, uint64(len(topics)*(missed+1)))
+
+	pr, pw := io.Pipe()
+	go func() {
+		principal := &auth.Principal{UserID: "user-acme", TenantID: "acme", Role: auth.RoleUser}
+		h.serveEvents(bufio.NewWriter(pw), principal, topics, resume)
+		pw.Close()
+	}()
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			event, ok := strings.CutPrefix(scanner.Text(), "event: ")
+			if ok && !strings.HasPrefix(event, "presence.") {
+				events <- event
+			}
+		}
+	}()
+
+	want := []string{"ready"}
+	for range topics {
+		want = append(append(want, "subscribed"), repeat("comment.created", missed)...)
+	}
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-timeout:
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		for range events {
+		}
+	}()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/repositories"
+)
+
+var (
+	// ErrInvalidTopic is returned for topics that are not of the form kind:id
+	ErrInvalidTopic = errors.New("invalid topic")
+	// ErrTopicForbidden is returned when a principal may not follow a topic
+	ErrTopicForbidden = errors.New("topic not accessible")
+)
+
+// rootFolder names the tenant root in folder topics
+const rootFolder = "root"
+
+// FolderTopic is the topic for activity inside a folder; the zero ID is the tenant root
+func FolderTopic(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return "folder:" + rootFolder
+	}
+	return "folder:" + id.Hex()
+}
+
+// DocumentTopic is the topic for activity on a single document
+func DocumentTopic(id primitive.ObjectID) string {
+	return "document:" + id.Hex()
+}
+
+// UserTopic is the topic for events addressed to a single user
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// authorizeTopic checks that principal may follow topic. Folder and document topics
+// must name a record in the principal's tenant; user topics only the principal itself.
+func (h *Hub) authorizeTopic(ctx context.Context, principal *auth.Principal, topic string) error {
+	kind, id, ok := strings.Cut(topic, ":")
+	if !ok || id == "" {
+		return ErrInvalidTopic
+	}
+	switch kind {
+	case "user":
+		if id != principal.UserID {
+			return ErrTopicForbidden
+		}
+		return nil
+	case "folder":
+		if id == rootFolder {
+			return nil
+		}
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return ErrInvalidTopic
+		}
+		_, err = h.store.GetFolder(ctx, oid)
+		return topicLookupError(err)
+	case "document":
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return ErrInvalidTopic
+		}
+		_, err = h.store.GetDocument(ctx, oid)
+		return topicLookupError(err)
+	}
+	return ErrInvalidTopic
+}
+
+func topicLookupError(err error) error {
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrTopicForbidden
+	}
+	return err
+}
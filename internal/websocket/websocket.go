@@ -1,134 +1,422 @@
 package websocket
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/websocket/v2"
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/auth"
+	"UploadDocument-Saas/internal/repositories"
 )
 
-type Client struct {
-	conn *websocket.Conn
-	send chan []byte
+const (
+	// maxTopicsPerClient bounds how many topics a single connection may follow
+	maxTopicsPerClient = 100
+	// recentMessageIDs is how many message IDs each hub remembers for de-duplication
+	recentMessageIDs = 8192
+	// outboundBuffer is how many messages may wait to be published to the bus
+	outboundBuffer = 1024
+	// broadcastBuffer is how many published messages may wait for Run to fan them out
+	broadcastBuffer = 1024
+)
+
+// Message is an event routed to the subscribers of one topic within one tenant
+type Message struct {
+	ID       string          `json:"id"`
+	TenantID string          `json:"-"`
+	Topic    string          `json:"topic"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// subscription adds or removes a client's interest in a tenant-qualified topic.
+// ack is delivered to the client once the change has been applied.
+type subscription struct {
+	client *Client
+	key    string
+	add    bool
+	ack    reply
+	// resume, when set, replays what the client missed since lastSeq in epoch
+	resume  bool
+	epoch   string
+	lastSeq uint64
+	// applied, when set, receives whether the change took effect
+	applied chan bool
+}
+
+// registration asks the hub to admit a client; the hub answers on result
+type registration struct {
+	client *Client
+	result chan bool
+}
+
+// direct is a frame addressed to a single client
+type direct struct {
+	client *Client
+	data   []byte
 }
 
 type Hub struct {
-	clients    map[*Client]bool
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan []byte
-	mu         sync.RWMutex
+	cfg           config.HubConfig
+	tokens        *auth.Tokens        // authenticates connections that bring their own token
+	store         *repositories.Store // holds the records folder and document topics name
+	clients       map[*Client]bool
+	users         map[string]int // connections per tenant-qualified user
+	topics        map[string]map[*Client]bool
+	register      chan registration
+	unregister    chan *Client
+	subscriptions chan subscription
+	direct        chan direct
+	broadcast     chan Message
+	outbound      chan Message
+	recent        *recentIDs
+	presence      *presenceTracker
+	replay        map[string]*replayBuffer // per topic key, owned by Run
+	seqClock      uint64                   // highest sequence number issued on any topic
+	epoch         string                   // names this hub's sequence space; random, so replay needs sticky sessions
+	origin        string
+	busConnected  atomic.Bool
+	closing       atomic.Bool    // set by Shutdown; no new clients are admitted
+	conns         sync.WaitGroup // admitted clients whose connection is still open
+	counters      hubCounters
+	mu            sync.RWMutex
 }
 
-func NewHub() *Hub {
+// NewHub returns a hub tuned by the hub config section that authenticates connections
+// with tokens and checks topics against the records in store. An unknown slow consumer
+// policy means drop_oldest.
+//
+// Sequence numbers and the replay buffer are local to one hub: every hub starts a
+// fresh random epoch, and a resume carrying another hub's epoch is answered with
+// resync_required. Behind a load balancer, route a client's reconnects to the same
+// replica (sticky sessions, e.g. by cookie or by user ID hash) or clients resync
+// instead of replaying after every reconnect.
+func NewHub(cfg config.HubConfig, tokens *auth.Tokens, store *repositories.Store) *Hub {
+	if cfg.SlowConsumerPolicy != SlowConsumerDisconnect {
+		cfg.SlowConsumerPolicy = SlowConsumerDropOldest
+	}
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
+		cfg:           cfg,
+		tokens:        tokens,
+		store:         store,
+		clients:       make(map[*Client]bool),
+		users:         make(map[string]int),
+		topics:        make(map[string]map[*Client]bool),
+		register:      make(chan registration),
+		unregister:    make(chan *Client),
+		subscriptions: make(chan subscription),
+		direct:        make(chan direct),
+		broadcast:     make(chan Message, broadcastBuffer),
+		outbound:      make(chan Message, outboundBuffer),
+		recent:        newRecentIDs(recentMessageIDs),
+		presence:      newPresenceTracker(),
+		replay:        make(map[string]*replayBuffer),
+		epoch:         newMessageID()[:16],
 	}
 }
 
-var HubInstance = NewHub()
+// topicKey qualifies a topic with its tenant so identical IDs in different tenants never meet
+func topicKey(tenantID, topic string) string {
+	return tenantID + "|" + topic
+}
 
+// Run owns the hub's maps; every write to a client's send channel happens here
 func (h *Hub) Run() {
+	go h.runPresence()
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
 	for {
 		select {
-		case client := <-h.register:
+		case now := <-prune.C:
 			h.mu.Lock()
-			h.clients[client] = true
+			h.pruneReplay(now)
+			h.mu.Unlock()
+		case reg := <-h.register:
+			h.mu.Lock()
+			reg.result <- h.admit(reg.client)
 			h.mu.Unlock()
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+			h.remove(client)
+			h.mu.Unlock()
+		case sub := <-h.subscriptions:
+			h.mu.Lock()
+			h.applySubscription(sub)
+			h.mu.Unlock()
+		case d := <-h.direct:
+			h.mu.Lock()
+			if h.clients[d.client] {
+				h.deliver(d.client, d.data)
 			}
 			h.mu.Unlock()
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			if h.recent.seen(message.ID) {
+				continue
+			}
+			key := topicKey(message.TenantID, message.Topic)
+			h.mu.Lock()
+			seq, buf := h.nextSeq(key)
+			payload, err := json.Marshal(eventFrame{Type: "event", Seq: seq, Message: message})
+			if err != nil {
+				h.mu.Unlock()
+				slog.Error("Error encoding hub message", "topic", message.Topic, "error", err)
+				continue
 			}
-			h.mu.RUnlock()
+			buf.append(seq, payload)
+			for client := range h.topics[key] {
+				h.deliver(client, payload)
+			}
+			h.mu.Unlock()
 		}
 	}
 }
 
-// func HandleWebSocket(c *fiber.Ctx) error {
-// 	if websocket.IsWebSocketUpgrade(c) {
-// 		return websocket.New(func(conn *websocket.Conn) {
-// 			client := &Client{conn: conn, send: make(chan []byte, 256)}
-// 			HubInstance.register <- client
-
-// 			go func() {
-// 				for {
-// 					mt, message, err := conn.ReadMessage()
-// 					if err != nil {
-// 						break
-// 					}
-// 					if mt == websocket.TextMessage || mt == websocket.BinaryMessage {
-// 						log.Printf("recv: %s", message)
-// 					}
-// 				}
-// 				HubInstance.unregister <- client
-// 				conn.Close()
-// 			}()
-
-//				// Write pump
-//				for msg := range client.send {
-//					if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-//						break
-//					}
-//				}
-//			})(c)
-//		}
-//		return fiber.ErrUpgradeRequired
-//	}
-func HandleWebSocket(c *fiber.Ctx) error {
-	if !websocket.IsWebSocketUpgrade(c) {
-		return fiber.ErrUpgradeRequired
-	}
-	return websocket.New(func(conn *websocket.Conn) {
-		client := &Client{conn: conn, send: make(chan []byte, 256)}
-		HubInstance.register <- client
-
-		var closeOnce sync.Once
-		cleanup := func() {
-			closeOnce.Do(func() {
-				HubInstance.unregister <- client
-				_ = conn.Close()
-			})
+// admit registers client unless the hub is shutting down or the global or per-user
+// connection cap is reached. Callers hold h.mu.
+func (h *Hub) admit(client *Client) bool {
+	if h.closing.Load() {
+		return false
+	}
+	if len(h.clients) >= h.cfg.MaxConnections || h.users[client.userKey()] >= h.cfg.MaxConnectionsPerUser {
+		h.counters.rejected.Add(1)
+		return false
+	}
+	h.clients[client] = true
+	h.users[client.userKey()]++
+	h.conns.Add(1)
+	return true
+}
+
+// deliver queues data for client. When the client's buffer is full the slow consumer
+// policy either discards the oldest queued frame or disconnects the client. Callers hold h.mu.
+func (h *Hub) deliver(client *Client, data []byte) {
+	select {
+	case client.send <- data:
+		h.counters.delivered.Add(1)
+		return
+	default:
+	}
+	if h.cfg.SlowConsumerPolicy == SlowConsumerDisconnect {
+		h.counters.dropped.Add(1)
+		h.counters.slowDisconnects.Add(1)
+		client.closeCode, client.closeText = CloseTryAgainLater, "slow consumer"
+		h.remove(client)
+		return
+	}
+	select {
+	case <-client.send:
+		h.counters.dropped.Add(1)
+	default:
+	}
+	select {
+	case client.send <- data:
+		h.counters.delivered.Add(1)
+	default:
+		h.counters.dropped.Add(1)
+	}
+}
+
+// remove forgets a client and closes its send channel. Callers hold h.mu.
+func (h *Hub) remove(client *Client) {
+	if !h.clients[client] {
+		return
+	}
+	delete(h.clients, client)
+	if h.users[client.userKey()]--; h.users[client.userKey()] <= 0 {
+		delete(h.users, client.userKey())
+	}
+	for key := range client.topics {
+		delete(h.topics[key], client)
+		if len(h.topics[key]) == 0 {
+			delete(h.topics, key)
 		}
+	}
+	close(client.send)
+}
 
-		go func() {
-			defer cleanup()
-			for msg := range client.send {
-				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-					break
-				}
+// applySubscription updates the topic index for sub and acknowledges it. Callers hold h.mu.
+func (h *Hub) applySubscription(sub subscription) {
+	client := sub.client
+	applied := false
+	defer func() {
+		if sub.applied != nil {
+			sub.applied <- applied
+		}
+	}()
+	if !h.clients[client] {
+		return
+	}
+	if sub.add {
+		if !client.topics[sub.key] && len(client.topics) >= maxTopicsPerClient {
+			sub.ack = reply{Type: "error", ID: sub.ack.ID, Topic: sub.ack.Topic, Error: "too many subscriptions"}
+		} else {
+			applied = true
+			if h.topics[sub.key] == nil {
+				h.topics[sub.key] = make(map[*Client]bool)
 			}
-		}()
+			h.topics[sub.key][client] = true
+			client.topics[sub.key] = true
+		}
+	} else if client.topics[sub.key] {
+		applied = true
+		delete(client.topics, sub.key)
+		delete(h.topics[sub.key], client)
+		if len(h.topics[sub.key]) == 0 {
+			delete(h.topics, sub.key)
+		}
+	}
+	if !applied || !sub.add {
+		h.ack(client, sub.ack)
+		return
+	}
+	// The ack and any replay are queued before the next broadcast, so the client sees
+	// an unbroken sequence from its last_seq onwards
+	sub.ack.Epoch, sub.ack.Seq = h.epoch, h.currentSeq(sub.key)
+	var missed [][]byte
+	resync := false
+	if sub.resume {
+		var ok bool
+		missed, ok = h.missed(sub.key, sub.epoch, sub.lastSeq)
+		// A replay that does not fit next to the ack would be cut short by the slow
+		// consumer policy, leaving a silent gap; a client that far behind resyncs instead
+		resync = !ok || len(missed)+1 > cap(client.send)-len(client.send)
+	}
+	h.ack(client, sub.ack)
+	if resync {
+		h.ack(client, reply{Type: "resync_required", ID: sub.ack.ID, Topic: sub.ack.Topic, Epoch: h.epoch, Seq: sub.ack.Seq})
+		return
+	}
+	for _, frame := range missed {
+		h.deliver(client, frame)
+	}
+}
+
+// ack queues a reply for client. Callers hold h.mu.
+func (h *Hub) ack(client *Client, r reply) {
+	if data, err := json.Marshal(r); err == nil {
+		h.deliver(client, data)
+	}
+}
+
+// ConnectBus relays this hub's messages to other replicas through bus and delivers
+// theirs to local subscribers, until ctx is done. Call it before publishing.
+func (h *Hub) ConnectBus(ctx context.Context, bus Bus, origin string) {
+	h.origin = origin
+	h.busConnected.Store(true)
 
+	go func() {
 		for {
-			mt, message, err := conn.ReadMessage()
-			if err != nil {
-				break
+			select {
+			case <-ctx.Done():
+				return
+			case message := <-h.outbound:
+				env := Envelope{
+					ID:       message.ID,
+					Origin:   h.origin,
+					TenantID: message.TenantID,
+					Topic:    message.Topic,
+					Event:    message.Event,
+					Data:     message.Data,
+				}
+				if err := bus.Publish(ctx, env); err != nil {
+					slog.Error("Error publishing hub message to bus", "error", err)
+				}
+			}
+		}
+	}()
+
+	go func() {
+		err := bus.Subscribe(ctx, func(env Envelope) {
+			if env.Origin == h.origin {
+				return
+			}
+			if strings.HasPrefix(env.Event, "presence.") {
+				h.presence.observe(env.Origin, topicKey(env.TenantID, env.Topic), env.Event, env.Data)
+				if env.Event == eventPresenceSync {
+					return
+				}
 			}
-			if mt == websocket.TextMessage || mt == websocket.BinaryMessage {
-				log.Printf("recv: %s", message)
+			h.broadcast <- Message{
+				ID:       env.ID,
+				TenantID: env.TenantID,
+				Topic:    env.Topic,
+				Event:    env.Event,
+				Data:     env.Data,
 			}
+		})
+		if err != nil {
+			slog.Error("Hub bus subscription ended", "error", err)
 		}
-		cleanup()
-	})(c)
+	}()
+}
+
+// Publish delivers an event to this hub's subscribers of topic within tenantID and,
+// when a bus is connected, to every other replica's subscribers
+func (h *Hub) Publish(tenantID, topic, event string, data interface{}) {
+	message, ok := newMessage(tenantID, topic, event, data)
+	if !ok {
+		return
+	}
+	h.counters.published.Add(1)
+	h.enqueue(message)
+	h.relayMessage(message)
+}
+
+// publishLocal delivers an event to this hub's subscribers only
+func (h *Hub) publishLocal(tenantID, topic, event string, data interface{}) {
+	if message, ok := newMessage(tenantID, topic, event, data); ok {
+		h.counters.published.Add(1)
+		h.enqueue(message)
+	}
+}
+
+// enqueue hands message to Run without blocking the publisher. When Run has fallen
+// a whole buffer behind the message is dropped and counted rather than stalling the
+// request or worker that published it.
+func (h *Hub) enqueue(message Message) {
+	select {
+	case h.broadcast <- message:
+	default:
+		h.counters.broadcastDropped.Add(1)
+		slog.Warn("Hub broadcast backlog full; event dropped", "event", message.Event, "topic", message.Topic)
+	}
+}
+
+// relay sends an event to the other replicas only
+func (h *Hub) relay(tenantID, topic, event string, data interface{}) {
+	if message, ok := newMessage(tenantID, topic, event, data); ok {
+		h.relayMessage(message)
+	}
+}
+
+func (h *Hub) relayMessage(message Message) {
+	if !h.busConnected.Load() {
+		return
+	}
+	select {
+	case h.outbound <- message:
+	default:
+		slog.Warn("Hub bus backlog full; event not relayed", "event", message.Event, "topic", message.Topic)
+	}
+}
+
+func newMessage(tenantID, topic, event string, data interface{}) (Message, bool) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Error encoding hub event", "event", event, "topic", topic, "error", err)
+		return Message{}, false
+	}
+	return Message{ID: newMessageID(), TenantID: tenantID, Topic: topic, Event: event, Data: raw}, true
 }
 
-func BroadcastMessage(message []byte) {
-	HubInstance.broadcast <- message
+// registerClient asks the hub to admit client, reporting whether it was accepted
+func (h *Hub) registerClient(client *Client) bool {
+	result := make(chan bool, 1)
+	h.register <- registration{client: client, result: result}
+	return <-result
 }
@@ -1,37 +1,161 @@
+// Package logger configures structured JSON logging through log/slog.
+//
+// Records go to a rotating log file, stdout or both at a minimum level that SetLevel
+// can change while the process runs. Attributes attached to a context with With, such
+// as the request ID, tenant and user, are added to every record logged with that
+// context, as are the trace and span IDs of its active span.
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-const maxLogSize = 10 * 1024 * 1024
+var level = new(slog.LevelVar)
 
-func InitLogFile() *os.File {
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Printf("Error creating logs directory: %v", err)
-		return nil
+// Options configures Init
+type Options struct {
+	Level  string // debug, info, warn or error
+	Output string // file, stdout or both
+	// File is rotated past MaxSizeMB or every RotateInterval, keeping MaxBackups
+	// backups, gzipped when Compress is set. Zero disables each limit.
+	File           string
+	MaxSizeMB      int64
+	MaxBackups     int
+	RotateInterval time.Duration
+	Compress       bool
+}
+
+// Init installs the JSON logger as the slog default, which also routes the standard
+// log package through it. The returned file, nil when logging only to stdout, should
+// be closed on exit.
+func Init(opts Options) *RotatingFile {
+	if err := SetLevel(opts.Level); err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring log level: %v\n", err)
 	}
 
-	logPath := "logs/app.log"
+	var out io.Writer = os.Stdout
+	var logFile *RotatingFile
+	switch strings.ToLower(opts.Output) {
+	case "stdout":
+	case "both":
+		if logFile = openLogFile(opts); logFile != nil {
+			out = io.MultiWriter(os.Stdout, logFile)
+		}
+	default:
+		if logFile = openLogFile(opts); logFile != nil {
+			out = logFile
+		}
+	}
+
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{AddSource: true, Level: level})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	if logFile != nil {
+		reopenOnHangup(logFile)
+	}
+	slog.Info("Application started", "level", Level())
+	return logFile
+}
+
+func openLogFile(opts Options) *RotatingFile {
+	logFile := &RotatingFile{
+		Path:       opts.File,
+		MaxSize:    opts.MaxSizeMB * 1024 * 1024,
+		Interval:   opts.RotateInterval,
+		MaxBackups: opts.MaxBackups,
+		Compress:   opts.Compress,
+	}
+	if err := logFile.Open(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening log file: %v\n", err)
+		return nil
+	}
+	return logFile
+}
 
-	fileInfo, err := os.Stat(logPath)
-	if err == nil && fileInfo.Size() > maxLogSize {
-		if err := os.Truncate(logPath, 0); err != nil {
-			log.Printf("Error truncating log file: %v", err)
-			return nil
+// reopenOnHangup reopens logFile on SIGHUP, so an external logrotate can move it away
+func reopenOnHangup(logFile *RotatingFile) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := logFile.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reopening log file: %v\n", err)
+				continue
+			}
+			slog.Info("Reopened log file", "path", logFile.Path)
 		}
+	}()
+}
+
+// SetLevel changes the minimum level logged; an empty name means info
+func SetLevel(name string) error {
+	if name == "" {
+		name = "info"
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
 	}
+	level.Set(l)
+	return nil
+}
+
+// Level returns the name of the current minimum level
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+type ctxKey struct{}
+
+// With returns a copy of ctx whose log records carry args, given as slog key-value
+// pairs or attributes, in addition to any attached earlier
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := attrsFrom(ctx)
+	combined := make([]slog.Attr, 0, len(attrs)+len(args))
+	combined = append(combined, attrs...)
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		combined = append(combined, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, combined)
+}
 
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Printf("Error opening log file: %v", err)
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
 		return nil
 	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
 
-	log.SetOutput(logFile)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.Println("Application started")
+// contextHandler adds the attributes attached by With and the active span's IDs to each record
+type contextHandler struct {
+	slog.Handler
+}
 
-	return logFile
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(attrsFrom(ctx)...)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
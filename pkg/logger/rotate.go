@@ -0,0 +1,242 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is a log file that rotates itself once it grows past MaxSize bytes or
+// its Interval has passed, keeping MaxBackups old files, gzipped when Compress is set.
+// Reopen reopens the path without rotating, for external tools such as logrotate.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64         // zero disables size-based rotation
+	Interval   time.Duration // zero disables time-based rotation
+	MaxBackups int           // zero keeps every backup
+	Compress   bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	mill   sync.WaitGroup
+	millMu sync.Mutex
+}
+
+// Open opens or creates the file at r.Path for appending
+func (r *RotatingFile) Open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.open()
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	// A file left over from an earlier period rotates on its first write in this one
+	r.period = r.periodOf(info.ModTime())
+	if r.size == 0 {
+		r.period = r.periodOf(time.Now())
+	}
+	return nil
+}
+
+func (r *RotatingFile) periodOf(t time.Time) time.Time {
+	if r.Interval <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(r.Interval)
+}
+
+// Write appends p, rotating first if p would take the file past MaxSize or the
+// current interval has ended
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.size > 0 && (r.MaxSize > 0 && r.size+int64(len(p)) > r.MaxSize ||
+		r.Interval > 0 && !r.periodOf(time.Now()).Equal(r.period)) {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing records
+			fmt.Fprintf(os.Stderr, "Error rotating log file: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside as a backup and starts a new one
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	ext := filepath.Ext(r.Path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.Path, ext), time.Now().UTC().Format(backupTimeFormat), ext)
+	renameErr := os.Rename(r.Path, backup)
+	if err := r.open(); err != nil {
+		return err
+	}
+	if os.IsNotExist(renameErr) {
+		// The file was moved away externally, so there is no backup to mill
+		return nil
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	r.mill.Add(1)
+	go r.millBackups(backup)
+	return nil
+}
+
+// Reopen closes and reopens r.Path, picking up a file that was moved away externally
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	return r.open()
+}
+
+// Close closes the file and waits for pending compression. A nil RotatingFile is a no-op.
+func (r *RotatingFile) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+	r.mill.Wait()
+	return err
+}
+
+// millBackups compresses a freshly rotated backup and deletes the oldest beyond MaxBackups
+func (r *RotatingFile) millBackups(backup string) {
+	defer r.mill.Done()
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	if r.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "Error compressing log backup %s: %v\n", backup, err)
+		}
+	}
+	if r.MaxBackups <= 0 {
+		return
+	}
+	backups, err := r.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing log backups: %v\n", err)
+		return
+	}
+	for _, files := range backups[:max(0, len(backups)-r.MaxBackups)] {
+		for _, old := range files {
+			if err := os.Remove(old); err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing old log backup %s: %v\n", old, err)
+			}
+		}
+	}
+}
+
+// backups returns the files of each backup of r.Path, oldest first. Only names with a
+// backup timestamp count, so other logs sharing the prefix (app-worker.log beside
+// app.log) are left alone, and a backup caught mid-compression, with both its plain
+// and its gzipped file, counts once.
+func (r *RotatingFile) backups() ([][]string, error) {
+	dir := filepath.Dir(r.Path)
+	ext := filepath.Ext(r.Path)
+	prefix := strings.TrimSuffix(filepath.Base(r.Path), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]string{}
+	var stamps []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if stamp, ok = strings.CutSuffix(strings.TrimSuffix(stamp, ".gz"), ext); !ok {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		if files[stamp] == nil {
+			stamps = append(stamps, stamp)
+		}
+		files[stamp] = append(files[stamp], filepath.Join(dir, entry.Name()))
+	}
+	// The timestamps sort in age order
+	sort.Strings(stamps)
+	backups := make([][]string, len(stamps))
+	for i, stamp := range stamps {
+		backups[i] = files[stamp]
+	}
+	return backups, nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
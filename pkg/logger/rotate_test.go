@@ -0,0 +1,235 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listDir returns the names in dir, sorted
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func write(t *testing.T, r *RotatingFile, s string) {
+	t.Helper()
+	if _, err := r.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// backupName is the name of a backup of app.log rotated at at
+func backupName(at time.Time) string {
+	return "app-" + at.UTC().Format(backupTimeFormat) + ".log"
+}
+
+func TestRotatesWhenAWriteWouldPassMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{Path: filepath.Join(dir, "app.log"), MaxSize: 10}
+	write(t, r, "12345678\n")
+	write(t, r, "a\n") // 11 bytes would pass MaxSize
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := listDir(t, dir)
+	if len(names) != 2 || names[1] != "app.log" {
+		t.Fatalf("files = %v, want one backup and app.log", names)
+	}
+	if got := readFile(t, filepath.Join(dir, names[0])); got != "12345678\n" {
+		t.Errorf("backup holds %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "a\n" {
+		t.Errorf("app.log holds %q", got)
+	}
+}
+
+func TestRotatesALeftoverFileFromAnEarlierInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("yesterday\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-26 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &RotatingFile{Path: path, Interval: 24 * time.Hour}
+	write(t, r, "today\n")
+	write(t, r, "still today\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := listDir(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("files = %v, want one backup and app.log", names)
+	}
+	if got := readFile(t, filepath.Join(dir, names[0])); got != "yesterday\n" {
+		t.Errorf("backup holds %q", got)
+	}
+	if got := readFile(t, path); got != "today\nstill today\n" {
+		t.Errorf("app.log holds %q", got)
+	}
+}
+
+func TestPrunesBackupsBeyondMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	create := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldest, older, old := backupName(base), backupName(base.Add(time.Minute)), backupName(base.Add(2*time.Minute))
+	create(oldest)
+	// A backup caught mid-compression counts once
+	create(older)
+	create(older + ".gz")
+	create(old + ".gz")
+	// Other files sharing the prefix are not backups of app.log
+	for _, other := range []string{"app-worker.log", "app-worker-" + base.UTC().Format(backupTimeFormat) + ".log", "app-notes.txt"} {
+		create(other)
+	}
+
+	r := &RotatingFile{Path: filepath.Join(dir, "app.log"), MaxBackups: 2}
+	write(t, r, "current\n")
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := listDir(t, dir)
+	for _, gone := range []string{oldest, older, older + ".gz"} {
+		for _, name := range names {
+			if name == gone {
+				t.Errorf("%s was kept", gone)
+			}
+		}
+	}
+	var backups []string
+	for _, name := range names {
+		if name != "app.log" && !strings.HasPrefix(name, "app-worker") && name != "app-notes.txt" {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) != 2 || backups[0] != old+".gz" {
+		t.Errorf("backups = %v, want %s and the new one", backups, old+".gz")
+	}
+	if len(names)-len(backups) != 4 {
+		t.Errorf("files = %v, want app.log and the three other files untouched", names)
+	}
+}
+
+func TestCompressesBackups(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{Path: filepath.Join(dir, "app.log"), Compress: true}
+	write(t, r, "compress me\n")
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := listDir(t, dir)
+	if len(names) != 2 || !strings.HasSuffix(names[0], ".log.gz") {
+		t.Fatalf("files = %v, want a gzipped backup and app.log", names)
+	}
+	f, err := os.Open(filepath.Join(dir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(zr); err != nil || string(got) != "compress me\n" {
+		t.Errorf("backup holds %q, %v", got, err)
+	}
+}
+
+func TestReopenFollowsAFileMovedAway(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	r := &RotatingFile{Path: path}
+	write(t, r, "before\n")
+
+	// logrotate moves the file, then signals the process
+	moved := filepath.Join(dir, "app.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "after\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, moved); got != "before\n" {
+		t.Errorf("moved file holds %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("reopened file holds %q", got)
+	}
+}
+
+func TestRotateWithoutAFileMakesNoBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// More backups than MaxBackups allows, left by an earlier configuration
+	base := time.Now().Add(-time.Hour)
+	existing := []string{backupName(base), backupName(base.Add(time.Minute))}
+	for _, name := range existing {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &RotatingFile{Path: path, Compress: true, MaxBackups: 1}
+	write(t, r, "moved away\n")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("Rotate = %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing was rotated, so nothing is compressed or pruned
+	want := append(existing, "app.log")
+	if names := listDir(t, dir); strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
@@ -3,48 +3,135 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 
+	"UploadDocument-Saas/config"
+	"UploadDocument-Saas/internal/audit"
+	"UploadDocument-Saas/internal/auth"
 	"UploadDocument-Saas/internal/handlers"
+	"UploadDocument-Saas/internal/metrics"
 	"UploadDocument-Saas/internal/middleware"
+	"UploadDocument-Saas/internal/ratelimit"
 	"UploadDocument-Saas/internal/websocket"
 )
 
+// Dependencies are the components the routes are served by
+type Dependencies struct {
+	Handler *handlers.Handler
+	Audit   *audit.Log
+	Tokens  *auth.Tokens
+	Limiter *ratelimit.Limiter
+	Hub     *websocket.Hub
+}
+
 // SetupRoutes registers all application routes.
-func SetupRoutes(app *fiber.App) {
+func SetupRoutes(app *fiber.App, cfg *config.Config, deps Dependencies) {
+	h := deps.Handler
+
 	// Global Middleware
-	app.Use(middleware.CORSMiddleware()) // CORS support
-	app.Use(middleware.Logger())         // Custom logger
-	app.Use(middleware.Recovery())       // Recover from panics
+	app.Use(middleware.RequestID())         // X-Request-ID on responses and log records
+	app.Use(middleware.TracingMiddleware()) // OpenTelemetry server spans
+	app.Use(middleware.MetricsMiddleware()) // Prometheus request metrics
+	app.Use(middleware.AccessLog())         // One structured log record per request
+	app.Use(middleware.CORSMiddleware())    // CORS support
+	app.Use(middleware.Recovery())          // Recover from panics
 
 	// Health Check (public endpoint)
-	app.Get("/health", handlers.HealthCheck)
+	app.Get("/health", h.HealthCheck)
+	app.Get("/health/live", h.Liveness)
+	app.Get("/health/ready", middleware.MetricsDetails(cfg.Metrics.Token), h.Readiness) // details need the metrics token
 
-	// WebSocket for real-time communication
-	app.Get("/ws", websocket.HandleWebSocket)
+	// Prometheus scrape endpoint, restricted to METRICS_TOKEN or private networks
+	app.Get("/metrics", middleware.MetricsAccess(cfg.Metrics.Token), metrics.Handler())
 
-	// Static file serving for uploads
-	app.Static("/uploads", "./uploads")
+	// WebSocket for real-time communication; authenticates itself via header, ?token= or first frame
+	app.Get("/ws", deps.Hub.HandleWebSocket)
 
-	// API routes group with rate limiting
+	// File downloads, authorized by an HMAC-signed URL minted via /api/document/:id/download-url
+	app.Get("/download/:id", h.DownloadDocument)
+
+	// Public share links (the token itself grants access)
+	app.Get("/s/:token", middleware.RateLimitMiddleware(deps.Limiter, ratelimit.BudgetRead), h.GetSharedContent)
+	app.Post("/s/:token", middleware.RateLimitMiddleware(deps.Limiter, ratelimit.BudgetUpload), h.UploadToShare)
+
+	// API routes group; each group is rate limited after authentication so buckets are per caller
 	api := app.Group("/api")
-	api.Use(middleware.RateLimitMiddleware())
+	read := middleware.RateLimitMiddleware(deps.Limiter, ratelimit.BudgetRead)
+	upload := middleware.RateLimitMiddleware(deps.Limiter, ratelimit.BudgetUpload)
+	search := middleware.RateLimitMiddleware(deps.Limiter, ratelimit.BudgetSearch)
+
+	// Server-Sent Events fallback for /ws; the token may also be sent as ?token=
+	api.Get("/events", middleware.StreamAuthMiddleware(deps.Tokens), read, deps.Hub.HandleEvents)
+
+	// Document routes (tenant scoped)
+	document := api.Group("/document", middleware.AuthMiddleware(deps.Tokens))
+	document.Post("/upload", upload, h.UploadDocument)
+	document.Get("/search", search, h.SearchDocuments)
+	document.Use(read)
+	document.Get("/:id", middleware.AuditMiddleware(deps.Audit, audit.ActionDocumentViewed, audit.TargetDocument, "id"), h.GetDocumentByID)
+	document.Patch("/:id", h.MoveDocument)
+	document.Delete("/:id", h.DeleteDocument)
+	document.Post("/:id/purge", middleware.RequireRole(auth.RoleAdmin), h.PurgeDocument)
+	document.Post("/:id/download-url", h.CreateDownloadURL)
+	document.Get("/:id/viewers", h.DocumentViewers)
+	document.Post("/:id/versions", upload, h.UploadDocumentVersion)
+	document.Get("/:id/versions", h.ListDocumentVersions)
+	document.Post("/:id/comments", h.CreateComment)
+	document.Get("/:id/comments", h.ListComments)
+	document.Get("/", h.ListDocuments)
+
+	// Folder routes (tenant scoped)
+	folder := api.Group("/folder", middleware.AuthMiddleware(deps.Tokens), read)
+	folder.Get("/", h.ListFolders)
+	folder.Post("/", h.CreateFolder)
+	folder.Get("/watches", h.ListWatches)
+	folder.Get("/:id/viewers", h.FolderViewers)
+	folder.Put("/:id/watch", h.WatchFolder)
+	folder.Delete("/:id/watch", h.UnwatchFolder)
+
+	// Share link management (tenant scoped)
+	share := api.Group("/share", middleware.AuthMiddleware(deps.Tokens), read)
+	share.Post("/", h.CreateShareLink)
+	share.Get("/", h.ListShareLinks)
+	share.Get("/received/:id", h.GetReceivedShare)
+	share.Delete("/:id", h.RevokeShareLink)
+
+	// Storage usage and quota of the caller's tenant
+	api.Get("/usage", middleware.AuthMiddleware(deps.Tokens), read, h.GetUsage)
+
+	// Notification center for the caller
+	notification := api.Group("/notifications", middleware.AuthMiddleware(deps.Tokens), read)
+	notification.Get("/", h.ListNotifications)
+	notification.Get("/unread-count", h.UnreadNotificationCount)
+	notification.Post("/read", h.MarkNotificationsRead)
+	notification.Get("/preferences", h.GetNotificationPreferences)
+	notification.Put("/preferences", h.UpdateNotificationPreferences)
 
-	// Document routes
-	document := api.Group("/document")
-	document.Post("/upload", handlers.UploadDocument)
-	document.Get("/:id", handlers.GetDocumentByID)
-	document.Get("/", handlers.ListDocuments)
+	// Webhook subscriptions (tenant admins)
+	webhook := api.Group("/webhooks", middleware.AuthMiddleware(deps.Tokens), middleware.RequireRole(auth.RoleAdmin), read)
+	webhook.Post("/", h.CreateWebhook)
+	webhook.Get("/", h.ListWebhooks)
+	webhook.Patch("/:id", h.UpdateWebhook)
+	webhook.Delete("/:id", h.DeleteWebhook)
+	webhook.Get("/:id/deliveries", h.ListWebhookDeliveries)
+	webhook.Post("/:id/deliveries/:deliveryId/redeliver", h.RedeliverWebhookDelivery)
 
-	// Folder routes
-	folder := api.Group("/folder")
-	folder.Get("/", handlers.ListFolders)
+	// Admin routes
+	admin := api.Group("/admin", middleware.AuthMiddleware(deps.Tokens), middleware.RequireRole(auth.RoleAdmin), read)
+	admin.Get("/audit", h.ListAuditEvents)
+	admin.Get("/audit/verify", h.VerifyAuditChain)
+	admin.Get("/keys", h.ListTenantKeys)
+	admin.Post("/keys/rotate", h.RotateTenantKeys)
+	admin.Get("/ws/metrics", h.WebSocketMetrics)
+	admin.Post("/usage/reconcile", h.ReconcileUsage)
+	admin.Get("/log-level", h.GetLogLevel)
+	admin.Put("/log-level", h.SetLogLevel)
 
 	// Master routes
-	master := api.Group("/master")
-	master.Get("/", handlers.ListMasters)
+	master := api.Group("/master", read)
+	master.Get("/", h.ListMasters)
 
 	// Protected routes (require authentication)
 	protected := api.Group("/protected")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(deps.Tokens), read)
 	protected.Get("/profile", func(c *fiber.Ctx) error {
 		userID := c.Locals("user_id")
 		return c.JSON(fiber.Map{
@@ -54,5 +141,5 @@ func SetupRoutes(app *fiber.App) {
 	})
 
 	// Kafka testing (optional)
-	app.Post("/kafka/send", handlers.SendKafkaTestMessage)
+	app.Post("/kafka/send", h.SendKafkaTestMessage)
 }